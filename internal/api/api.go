@@ -0,0 +1,277 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package api serves the repository as a json rest api
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/chordflower/todoman/internal/utils"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	// DefaultLimit is the size of a page when the request does not give one
+	DefaultLimit = 50
+	// MaxLimit is the largest page a request can ask for
+	MaxLimit = 500
+)
+
+// Opener opens the store used by a request, to change it when asked to
+type Opener func(write bool) (store.Store, error)
+
+// handler handles a request whose path matched a route, with the ids of the path, returning the status and body
+// of the response
+type handler func(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error)
+
+// route associates a method and a path, where {id} matches an id, to a handler
+type route struct {
+	method  string
+	pattern []string
+	handle  handler
+}
+
+// Server is the http handler of the rest api, the store is opened again on every request so that the changes
+// made by the cli are always visible, and the requests are handled one at a time
+type Server struct {
+	open   Opener
+	token  string
+	mutex  sync.Mutex
+	routes []route
+}
+
+// apiError is an error with the http status to answer with
+type apiError struct {
+	status  int
+	message string
+}
+
+// Error returns the message of this error
+func (e *apiError) Error() string {
+	return e.message
+}
+
+// badRequest returns an error for a request that can not be understood
+func badRequest(format string, args ...any) error {
+	return &apiError{status: http.StatusBadRequest, message: errors.Errorf(format, args...).Error()}
+}
+
+// invalid returns an error for a request whose models are not valid
+func invalid(err error) error {
+	return &apiError{status: http.StatusBadRequest, message: err.Error()}
+}
+
+// page is a page of a list
+type page struct {
+	Items  any `json:"items"`
+	Total  int `json:"total"`
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// errorBody is the body of an error response
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// NewServer creates the rest api of the stores given by the opener, the requests must send the given token
+func NewServer(open Opener, token string) *Server {
+	srv := &Server{open: open, token: token}
+	srv.routes = []route{
+		{http.MethodGet, []string{"boards"}, srv.listBoards},
+		{http.MethodPost, []string{"boards"}, srv.createBoard},
+		{http.MethodGet, []string{"boards", "{id}"}, srv.getBoard},
+		{http.MethodPatch, []string{"boards", "{id}"}, srv.updateBoard},
+		{http.MethodDelete, []string{"boards", "{id}"}, srv.deleteBoard},
+		{http.MethodGet, []string{"boards", "{id}", "todos"}, srv.listTodos},
+		{http.MethodPost, []string{"boards", "{id}", "todos"}, srv.createTodo},
+		{http.MethodGet, []string{"todos"}, srv.listTodos},
+		{http.MethodGet, []string{"todos", "{id}"}, srv.getTodo},
+		{http.MethodPatch, []string{"todos", "{id}"}, srv.updateTodo},
+		{http.MethodDelete, []string{"todos", "{id}"}, srv.deleteTodo},
+		{http.MethodGet, []string{"todos", "{id}", "notes"}, srv.listNotes},
+		{http.MethodPost, []string{"todos", "{id}", "notes"}, srv.createNote},
+		{http.MethodDelete, []string{"todos", "{id}", "notes", "{id}"}, srv.deleteNote},
+		{http.MethodGet, []string{"todos", "{id}", "efforts"}, srv.listEfforts},
+		{http.MethodPost, []string{"todos", "{id}", "efforts"}, srv.createEffort},
+		{http.MethodDelete, []string{"todos", "{id}", "efforts", "{id}"}, srv.deleteEffort},
+		{http.MethodGet, []string{"tags"}, srv.listTags},
+		{http.MethodGet, []string{"milestones"}, srv.listMilestones},
+		{http.MethodPost, []string{"milestones"}, srv.createMilestone},
+		{http.MethodGet, []string{"milestones", "{id}"}, srv.getMilestone},
+		{http.MethodPatch, []string{"milestones", "{id}"}, srv.updateMilestone},
+		{http.MethodDelete, []string{"milestones", "{id}"}, srv.deleteMilestone},
+	}
+	return srv
+}
+
+// ServeHTTP handles a request of the api
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	if path == "openapi.json" && r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, OpenAPI())
+		return
+	}
+	if !srv.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="todoman"`)
+		writeJSON(w, http.StatusUnauthorized, errorBody{Error: "missing or invalid token"})
+		return
+	}
+
+	handle, ids, status := srv.match(r.Method, strings.Split(path, "/"))
+	if handle == nil {
+		writeJSON(w, status, errorBody{Error: http.StatusText(status)})
+		return
+	}
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	s, err := srv.open(r.Method != http.MethodGet && r.Method != http.MethodHead)
+	if err == nil {
+		defer s.Close()
+		var body any
+		if status, body, err = handle(s, r, ids); err == nil {
+			writeJSON(w, status, body)
+			return
+		}
+	}
+	status = statusOf(err)
+	if status == http.StatusInternalServerError {
+		utils.Error("%s %s: %s", r.Method, r.URL.Path, err)
+	}
+	writeJSON(w, status, errorBody{Error: err.Error()})
+}
+
+// authorized checks if the request sends the token of this server
+func (srv *Server) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return srv.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(srv.token)) == 1
+}
+
+// match returns the handler of the route with the given method and path and the ids of the path, or else the
+// status to answer with
+func (srv *Server) match(method string, parts []string) (handler, []uuid.UUID, int) {
+	status := http.StatusNotFound
+	for _, rt := range srv.routes {
+		ids, ok := matchPattern(rt.pattern, parts)
+		if !ok {
+			continue
+		}
+		if rt.method == method {
+			return rt.handle, ids, 0
+		}
+		status = http.StatusMethodNotAllowed
+	}
+	return nil, nil, status
+}
+
+// matchPattern checks if the given path parts match the given pattern, returning the ids of the path
+func matchPattern(pattern, parts []string) ([]uuid.UUID, bool) {
+	if len(pattern) != len(parts) {
+		return nil, false
+	}
+	ids := make([]uuid.UUID, 0)
+	for i, part := range pattern {
+		if part != "{id}" {
+			if part != parts[i] {
+				return nil, false
+			}
+			continue
+		}
+		id, err := uuid.FromString(parts[i])
+		if err != nil {
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
+// statusOf returns the http status of the given error
+func statusOf(err error) int {
+	var ae *apiError
+	switch {
+	case errors.As(err, &ae):
+		return ae.status
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, store.ErrInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, model.ErrDependencyCycle), errors.Is(err, model.ErrTodoBlocked), errors.Is(err, model.ErrUnknownTodo):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeJSON writes the given value as the json body of the response, without a body for 204 responses
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		utils.Error("Unable to write the response: %s", err)
+	}
+}
+
+// readJSON reads the json body of the request into the given value, refusing unknown fields
+func readJSON(r *http.Request, value any) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		return badRequest("invalid body: %s", err.Error())
+	}
+	return nil
+}
+
+// paginate returns the page of the given items asked by the offset and limit query parameters
+func paginate[T any](r *http.Request, items []T) (*page, error) {
+	query := r.URL.Query()
+	offset, limit := 0, DefaultLimit
+	if value := query.Get("offset"); value != "" {
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 0 {
+			return nil, badRequest("invalid offset %q", value)
+		}
+		offset = number
+	}
+	if value := query.Get("limit"); value != "" {
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 1 || number > MaxLimit {
+			return nil, badRequest("invalid limit %q, it must be between 1 and %d", value, MaxLimit)
+		}
+		limit = number
+	}
+	start, end := offset, offset+limit
+	if start > len(items) {
+		start = len(items)
+	}
+	if end > len(items) {
+		end = len(items)
+	}
+	return &page{Items: items[start:end], Total: len(items), Offset: offset, Limit: limit}, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"image/color"
+	"net/http"
+	"strings"
+
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/gofrs/uuid"
+)
+
+// BoardInput is the body of the requests that create or change a board, the missing fields are left as they are
+type BoardInput struct {
+	Name        *string     `json:"name"`
+	Description *string     `json:"description"`
+	Colour      *color.RGBA `json:"colour"`
+}
+
+// apply changes the given board with the fields of this input
+func (in *BoardInput) apply(board *model.Board) {
+	if in.Name != nil {
+		board.Name = *in.Name
+	}
+	if in.Description != nil {
+		board.Description = *in.Description
+	}
+	if in.Colour != nil {
+		board.Colour = *in.Colour
+	}
+}
+
+func (srv *Server) listBoards(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	boards := s.Boards()
+	if name := r.URL.Query().Get("name"); name != "" {
+		filtered := make([]*model.Board, 0, len(boards))
+		for _, board := range boards {
+			if strings.Contains(strings.ToLower(board.Name), strings.ToLower(name)) {
+				filtered = append(filtered, board)
+			}
+		}
+		boards = filtered
+	}
+	list, err := paginate(r, boards)
+	return http.StatusOK, list, err
+}
+
+func (srv *Server) createBoard(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	in := &BoardInput{}
+	if err := readJSON(r, in); err != nil {
+		return 0, nil, err
+	}
+	board := model.NewBoard("", color.RGBA{})
+	in.apply(board)
+	if err := board.Validate(); err != nil {
+		return 0, nil, invalid(err)
+	}
+	return http.StatusCreated, board, s.SaveBoard(board)
+}
+
+func (srv *Server) getBoard(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	board, err := s.Board(ids[0])
+	return http.StatusOK, board, err
+}
+
+func (srv *Server) updateBoard(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	board, err := s.Board(ids[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	in := &BoardInput{}
+	if err := readJSON(r, in); err != nil {
+		return 0, nil, err
+	}
+	in.apply(board)
+	if err := board.Validate(); err != nil {
+		return 0, nil, invalid(err)
+	}
+	return http.StatusOK, board, s.SaveBoard(board)
+}
+
+func (srv *Server) deleteBoard(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	return http.StatusNoContent, nil, s.RemoveBoard(ids[0])
+}
+
+func (srv *Server) listTags(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	list, err := paginate(r, s.Tags())
+	return http.StatusOK, list, err
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/gofrs/uuid"
+)
+
+// MilestoneInput is the body of the requests that create or change a milestone, the missing fields are left as they
+// are
+type MilestoneInput struct {
+	Name        *string         `json:"name"`
+	Description *string         `json:"description"`
+	DueDate     *model.DateTime `json:"due_date"`
+}
+
+// apply changes the given milestone with the fields of this input
+func (in *MilestoneInput) apply(milestone *model.Milestone) {
+	if in.Name != nil {
+		milestone.Name = *in.Name
+	}
+	if in.Description != nil {
+		milestone.Description = *in.Description
+	}
+	if in.DueDate != nil {
+		milestone.DueDate = *in.DueDate
+	}
+}
+
+func (srv *Server) listMilestones(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	list, err := paginate(r, s.Milestones())
+	return http.StatusOK, list, err
+}
+
+func (srv *Server) createMilestone(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	in := &MilestoneInput{}
+	if err := readJSON(r, in); err != nil {
+		return 0, nil, err
+	}
+	milestone := model.NewMilestone("")
+	in.apply(milestone)
+	if err := milestone.Validate(); err != nil {
+		return 0, nil, invalid(err)
+	}
+	return http.StatusCreated, milestone, s.SaveMilestone(milestone)
+}
+
+func (srv *Server) getMilestone(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	milestone, err := s.Milestone(ids[0])
+	return http.StatusOK, milestone, err
+}
+
+func (srv *Server) updateMilestone(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	milestone, err := s.Milestone(ids[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	in := &MilestoneInput{}
+	if err := readJSON(r, in); err != nil {
+		return 0, nil, err
+	}
+	in.apply(milestone)
+	if err := milestone.Validate(); err != nil {
+		return 0, nil, invalid(err)
+	}
+	return http.StatusOK, milestone, s.SaveMilestone(milestone)
+}
+
+func (srv *Server) deleteMilestone(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	return http.StatusNoContent, nil, s.RemoveMilestone(ids[0])
+}
@@ -0,0 +1,288 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"image/color"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/gofrs/uuid"
+)
+
+// components are the models described by the openapi document, generated from their types
+var components = map[string]reflect.Type{
+	"Board":          reflect.TypeOf(model.Board{}),
+	"Todo":           reflect.TypeOf(model.Todo{}),
+	"AgileTodo":      reflect.TypeOf(model.AgileTodo{}),
+	"Note":           reflect.TypeOf(model.Note{}),
+	"Effort":         reflect.TypeOf(model.Effort{}),
+	"Tag":            reflect.TypeOf(model.Tag{}),
+	"Milestone":      reflect.TypeOf(model.Milestone{}),
+	"ChecklistItem":  reflect.TypeOf(model.ChecklistItem{}),
+	"BoardInput":     reflect.TypeOf(BoardInput{}),
+	"TodoInput":      reflect.TypeOf(TodoInput{}),
+	"NoteInput":      reflect.TypeOf(NoteInput{}),
+	"EffortInput":    reflect.TypeOf(EffortInput{}),
+	"MilestoneInput": reflect.TypeOf(MilestoneInput{}),
+	"Error":          reflect.TypeOf(errorBody{}),
+}
+
+// ref returns a reference to the component with the given name
+func ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// names returns the names of the values from first to last, as a description of their numbers
+func names[T interface{ Name() string }](first, last int, value func(int) T) string {
+	parts := make([]string, 0)
+	for i := first; i <= last; i++ {
+		parts = append(parts, fmt.Sprintf("%d %s", i, value(i).Name()))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// typeSchema returns the schema of the given type
+func typeSchema(t reflect.Type) map[string]any {
+	switch t {
+	case reflect.TypeOf(uuid.UUID{}):
+		return map[string]any{"type": "string", "format": "uuid"}
+	case reflect.TypeOf(model.DateTime{}):
+		return map[string]any{"type": "string", "format": "date-time", "description": "An RFC 3339 date, or an empty string when not set"}
+	case reflect.TypeOf(time.Duration(0)):
+		return map[string]any{"type": "integer", "format": "int64", "description": "A duration in nanoseconds"}
+	case reflect.TypeOf(model.Recurrence{}):
+		return map[string]any{"type": "string", "description": "An RFC 5545 RRULE with FREQ, INTERVAL, BYDAY and UNTIL"}
+	case reflect.TypeOf(color.RGBA{}):
+		channel := map[string]any{"type": "integer", "minimum": 0, "maximum": 255}
+		return map[string]any{
+			"type":       "object",
+			"required":   []string{"R", "G", "B", "A"},
+			"properties": map[string]any{"R": channel, "G": channel, "B": channel, "A": channel},
+		}
+	case reflect.TypeOf(model.TodoStatus(0)):
+		return map[string]any{
+			"type":        "integer",
+			"minimum":     int(model.STATUS_NEW),
+			"maximum":     int(model.STATUS_DONE),
+			"description": names(int(model.STATUS_NEW), int(model.STATUS_DONE), func(i int) model.TodoStatus { return model.TodoStatus(i) }),
+		}
+	case reflect.TypeOf(model.TodoPriority(0)):
+		return map[string]any{
+			"type":        "integer",
+			"minimum":     int(model.PRIORITY_LOWEST),
+			"maximum":     int(model.PRIORITY_HIGHEST),
+			"description": names(int(model.PRIORITY_LOWEST), int(model.PRIORITY_HIGHEST), func(i int) model.TodoPriority { return model.TodoPriority(i) }),
+		}
+	}
+	for name, component := range components {
+		if t == component {
+			return ref(name)
+		}
+	}
+	// The collections are kept as an array of the models returned by their Values method
+	if values, ok := t.MethodByName("Values"); ok && values.Type.NumOut() == 1 && values.Type.Out(0).Kind() == reflect.Slice {
+		return typeSchema(values.Type.Out(0))
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Uint8:
+		return map[string]any{"type": "integer", "minimum": 0, "maximum": 255}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	return map[string]any{}
+}
+
+// structSchema returns the schema of the given struct, using the json names of its fields
+func structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var collect func(t reflect.Type)
+	collect = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			if field.Anonymous && tag == "" {
+				collect(field.Type)
+				continue
+			}
+			name, _, _ := strings.Cut(tag, ",")
+			if name == "-" || !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = typeSchema(field.Type)
+		}
+	}
+	collect(t)
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+// operation describes an operation of the api
+func operation(summary string, body string, status int, response any, parameters ...map[string]any) map[string]any {
+	op := map[string]any{
+		"summary": summary,
+		"responses": map[string]any{
+			"default": map[string]any{
+				"description": "An error",
+				"content":     map[string]any{"application/json": map[string]any{"schema": ref("Error")}},
+			},
+		},
+	}
+	if response == nil {
+		op["responses"].(map[string]any)[fmt.Sprint(status)] = map[string]any{"description": "Done"}
+	} else {
+		op["responses"].(map[string]any)[fmt.Sprint(status)] = map[string]any{
+			"description": "Done",
+			"content":     map[string]any{"application/json": map[string]any{"schema": response}},
+		}
+	}
+	if body != "" {
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": ref(body)}},
+		}
+	}
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+	return op
+}
+
+// parameter describes a parameter of an operation
+func parameter(in, name, description string, schema map[string]any) map[string]any {
+	return map[string]any{"in": in, "name": name, "description": description, "required": in == "path", "schema": schema}
+}
+
+// pageOf returns the schema of a page of the given schema
+func pageOf(items map[string]any) map[string]any {
+	integer := map[string]any{"type": "integer"}
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"items":  map[string]any{"type": "array", "items": items},
+			"total":  integer,
+			"offset": integer,
+			"limit":  integer,
+		},
+	}
+}
+
+// OpenAPI returns the openapi document of the api
+func OpenAPI() map[string]any {
+	id := func(name string) map[string]any {
+		return parameter("path", name, "The id of the "+strings.TrimSuffix(name, "_id"), map[string]any{"type": "string", "format": "uuid"})
+	}
+	str := map[string]any{"type": "string"}
+	pagination := []map[string]any{
+		parameter("query", "offset", "The number of items to skip", map[string]any{"type": "integer", "minimum": 0}),
+		parameter("query", "limit", fmt.Sprintf("The size of the page, %d by default", DefaultLimit), map[string]any{"type": "integer", "minimum": 1, "maximum": MaxLimit}),
+	}
+	filters := append([]map[string]any{
+		parameter("query", "status", "Only the todos with the given status name", str),
+		parameter("query", "priority", "Only the todos with the given priority name", str),
+		parameter("query", "tag", "Only the todos with the given tag, can be repeated", str),
+		parameter("query", "overdue", "Only the unfinished todos whose due date has passed", map[string]any{"type": "boolean"}),
+		parameter("query", "soon", "Only the unfinished todos due within the given duration, like 3d", str),
+		parameter("query", "q", "Only the todos whose name or description contain the given text", str),
+	}, pagination...)
+	task := map[string]any{"oneOf": []any{ref("Todo"), ref("AgileTodo")}}
+
+	schemas := make(map[string]any)
+	for name, t := range components {
+		schemas[name] = structSchema(t)
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "todoman",
+			"version": "1",
+		},
+		"security": []any{map[string]any{"token": []string{}}},
+		"paths": map[string]any{
+			"/boards": map[string]any{
+				"get":  operation("List the boards", "", 200, pageOf(ref("Board")), append([]map[string]any{parameter("query", "name", "Only the boards whose name contains the given text", str)}, pagination...)...),
+				"post": operation("Create a board", "BoardInput", 201, ref("Board")),
+			},
+			"/boards/{board_id}": map[string]any{
+				"get":    operation("Get a board", "", 200, ref("Board"), id("board_id")),
+				"patch":  operation("Change a board", "BoardInput", 200, ref("Board"), id("board_id")),
+				"delete": operation("Remove a board and its todos", "", 204, nil, id("board_id")),
+			},
+			"/boards/{board_id}/todos": map[string]any{
+				"get":  operation("List the todos of a board", "", 200, pageOf(task), append([]map[string]any{id("board_id")}, filters...)...),
+				"post": operation("Create a todo", "TodoInput", 201, task, id("board_id")),
+			},
+			"/todos": map[string]any{
+				"get": operation("List the todos of every board", "", 200, pageOf(task), filters...),
+			},
+			"/todos/{todo_id}": map[string]any{
+				"get":    operation("Get a todo", "", 200, task, id("todo_id")),
+				"patch":  operation("Change a todo", "TodoInput", 200, task, id("todo_id")),
+				"delete": operation("Remove a todo and its subtasks", "", 204, nil, id("todo_id")),
+			},
+			"/todos/{todo_id}/notes": map[string]any{
+				"get":  operation("List the notes of a todo", "", 200, pageOf(ref("Note")), append([]map[string]any{id("todo_id")}, pagination...)...),
+				"post": operation("Add a note to a todo", "NoteInput", 201, ref("Note"), id("todo_id")),
+			},
+			"/todos/{todo_id}/notes/{note_id}": map[string]any{
+				"delete": operation("Remove a note", "", 204, nil, id("todo_id"), id("note_id")),
+			},
+			"/todos/{todo_id}/efforts": map[string]any{
+				"get":  operation("List the efforts of an agile todo", "", 200, pageOf(ref("Effort")), append([]map[string]any{id("todo_id")}, pagination...)...),
+				"post": operation("Add an effort to an agile todo", "EffortInput", 201, ref("Effort"), id("todo_id")),
+			},
+			"/todos/{todo_id}/efforts/{effort_id}": map[string]any{
+				"delete": operation("Remove an effort", "", 204, nil, id("todo_id"), id("effort_id")),
+			},
+			"/tags": map[string]any{
+				"get": operation("List the tags", "", 200, pageOf(ref("Tag")), pagination...),
+			},
+			"/milestones": map[string]any{
+				"get":  operation("List the milestones", "", 200, pageOf(ref("Milestone")), pagination...),
+				"post": operation("Create a milestone", "MilestoneInput", 201, ref("Milestone")),
+			},
+			"/milestones/{milestone_id}": map[string]any{
+				"get":    operation("Get a milestone", "", 200, ref("Milestone"), id("milestone_id")),
+				"patch":  operation("Change a milestone", "MilestoneInput", 200, ref("Milestone"), id("milestone_id")),
+				"delete": operation("Remove a milestone", "", 204, nil, id("milestone_id")),
+			},
+		},
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"token": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
@@ -0,0 +1,346 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/gofrs/uuid"
+)
+
+// TodoInput is the body of the requests that create or change a todo, the missing fields are left as they are
+type TodoInput struct {
+	Name              *string             `json:"name"`
+	Description       *string             `json:"description"`
+	Status            *model.TodoStatus   `json:"status"`
+	Priority          *model.TodoPriority `json:"priority"`
+	StartDate         *model.DateTime     `json:"start_date"`
+	DueDate           *model.DateTime     `json:"due_date"`
+	Tags              []string            `json:"tags"`
+	Recurrence        *string             `json:"recurrence"` // An RRULE, or an empty string to stop repeating
+	Agile             bool                `json:"agile"`      // Creates an agile todo, only used when creating
+	Points            *uint8              `json:"points"`
+	EstimatedDuration *time.Duration      `json:"estimated_duration"`
+}
+
+// NoteInput is the body of the requests that add a note to a todo
+type NoteInput struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Author      string `json:"author"`
+}
+
+// EffortInput is the body of the requests that add an effort to an agile todo
+type EffortInput struct {
+	Date        model.DateTime `json:"date"`
+	Duration    time.Duration  `json:"duration"`
+	Description string         `json:"description"`
+}
+
+// apply changes the given task with the fields of this input, except its status which must be changed with
+// store.SetStatus after the task is saved
+func (in *TodoInput) apply(s store.Store, task model.Task) error {
+	t := task.Base()
+	if in.Status != nil && in.Status.Name() == "" {
+		return invalid(errors.Errorf("unknown status %d", *in.Status))
+	}
+	if in.Priority != nil && in.Priority.Name() == "" {
+		return invalid(errors.Errorf("unknown priority %d", *in.Priority))
+	}
+	if in.Name != nil {
+		t.Name = *in.Name
+	}
+	if in.Description != nil {
+		t.Description = *in.Description
+	}
+	if in.Priority != nil {
+		t.Priority = *in.Priority
+	}
+	if in.StartDate != nil {
+		t.StartDate = *in.StartDate
+	}
+	if in.DueDate != nil {
+		t.DueDate = *in.DueDate
+	}
+	if in.Tags != nil {
+		for _, name := range in.Tags {
+			if _, err := s.Tag(name); err != nil {
+				return invalid(errors.Errorf("unknown tag %q", name))
+			}
+		}
+		t.Tags = in.Tags
+	}
+	if in.Recurrence != nil {
+		t.Recurrence = nil
+		if *in.Recurrence != "" {
+			recurrence, err := model.ParseRecurrence(*in.Recurrence)
+			if err != nil {
+				return invalid(err)
+			}
+			t.Recurrence = recurrence
+		}
+	}
+	ag, agile := task.(*model.AgileTodo)
+	if (in.Points != nil || in.EstimatedDuration != nil) && !agile {
+		return invalid(errors.New("only agile todos have points and an estimated duration"))
+	}
+	if in.Points != nil {
+		ag.Points = *in.Points
+	}
+	if in.EstimatedDuration != nil {
+		ag.EstimatedDuration = *in.EstimatedDuration
+	}
+	if err := task.Validate(); err != nil {
+		return invalid(err)
+	}
+	if in.Status != nil && *in.Status == model.STATUS_STARTED && t.Status != model.STATUS_STARTED {
+		if _, err := s.Todo(t.ID); err == nil {
+			return store.DependencyGraph(s).CanStart(t.ID)
+		}
+	}
+	return nil
+}
+
+// save saves the given task in the given board and then changes its status if the input has one
+func (in *TodoInput) save(s store.Store, board uuid.UUID, task model.Task) error {
+	if err := s.SaveTodo(board, task); err != nil {
+		return err
+	}
+	if in.Status != nil && *in.Status != task.Base().Status {
+		_, err := store.SetStatus(s, task.Base().ID, *in.Status)
+		return err
+	}
+	return nil
+}
+
+// todoQuery returns the query of the todos of the board with the given id, or of every board for the nil id, given
+// by the status, priority, overdue and soon parameters of the request, with the same meaning as the flags of the
+// list commands
+func todoQuery(r *http.Request, board uuid.UUID) (*store.TodoQuery, error) {
+	params := r.URL.Query()
+	query := &store.TodoQuery{Board: board, Now: time.Now(), Overdue: params.Get("overdue") == "true"}
+	if value := params.Get("status"); value != "" {
+		status, err := model.ParseTodoStatus(value)
+		if err != nil {
+			return nil, badRequest("%s", err.Error())
+		}
+		query.Status = &status
+	}
+	if value := params.Get("priority"); value != "" {
+		priority, err := model.ParseTodoPriority(value)
+		if err != nil {
+			return nil, badRequest("%s", err.Error())
+		}
+		query.Priority = &priority
+	}
+	if value := params.Get("soon"); value != "" {
+		within, err := model.ParseDuration(value)
+		if err != nil {
+			return nil, badRequest("%s", err.Error())
+		}
+		query.Soon = within
+	}
+	return query, nil
+}
+
+// todoFilters returns the filters given by the other parameters of the request, with the same meaning as the flags
+// of the list commands
+func todoFilters(r *http.Request) []func(model.Task) bool {
+	query := r.URL.Query()
+	filters := make([]func(model.Task) bool, 0)
+	for _, tag := range query["tag"] {
+		tag := tag
+		filters = append(filters, func(task model.Task) bool { return task.Base().HasTag(tag) })
+	}
+	if value := strings.ToLower(query.Get("q")); value != "" {
+		filters = append(filters, func(task model.Task) bool {
+			t := task.Base()
+			return strings.Contains(strings.ToLower(t.Name), value) || strings.Contains(strings.ToLower(t.Description), value)
+		})
+	}
+	return filters
+}
+
+func (srv *Server) listTodos(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	board := uuid.Nil
+	if len(ids) == 1 {
+		board = ids[0]
+	}
+	query, err := todoQuery(r, board)
+	if err != nil {
+		return 0, nil, err
+	}
+	tasks, err := s.FindTodos(query)
+	if err != nil {
+		return 0, nil, err
+	}
+	filters := todoFilters(r)
+	filtered := make([]model.Task, 0, len(tasks))
+next:
+	for _, task := range tasks {
+		for _, filter := range filters {
+			if !filter(task) {
+				continue next
+			}
+		}
+		filtered = append(filtered, task)
+	}
+	list, err := paginate(r, filtered)
+	return http.StatusOK, list, err
+}
+
+func (srv *Server) createTodo(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	if _, err := s.Board(ids[0]); err != nil {
+		return 0, nil, err
+	}
+	in := &TodoInput{}
+	if err := readJSON(r, in); err != nil {
+		return 0, nil, err
+	}
+	var task model.Task = model.NewTodo("")
+	if in.Agile {
+		task = model.NewAgileTodo("")
+	}
+	if err := in.apply(s, task); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, task, in.save(s, ids[0], task)
+}
+
+func (srv *Server) getTodo(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	task, err := s.Todo(ids[0])
+	return http.StatusOK, task, err
+}
+
+func (srv *Server) updateTodo(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	task, err := s.Todo(ids[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	board, err := s.BoardOf(ids[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	in := &TodoInput{}
+	if err := readJSON(r, in); err != nil {
+		return 0, nil, err
+	}
+	if err := in.apply(s, task); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, task, in.save(s, board.ID, task)
+}
+
+func (srv *Server) deleteTodo(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	return http.StatusNoContent, nil, store.RemoveTodoTree(s, ids[0])
+}
+
+func (srv *Server) listNotes(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	task, err := s.Todo(ids[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	list, err := paginate(r, task.Base().Notes.Values())
+	return http.StatusOK, list, err
+}
+
+func (srv *Server) createNote(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	task, err := s.Todo(ids[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	in := &NoteInput{}
+	if err := readJSON(r, in); err != nil {
+		return 0, nil, err
+	}
+	note := model.NewNote(in.Name, in.Author)
+	note.Description = in.Description
+	if err := note.Validate(); err != nil {
+		return 0, nil, invalid(err)
+	}
+	task.Base().AddNote(note)
+	return http.StatusCreated, note, store.SaveTask(s, task)
+}
+
+func (srv *Server) deleteNote(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	task, err := s.Todo(ids[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	if !task.Base().HasNote(ids[1]) {
+		return 0, nil, errors.Wrapf(store.ErrNotFound, "note %s", ids[1])
+	}
+	task.Base().RemoveNote(ids[1])
+	return http.StatusNoContent, nil, store.SaveTask(s, task)
+}
+
+// agileTodo returns the agile todo with the given id
+func agileTodo(s store.Store, id uuid.UUID) (*model.AgileTodo, error) {
+	task, err := s.Todo(id)
+	if err != nil {
+		return nil, err
+	}
+	ag, ok := task.(*model.AgileTodo)
+	if !ok {
+		return nil, badRequest("todo %s is not an agile todo", task.Base().Name)
+	}
+	return ag, nil
+}
+
+func (srv *Server) listEfforts(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	ag, err := agileTodo(s, ids[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	list, err := paginate(r, ag.Effort.Values())
+	return http.StatusOK, list, err
+}
+
+func (srv *Server) createEffort(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	ag, err := agileTodo(s, ids[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	in := &EffortInput{}
+	if err := readJSON(r, in); err != nil {
+		return 0, nil, err
+	}
+	effort := model.NewEffort(in.Date.DateTime, in.Duration)
+	effort.Description = in.Description
+	if err := effort.Validate(); err != nil {
+		return 0, nil, invalid(err)
+	}
+	if !ag.AddEffort(effort) {
+		return 0, nil, invalid(errors.New("the efforts of a day can not add up to more than 24 hours"))
+	}
+	return http.StatusCreated, effort, store.SaveTask(s, ag)
+}
+
+func (srv *Server) deleteEffort(s store.Store, r *http.Request, ids []uuid.UUID) (int, any, error) {
+	ag, err := agileTodo(s, ids[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	if !ag.HasEffort(ids[1]) {
+		return 0, nil, errors.Wrapf(store.ErrNotFound, "effort %s", ids[1])
+	}
+	ag.RemoveEffort(ids[1])
+	return http.StatusNoContent, nil, store.SaveTask(s, ag)
+}
@@ -15,6 +15,12 @@
 package app
 
 import (
+	"os"
+	"strings"
+
+	"github.com/chordflower/todoman/internal/cmd"
+	"github.com/chordflower/todoman/internal/plugin"
+	"github.com/chordflower/todoman/internal/utils"
 	"github.com/tucnak/climax"
 )
 
@@ -29,11 +35,57 @@ func Run() {
 	// Add the groups
 
 	// Add the application commands
+	commands := []cmd.Command{
+		cmd.NewBoardCommand(),
+		cmd.NewTodoCommand(),
+		cmd.NewTagCommand(),
+		cmd.NewMemberCommand(),
+		cmd.NewRemindCommand(),
+		cmd.NewImportCommand(),
+		cmd.NewExportCommand(),
+		cmd.NewServeCommand(),
+		cmd.NewBackupCommand(),
+		cmd.NewRestoreCommand(),
+		cmd.NewHookCommand(),
+		cmd.NewPluginCommand(),
+		cmd.NewTrashCommand(),
+		cmd.NewUndoCommand(),
+		cmd.NewRepoCommand(),
+		cmd.NewLogCommand(),
+	}
+	commands = append(commands, cmd.NewCompletionCommand(commands))
+	for _, command := range commands {
+		todoman.AddCommand(*command.Configure())
+	}
+
+	// Run the plugin of an unknown command
+	if len(os.Args) > 1 && !isCommand(commands, os.Args[1]) {
+		if p, err := plugin.Find(os.Args[1]); err == nil {
+			code, err := p.Run(todoman.Version, os.Args[2:])
+			if err != nil {
+				utils.Error("%s", err.Error())
+			}
+			os.Exit(code)
+		}
+	}
 
 	// Add the application topics
 
 	// Run the application
 
-	todoman.Run()
+	os.Exit(todoman.Run())
+
+}
 
+// isCommand checks if the given argument is a todoman command or option, which take precedence over the plugins
+func isCommand(commands []cmd.Command, name string) bool {
+	if name == "help" || strings.HasPrefix(name, "-") {
+		return true
+	}
+	for _, command := range commands {
+		if command.Name() == name {
+			return true
+		}
+	}
+	return false
 }
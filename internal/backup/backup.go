@@ -0,0 +1,432 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package backup writes and restores archives with every file of a repository and of the configuration
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+)
+
+const (
+	// Format identifies the manifest of a todoman backup
+	Format = "todoman-backup"
+	// Version is the version of the archives written by this version of todoman
+	Version = 1
+	// ManifestName is the name of the manifest inside the archive
+	ManifestName = "manifest.json"
+	// RepositoryDir is the folder of the archive with the repository files
+	RepositoryDir = "repository"
+	// ConfigDir is the folder of the archive with the configuration files
+	ConfigDir = "config"
+)
+
+// ErrInvalidArchive is returned when an archive is damaged or is not a todoman backup
+var ErrInvalidArchive = errors.Sentinel("invalid backup archive")
+
+// ManifestFile describes a file of the archive
+type ManifestFile struct {
+	Path   string `json:"path"`   // The path of the file inside the archive
+	Size   int64  `json:"size"`   // The size in bytes of the file
+	SHA256 string `json:"sha256"` // The hex encoded sha256 checksum of the file
+}
+
+// Manifest describes the contents of an archive
+type Manifest struct {
+	Format  string         `json:"format"`  // Always todoman-backup
+	Version int            `json:"version"` // The version of the archive layout
+	Created time.Time      `json:"created"` // When the archive was written
+	Files   []ManifestFile `json:"files"`   // The files of the archive, without the manifest
+}
+
+// Archive is a backup read into memory
+type Archive struct {
+	Manifest Manifest
+	files    map[string][]byte
+}
+
+// checksum returns the hex encoded sha256 checksum of the given data
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// collect reads every file under the given directory into the given map, under the given archive folder
+func collect(files map[string][]byte, dir, folder string) error {
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		// The lock of the repository belongs to the processes using it
+		if folder == RepositoryDir && (rel == store.LockFile || filepath.Dir(rel) == store.HoldersDir) {
+			return nil
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		files[path.Join(folder, filepath.ToSlash(rel))] = data
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return errors.Wrapf(err, "unable to read %s", dir)
+}
+
+// Write writes a gzip compressed tar archive with every file of the given repository and configuration directories,
+// preceded by a manifest with their checksums
+func Write(w io.Writer, repository, configDir string) (*Manifest, error) {
+	files := make(map[string][]byte)
+	if err := collect(files, repository, RepositoryDir); err != nil {
+		return nil, err
+	}
+	if err := collect(files, configDir, ConfigDir); err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{Format: Format, Version: Version, Created: time.Now().UTC(), Files: make([]ManifestFile, 0, len(files))}
+	for name, data := range files {
+		manifest.Files = append(manifest.Files, ManifestFile{Path: name, Size: int64(len(data)), SHA256: checksum(data)})
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Path < manifest.Files[j].Path
+	})
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to encode the manifest")
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	write := func(name string, data []byte) error {
+		header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: manifest.Created}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	if err := write(ManifestName, data); err != nil {
+		return nil, errors.Wrap(err, "unable to write the archive")
+	}
+	for _, file := range manifest.Files {
+		if err := write(file.Path, files[file.Path]); err != nil {
+			return nil, errors.Wrap(err, "unable to write the archive")
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, errors.Wrap(err, "unable to write the archive")
+	}
+	return manifest, errors.Wrap(gz.Close(), "unable to write the archive")
+}
+
+// Read reads an archive written by Write, checking its manifest and the checksums of its files
+func Read(r io.Reader) (*Archive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidArchive, err.Error())
+	}
+	defer gz.Close()
+	archive := &Archive{files: make(map[string][]byte)}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(ErrInvalidArchive, err.Error())
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(header.Name)
+		if path.IsAbs(name) || strings.HasPrefix(name, "../") {
+			return nil, errors.Wrapf(ErrInvalidArchive, "unsafe path %s", header.Name)
+		}
+		var buffer bytes.Buffer
+		if _, err := io.Copy(&buffer, tr); err != nil {
+			return nil, errors.Wrap(ErrInvalidArchive, err.Error())
+		}
+		archive.files[name] = buffer.Bytes()
+	}
+
+	data, ok := archive.files[ManifestName]
+	if !ok {
+		return nil, errors.Wrap(ErrInvalidArchive, "missing manifest")
+	}
+	delete(archive.files, ManifestName)
+	if err := json.Unmarshal(data, &archive.Manifest); err != nil {
+		return nil, errors.Wrapf(ErrInvalidArchive, "invalid manifest: %s", err.Error())
+	}
+	if archive.Manifest.Format != Format {
+		return nil, errors.Wrap(ErrInvalidArchive, "not a todoman backup")
+	}
+	if archive.Manifest.Version < 1 || archive.Manifest.Version > Version {
+		return nil, errors.Wrapf(ErrInvalidArchive, "unsupported version %d", archive.Manifest.Version)
+	}
+	if len(archive.Manifest.Files) != len(archive.files) {
+		return nil, errors.Wrapf(ErrInvalidArchive, "the manifest lists %d files but the archive has %d",
+			len(archive.Manifest.Files), len(archive.files))
+	}
+	for _, file := range archive.Manifest.Files {
+		data, ok := archive.files[file.Path]
+		if !ok {
+			return nil, errors.Wrapf(ErrInvalidArchive, "missing file %s", file.Path)
+		}
+		if int64(len(data)) != file.Size || checksum(data) != file.SHA256 {
+			return nil, errors.Wrapf(ErrInvalidArchive, "checksum mismatch of %s", file.Path)
+		}
+	}
+	return archive, nil
+}
+
+// schemaOf returns the name of the schema of the repository file with the given path inside the archive, or an
+// empty string if the file has no schema
+func schemaOf(name string) string {
+	parts := strings.Split(name, "/")
+	if parts[0] != RepositoryDir {
+		return ""
+	}
+	parts = parts[1:]
+	// The archived and removed boards and todos have the same layout as the others
+	if len(parts) > 1 && (parts[0] == "archive" || parts[0] == "trash") {
+		parts = parts[1:]
+	}
+	switch {
+	case len(parts) == 1 && parts[0] == "index.json":
+		return "index"
+	case len(parts) == 1 && parts[0] == "tags.json":
+		return "tag"
+	case len(parts) == 1 && parts[0] == "users.json":
+		return "user"
+	case len(parts) == 1 && parts[0] == "milestones.json":
+		return "milestone"
+	case len(parts) == 3 && parts[0] == "boards" && parts[2] == "board.json":
+		return "board"
+	case len(parts) == 3 && parts[0] == "boards" && parts[2] == "index.json":
+		return "index"
+	case len(parts) == 4 && parts[0] == "boards" && parts[2] == "todos" && path.Ext(parts[3]) == ".json":
+		return "todo"
+	}
+	return ""
+}
+
+// Validate checks the repository files of this archive against their json schemas, and the database of a sqlite
+// repository for damage and its version
+func (a *Archive) Validate() error {
+	schemas := make(map[string]*schema)
+	for _, file := range a.Manifest.Files {
+		if file.Path == path.Join(RepositoryDir, store.SQLiteFile) {
+			if err := a.validateDatabase(file.Path); err != nil {
+				return errors.Wrapf(ErrInvalidArchive, "%s: %s", file.Path, err.Error())
+			}
+			continue
+		}
+		name := schemaOf(file.Path)
+		if name == "" {
+			continue
+		}
+		sch, ok := schemas[name]
+		if !ok {
+			var err error
+			if sch, err = loadSchema(name); err != nil {
+				return err
+			}
+			schemas[name] = sch
+		}
+		if err := validateJSON(sch, a.files[file.Path]); err != nil {
+			return errors.Wrapf(ErrInvalidArchive, "%s: %s", file.Path, err.Error())
+		}
+	}
+	return nil
+}
+
+// validateDatabase checks the sqlite database of the given file of this archive, from a temporary copy since sqlite
+// can only open files
+func (a *Archive) validateDatabase(name string) error {
+	dir, err := os.MkdirTemp("", "todoman-validate-")
+	if err != nil {
+		return errors.Wrap(err, "unable to create a temporary directory")
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, store.SQLiteFile)
+	if err := os.WriteFile(file, a.files[name], 0o600); err != nil {
+		return errors.Wrapf(err, "unable to write %s", file)
+	}
+	return store.CheckSQLite(file)
+}
+
+// Count returns how many files of this archive are in the given folder
+func (a *Archive) Count(folder string) int {
+	count := 0
+	for name := range a.files {
+		if strings.HasPrefix(name, folder+"/") {
+			count++
+		}
+	}
+	return count
+}
+
+// Extract writes the files of the given folder of this archive into the given directory
+func (a *Archive) Extract(folder, dir string) error {
+	for name, data := range a.files {
+		if !strings.HasPrefix(name, folder+"/") {
+			continue
+		}
+		file := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(name, folder+"/")))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			return errors.Wrapf(err, "unable to create the directory of %s", file)
+		}
+		if err := os.WriteFile(file, data, 0o644); err != nil {
+			return errors.Wrapf(err, "unable to write %s", file)
+		}
+	}
+	return nil
+}
+
+// Replace replaces the given repository with the one of this archive, the current repository is moved aside and
+// its new location is returned, or an empty string if there was no repository
+func (a *Archive) Replace(repository string) (string, error) {
+	restored := repository + ".restoring"
+	if err := os.RemoveAll(restored); err != nil {
+		return "", errors.Wrapf(err, "unable to remove %s", restored)
+	}
+	if err := a.Extract(RepositoryDir, restored); err != nil {
+		return "", err
+	}
+	previous := ""
+	if _, err := os.Stat(repository); err == nil {
+		previous = repository + ".before-restore-" + time.Now().Format("20060102-150405")
+		if err := os.Rename(repository, previous); err != nil {
+			return "", errors.Wrap(err, "unable to move the current repository")
+		}
+	}
+	if err := os.Rename(restored, repository); err != nil {
+		return previous, errors.Wrap(err, "unable to move the restored repository")
+	}
+	return previous, nil
+}
+
+// MergeResult tells how many models were added by a merge
+type MergeResult struct {
+	Boards     int
+	Todos      int
+	Tags       int
+	Users      int
+	Milestones int
+	Skipped    int // The models of the archive that already were in the repository
+}
+
+// Merge adds the boards, todos, tags, users and milestones of this archive that the given store does not have, the
+// ones it already has are kept as they are. The tags and users go first, so that the added todos find the ones they
+// have.
+func (a *Archive) Merge(s store.Store) (result MergeResult, err error) {
+	dir, err := os.MkdirTemp("", "todoman-restore-")
+	if err != nil {
+		return result, errors.Wrap(err, "unable to create a temporary directory")
+	}
+	defer os.RemoveAll(dir)
+	if err := a.Extract(RepositoryDir, dir); err != nil {
+		return result, err
+	}
+	archived, err := store.NewStore(dir)
+	if err != nil {
+		return result, err
+	}
+	defer archived.Close()
+
+	for _, tag := range archived.Tags() {
+		if _, err := s.Tag(tag.Name); err == nil {
+			result.Skipped++
+			continue
+		}
+		if err := s.SaveTag(tag); err != nil {
+			return result, err
+		}
+		result.Tags++
+	}
+	for _, user := range archived.Users() {
+		if _, err := s.User(user.Name); err == nil {
+			result.Skipped++
+			continue
+		}
+		if err := s.SaveUser(user); err != nil {
+			return result, err
+		}
+		result.Users++
+	}
+	for _, milestone := range archived.Milestones() {
+		if _, err := s.Milestone(milestone.ID); err == nil {
+			result.Skipped++
+			continue
+		}
+		if err := s.SaveMilestone(milestone); err != nil {
+			return result, err
+		}
+		result.Milestones++
+	}
+	for _, board := range archived.Boards() {
+		if _, err := s.Board(board.ID); err != nil {
+			restored := model.NewBoard(board.Name, board.Colour)
+			restored.ID = board.ID
+			restored.CreationDate = board.CreationDate
+			restored.Description = board.Description
+			if err := s.SaveBoard(restored); err != nil {
+				return result, err
+			}
+			result.Boards++
+		} else {
+			result.Skipped++
+		}
+		tasks, err := archived.Todos(board.ID)
+		if err != nil {
+			return result, err
+		}
+		for _, task := range tasks {
+			if _, err := s.Todo(task.Base().ID); err == nil {
+				result.Skipped++
+				continue
+			}
+			if err := s.SaveTodo(board.ID, task); err != nil {
+				return result, err
+			}
+			result.Todos++
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,193 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/share"
+	"github.com/gofrs/uuid"
+)
+
+// schema is the subset of a draft-07 json schema used by the schemas of the repository files
+type schema struct {
+	Type                 string             `json:"type"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	Enum                 []json.Number      `json:"enum"`
+	Format               string             `json:"format"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Minimum              *json.Number       `json:"minimum"`
+	Maximum              *json.Number       `json:"maximum"`
+}
+
+// loadSchema reads the schema of the given model from the shared schemas
+func loadSchema(name string) (*schema, error) {
+	data, err := share.Schemas.ReadFile(name + ".schema.json")
+	if err != nil {
+		return nil, errors.Wrapf(err, "unknown schema %s", name)
+	}
+	sch := &schema{}
+	if err := json.Unmarshal(data, sch); err != nil {
+		return nil, errors.Wrapf(err, "invalid schema %s", name)
+	}
+	return sch, nil
+}
+
+// validateJSON checks that the given json data follows the given schema
+func validateJSON(sch *schema, data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return errors.Wrap(err, "invalid json")
+	}
+	return sch.validate("", value)
+}
+
+// validate checks the given decoded json value, at the given json pointer without its leading slash, against
+// this schema
+func (sch *schema) validate(path string, value any) error {
+	switch sch.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return errors.Errorf("/%s: expected an object", path)
+		}
+		return sch.validateObject(path, object)
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return errors.Errorf("/%s: expected an array", path)
+		}
+		if sch.Items != nil {
+			for i, item := range array {
+				if err := sch.Items.validate(strings.TrimPrefix(fmt.Sprintf("%s/%d", path, i), "/"), item); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return errors.Errorf("/%s: expected a string", path)
+		}
+		return sch.validateString(path, text)
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			return errors.Errorf("/%s: expected a number", path)
+		}
+		return sch.validateNumber(path, number)
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return errors.Errorf("/%s: expected a boolean", path)
+		}
+	}
+	return nil
+}
+
+func (sch *schema) validateObject(path string, object map[string]any) error {
+	for _, name := range sch.Required {
+		if _, ok := object[name]; !ok {
+			return errors.Errorf("/%s: missing property %s", path, name)
+		}
+	}
+	for name, value := range object {
+		property, ok := sch.Properties[name]
+		if !ok {
+			if sch.AdditionalProperties != nil && !*sch.AdditionalProperties {
+				return errors.Errorf("/%s: unknown property %s", path, name)
+			}
+			continue
+		}
+		if err := property.validate(strings.TrimPrefix(path+"/"+name, "/"), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sch *schema) validateString(path, text string) error {
+	length := utf8.RuneCountInString(text)
+	if sch.MinLength != nil && length < *sch.MinLength {
+		return errors.Errorf("/%s: must have at least %d characters", path, *sch.MinLength)
+	}
+	if sch.MaxLength != nil && length > *sch.MaxLength {
+		return errors.Errorf("/%s: must have at most %d characters", path, *sch.MaxLength)
+	}
+	switch sch.Format {
+	case "uuid":
+		if _, err := uuid.FromString(text); err != nil {
+			return errors.Errorf("/%s: invalid uuid %q", path, text)
+		}
+	case "date", "date-time":
+		// The dates that are not set are stored as empty strings
+		if text == "" {
+			return nil
+		}
+		if _, err := time.Parse(time.RFC3339Nano, text); err != nil {
+			if _, err := time.Parse("2006-01-02", text); err != nil {
+				return errors.Errorf("/%s: invalid date %q", path, text)
+			}
+		}
+	}
+	return nil
+}
+
+func (sch *schema) validateNumber(path string, number json.Number) error {
+	value, err := number.Float64()
+	if err != nil {
+		return errors.Errorf("/%s: invalid number %s", path, number)
+	}
+	if sch.Type == "integer" && strings.ContainsAny(number.String(), ".eE") {
+		return errors.Errorf("/%s: expected an integer", path)
+	}
+	if len(sch.Enum) > 0 {
+		found := false
+		for _, option := range sch.Enum {
+			if option.String() == number.String() {
+				found = true
+			}
+		}
+		if !found {
+			return errors.Errorf("/%s: %s is not one of the allowed values", path, number)
+		}
+	}
+	if minimum, err := numberValue(sch.Minimum); err == nil && value < minimum {
+		return errors.Errorf("/%s: must be at least %s", path, sch.Minimum)
+	}
+	if maximum, err := numberValue(sch.Maximum); err == nil && value > maximum {
+		return errors.Errorf("/%s: must be at most %s", path, sch.Maximum)
+	}
+	return nil
+}
+
+// numberValue returns the value of the given optional number
+func numberValue(number *json.Number) (float64, error) {
+	if number == nil {
+		return 0, errors.New("no number")
+	}
+	return number.Float64()
+}
@@ -0,0 +1,216 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/backup"
+	"github.com/chordflower/todoman/internal/config"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/chordflower/todoman/internal/utils"
+	"github.com/tucnak/climax"
+)
+
+// BackupCommand writes an archive with the whole repository and configuration
+type BackupCommand struct{}
+
+// NewBackupCommand creates a new backup command
+func NewBackupCommand() *BackupCommand {
+	return &BackupCommand{}
+}
+
+// Name returns the name of this command
+func (c *BackupCommand) Name() string {
+	return "backup"
+}
+
+// Configure returns the climax definition of this command
+func (c *BackupCommand) Configure() *climax.Command {
+	return &climax.Command{
+		Name:  c.Name(),
+		Brief: "backup the repository",
+		Usage: "[<file>]",
+		Help: `Writes a tar.gz archive with every board, todo, tag and index of the
+repository and the configuration files, with a manifest listing the checksums
+of every file. Without a file the archive is written to the current directory
+as todoman-backup-<date>.tar.gz.`,
+		Examples: []climax.Example{
+			{
+				Usecase:     "~/backups/todoman.tar.gz",
+				Description: "Writes a backup to the given file",
+			},
+		},
+		Handle: c.Run,
+	}
+}
+
+// Run executes this command
+func (c *BackupCommand) Run(ctx climax.Context) int {
+	if len(ctx.Args) > 1 {
+		return fail(errors.New("usage: backup [<file>]"))
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fail(err)
+	}
+	name := "todoman-backup-" + time.Now().Format("20060102-150405") + ".tar.gz"
+	if len(ctx.Args) == 1 {
+		name = ctx.Args[0]
+	}
+	lock, err := store.LockRepository(cfg.Repository, false, time.Duration(cfg.LockTimeout)*time.Second)
+	if err != nil {
+		return fail(err)
+	}
+	defer lock.Unlock()
+	output, err := os.Create(name)
+	if err != nil {
+		return fail(err)
+	}
+	manifest, err := backup.Write(output, cfg.Repository, config.Dir())
+	if err == nil {
+		err = output.Close()
+	} else {
+		output.Close()
+	}
+	if err != nil {
+		os.Remove(name)
+		return fail(err)
+	}
+	utils.Info("Wrote %d files to %s", len(manifest.Files), name)
+	return 0
+}
+
+// RestoreCommand restores an archive written by the backup command
+type RestoreCommand struct{}
+
+// NewRestoreCommand creates a new restore command
+func NewRestoreCommand() *RestoreCommand {
+	return &RestoreCommand{}
+}
+
+// Name returns the name of this command
+func (c *RestoreCommand) Name() string {
+	return "restore"
+}
+
+// Configure returns the climax definition of this command
+func (c *RestoreCommand) Configure() *climax.Command {
+	return &climax.Command{
+		Name:  c.Name(),
+		Brief: "restore a backup of the repository",
+		Usage: "<file>",
+		Help: `Restores an archive written by the backup command. The checksums of the
+archive and its files are checked against the json schemas of the repository,
+or the database of a sqlite repository for damage and its version, before
+anything changes. By default the repository is replaced, keeping the
+current one next to it as <repository>.before-restore-<date>, with --merge
+only the boards, todos, tags and members missing from the repository are
+added.`,
+		Flags: []climax.Flag{
+			{
+				Name:  "merge",
+				Short: "m",
+				Usage: "--merge",
+				Help:  "Add the missing models instead of replacing the repository",
+			},
+			{
+				Name:  "config",
+				Short: "c",
+				Usage: "--config",
+				Help:  "Also restore the configuration files",
+			},
+			{
+				Name:  "dry-run",
+				Short: "n",
+				Usage: "--dry-run",
+				Help:  "Only check the archive",
+			},
+		},
+		Examples: []climax.Example{
+			{
+				Usecase:     "todoman-backup.tar.gz --merge",
+				Description: "Adds the todos of a backup that are missing from the repository",
+			},
+		},
+		Handle: c.Run,
+	}
+}
+
+// Run executes this command
+func (c *RestoreCommand) Run(ctx climax.Context) int {
+	if len(ctx.Args) != 1 {
+		return fail(errors.New("usage: restore <file>"))
+	}
+	input, err := os.Open(ctx.Args[0])
+	if err != nil {
+		return fail(err)
+	}
+	defer input.Close()
+	archive, err := backup.Read(input)
+	if err != nil {
+		return fail(err)
+	}
+	if err := archive.Validate(); err != nil {
+		return fail(err)
+	}
+	if ctx.Is("dry-run") {
+		utils.Info("The backup of %s is valid, with %d repository and %d configuration files",
+			archive.Manifest.Created.Local().Format("2006-01-02 15:04"),
+			archive.Count(backup.RepositoryDir), archive.Count(backup.ConfigDir))
+		return 0
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fail(err)
+	}
+	if ctx.Is("merge") {
+		s, err := openStore()
+		if err != nil {
+			return fail(err)
+		}
+		defer s.Close()
+		result, err := archive.Merge(s)
+		if err != nil {
+			return fail(err)
+		}
+		utils.Info("Added %d boards, %d todos, %d tags, %d members and %d milestones, %d already existed",
+			result.Boards, result.Todos, result.Tags, result.Users, result.Milestones, result.Skipped)
+	} else {
+		lock, err := store.LockRepository(cfg.Repository, true, time.Duration(cfg.LockTimeout)*time.Second)
+		if err != nil {
+			return fail(err)
+		}
+		previous, err := archive.Replace(cfg.Repository)
+		lock.Unlock()
+		if err != nil {
+			return fail(err)
+		}
+		if previous != "" {
+			utils.Info("The previous repository was moved to %s", previous)
+		}
+		utils.Info("Restored the repository from %s", ctx.Args[0])
+	}
+	if ctx.Is("config") {
+		if err := archive.Extract(backup.ConfigDir, config.Dir()); err != nil {
+			return fail(err)
+		}
+		utils.Info("Restored the configuration to %s", config.Dir())
+	}
+	return 0
+}
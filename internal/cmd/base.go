@@ -14,7 +14,22 @@
 
 package cmd
 
-import "github.com/tucnak/climax"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/config"
+	"github.com/chordflower/todoman/internal/hook"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/chordflower/todoman/internal/utils"
+	"github.com/gofrs/uuid"
+	"github.com/tucnak/climax"
+)
 
 // Command represents a app command
 type Command interface {
@@ -22,3 +37,105 @@ type Command interface {
 	Run(ctx climax.Context) int
 	Configure() *climax.Command
 }
+
+// openStore opens the repository defined by the configuration to change it, whose changes are kept in its history and
+// sent to the configured hooks
+func openStore() (store.Store, error) {
+	return openRepository(true, true)
+}
+
+// openReader opens the repository defined by the configuration only to read it, sharing it with the other readers
+func openReader() (store.Store, error) {
+	return openRepository(false, false)
+}
+
+// openRepository opens the store of the repository, locking it for the other processes while it changes it and
+// keeping who made each change, and the history of its changes when asked to. A store opened to change the repository
+// archives the old done todos and empties the old trash as configured.
+func openRepository(exclusive, history bool) (store.Store, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	s, err := store.Open(cfg.Repository, exclusive, time.Duration(cfg.LockTimeout)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if exclusive && cfg.TrashRetention > 0 {
+		if err := s.EmptyTrash(time.Now().AddDate(0, 0, -cfg.TrashRetention)); err != nil {
+			s.Close()
+			return nil, errors.Wrap(err, "unable to empty the trash")
+		}
+	}
+	if exclusive {
+		path := ""
+		if history {
+			path = filepath.Join(cfg.Repository, store.HistoryFile)
+		}
+		audit := filepath.Join(cfg.Repository, store.AuditFile)
+		s = store.NewHistoryStore(s, path, audit, strings.Join(os.Args[1:], " "), cfg.User)
+	}
+	hooks, err := hook.Load(config.Dir())
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	bus := store.NewBus()
+	bus.Subscribe(hook.NewDispatcher(hooks, cfg.Repository).Listen)
+	s = store.NewEventStore(s, bus)
+	if exclusive && cfg.ArchiveAfter > 0 {
+		if _, err := store.AutoArchive(s, time.Now().AddDate(0, 0, -cfg.ArchiveAfter)); err != nil {
+			s.Close()
+			return nil, errors.Wrap(err, "unable to archive the done todos")
+		}
+	}
+	return s, nil
+}
+
+// fail prints the given error and returns the exit code for a failed command
+func fail(err error) int {
+	utils.Error("%s", err.Error())
+	return 1
+}
+
+// maxCandidates is the number of candidates shown when a reference is ambiguous
+const maxCandidates = 10
+
+// resolve returns the id of the item of the given index that is referenced by either its id, a unique prefix of
+// its id, its name or a unique part of its name
+func resolve(index *model.Index, ref string, kind string) (uuid.UUID, error) {
+	items := index.Match(ref)
+	switch len(items) {
+	case 0:
+		return uuid.Nil, errors.Wrapf(store.ErrNotFound, "%s %q", kind, ref)
+	case 1:
+		return items[0].ID, nil
+	}
+	candidates := ""
+	for i, item := range items {
+		if i == maxCandidates {
+			candidates += fmt.Sprintf("\n  and %d more", len(items)-maxCandidates)
+			break
+		}
+		candidates += fmt.Sprintf("\n  %-8s  %s", index.ShortID(item.ID), item.Name)
+	}
+	return uuid.Nil, errors.Errorf("%q matches %d %ss, use a longer id or name:%s", ref, len(items), kind, candidates)
+}
+
+// findBoard finds the board with the given id or name
+func findBoard(s store.Store, ref string) (*model.Board, error) {
+	id, err := resolve(s.BoardIndex(), ref, "board")
+	if err != nil {
+		return nil, err
+	}
+	return s.Board(id)
+}
+
+// findTodo finds the todo with the given id or name
+func findTodo(s store.Store, ref string) (model.Task, error) {
+	id, err := resolve(s.TodoIndex(), ref, "todo")
+	if err != nil {
+		return nil, err
+	}
+	return s.Todo(id)
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/gofrs/uuid"
+)
+
+func TestResolve(t *testing.T) {
+	index := model.NewIndex()
+	for _, name := range []string{"write docs", "write tests", "review"} {
+		index.AddItem(model.NewItem(uuid.Must(uuid.NewV4()), name))
+	}
+	for i := 0; i < maxCandidates+2; i++ {
+		index.AddItem(model.NewItem(uuid.Must(uuid.NewV4()), fmt.Sprintf("many %d", i)))
+	}
+	tests := []struct {
+		name string
+		ref  string
+		want string   // The name of the resolved item, empty when it is not resolved
+		err  error    // The error of the reference, nil for an ambiguous one
+		text []string // The texts of the error message
+	}{
+		{"unique", "review", "review", nil, nil},
+		{"not found", "deploy", "", store.ErrNotFound, []string{`todo "deploy"`}},
+		{"ambiguous", "write", "", nil, []string{`"write" matches 2 todos`, "write docs", "write tests"}},
+		{"too many candidates", "many", "", nil,
+			[]string{fmt.Sprintf(`"many" matches %d todos`, maxCandidates+2), "and 2 more"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			id, err := resolve(index, test.ref, "todo")
+			if test.want != "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if item := index.GetItem(id); item == nil || item.Name != test.want {
+					t.Errorf("%q resolved to %v, expected %s", test.ref, item, test.want)
+				}
+				return
+			}
+			if err == nil || id != uuid.Nil {
+				t.Fatalf("%q resolved to %s", test.ref, id)
+			}
+			if test.err != nil && !errors.Is(err, test.err) {
+				t.Errorf("%q failed with %s, expected %s", test.ref, err, test.err)
+			}
+			for _, text := range test.text {
+				if !strings.Contains(err.Error(), text) {
+					t.Errorf("the error %q does not contain %q", err, text)
+				}
+			}
+			if test.err != nil {
+				return
+			}
+			// The first candidates are shown by their short ids
+			for _, item := range index.Match(test.ref)[:2] {
+				if !strings.Contains(err.Error(), index.ShortID(item.ID)) {
+					t.Errorf("the error %q does not show the short id of %s", err, item.Name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/utils"
+	"github.com/tucnak/climax"
+)
+
+// BoardCommand manages the boards of the repository
+type BoardCommand struct{}
+
+// NewBoardCommand creates a new board command
+func NewBoardCommand() *BoardCommand {
+	return &BoardCommand{}
+}
+
+// Name returns the name of this command
+func (c *BoardCommand) Name() string {
+	return "board"
+}
+
+// Configure returns the climax definition of this command
+func (c *BoardCommand) Configure() *climax.Command {
+	return &climax.Command{
+		Name:  c.Name(),
+		Brief: "manage the boards",
+		Usage: "add <name> | list [--archived] | rm <board> | archive <board> | restore <board>",
+		Help: `Creates, lists and removes boards, a board can be referenced by its id or name.
+An archived board and its todos are moved to the archive of the repository,
+where they are only shown with --archived, until the board is restored.`,
+		Flags: []climax.Flag{
+			{
+				Name:     "colour",
+				Short:    "c",
+				Usage:    `--colour="(r,g,b,a)"`,
+				Help:     "The colour of the board being added",
+				Variable: true,
+			},
+			{
+				Name:     "description",
+				Short:    "d",
+				Usage:    `--description="text"`,
+				Help:     "The description of the board being added",
+				Variable: true,
+			},
+			{
+				Name:  "archived",
+				Usage: "--archived",
+				Help:  "Lists the boards of the archive",
+			},
+		},
+		Examples: []climax.Example{
+			{
+				Usecase:     `add work --colour="(255,0,0,255)"`,
+				Description: "Creates a red board named work",
+			},
+		},
+		Handle: c.Run,
+	}
+}
+
+// Run executes this command
+func (c *BoardCommand) Run(ctx climax.Context) int {
+	if len(ctx.Args) == 0 {
+		return fail(errors.New("missing board action"))
+	}
+	open := openStore
+	if ctx.Args[0] == "list" {
+		open = openReader
+	}
+	s, err := open()
+	if err != nil {
+		return fail(err)
+	}
+	defer s.Close()
+	args := ctx.Args[1:]
+	switch ctx.Args[0] {
+	case "add":
+		if len(args) != 1 {
+			return fail(errors.New("usage: board add <name>"))
+		}
+		colour, _ := ctx.Get("colour")
+		board := model.NewBoard2(args[0], colour)
+		board.Description, _ = ctx.Get("description")
+		if err := s.SaveBoard(board); err != nil {
+			return fail(err)
+		}
+		utils.Info("Created board %s (%s)", board.Name, board.ID)
+	case "list":
+		if ctx.Is("archived") {
+			archive, err := s.Archive()
+			if err != nil {
+				return fail(err)
+			}
+			defer archive.Close()
+			s = archive
+		}
+		for _, board := range s.Boards() {
+			fmt.Printf("%-8s  %-20s  %s\n", s.BoardIndex().ShortID(board.ID), board.Name, board.ColourToString())
+		}
+	case "rm":
+		if len(args) != 1 {
+			return fail(errors.New("usage: board rm <board>"))
+		}
+		board, err := findBoard(s, args[0])
+		if err != nil {
+			return fail(err)
+		}
+		if err := s.RemoveBoard(board.ID); err != nil {
+			return fail(err)
+		}
+		utils.Info("Removed board %s", board.Name)
+	case "archive":
+		if len(args) != 1 {
+			return fail(errors.New("usage: board archive <board>"))
+		}
+		board, err := findBoard(s, args[0])
+		if err != nil {
+			return fail(err)
+		}
+		if err := s.ArchiveBoard(board.ID); err != nil {
+			return fail(err)
+		}
+		utils.Info("Archived board %s", board.Name)
+	case "restore":
+		if len(args) != 1 {
+			return fail(errors.New("usage: board restore <board>"))
+		}
+		archive, err := s.Archive()
+		if err != nil {
+			return fail(err)
+		}
+		defer archive.Close()
+		board, err := findBoard(archive, args[0])
+		if err != nil {
+			return fail(err)
+		}
+		if err := s.RestoreBoard(board.ID); err != nil {
+			return fail(err)
+		}
+		utils.Info("Restored board %s", board.Name)
+	default:
+		return fail(errors.Errorf("unknown board action %q", ctx.Args[0]))
+	}
+	return 0
+}
@@ -0,0 +1,363 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/config"
+	"github.com/chordflower/todoman/internal/hook"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/plugin"
+	"github.com/tucnak/climax"
+)
+
+//go:embed completions
+var completions embed.FS
+
+// completer returns the values of an argument or flag, each one optionally followed by a tab and its description
+type completer func() ([]string, error)
+
+// usageForm is an alternative of the usage of a command, like status <todo> <status>
+type usageForm struct {
+	action   string   // The literal first word, empty if the form has none
+	args     []string // The names of the placeholders that follow it
+	repeated bool     // If the last placeholder can be given many times, like <member>...
+}
+
+// parseUsage splits the given usage into its alternatives, ignoring the flags
+func parseUsage(usage string) []usageForm {
+	forms := make([]usageForm, 0)
+	for _, alternative := range strings.Split(usage, " | ") {
+		form := usageForm{args: make([]string, 0)}
+		for i, word := range strings.Fields(alternative) {
+			if strings.HasPrefix(strings.TrimLeft(word, "["), "-") {
+				continue
+			}
+			if i == 0 && !strings.ContainsAny(word, "<[") {
+				form.action = word
+				continue
+			}
+			form.repeated = strings.HasSuffix(word, "...")
+			form.args = append(form.args, strings.Trim(strings.TrimSuffix(word, "..."), "[]<>"))
+		}
+		forms = append(forms, form)
+	}
+	return forms
+}
+
+// CompletionCommand generates the shell completion scripts and completes the arguments for them
+type CompletionCommand struct {
+	commands   []Command
+	completers map[string]completer
+}
+
+// NewCompletionCommand creates a new completion command for the given commands
+func NewCompletionCommand(commands []Command) *CompletionCommand {
+	c := &CompletionCommand{}
+	c.commands = append(append(make([]Command, 0, len(commands)+1), commands...), c)
+	// The completers of the placeholders of the usages and of the flags with the same name
+	c.completers = map[string]completer{
+		"board":    c.boards,
+		"todo":     c.todos,
+		"on":       c.todos,
+		"before":   c.todos,
+		"after":    c.todos,
+		"to":       c.boards,
+		"tag":      c.tags,
+		"member":   c.members,
+		"assignee": c.members,
+		"status":   c.statuses,
+		"priority": c.priorities,
+		"hook":     c.hooks,
+		"removed":  c.removed,
+	}
+	return c
+}
+
+// Name returns the name of this command
+func (c *CompletionCommand) Name() string {
+	return "completion"
+}
+
+// Configure returns the climax definition of this command
+func (c *CompletionCommand) Configure() *climax.Command {
+	return &climax.Command{
+		Name:  c.Name(),
+		Brief: "generate the shell completion scripts",
+		Usage: "bash | zsh | fish",
+		Help: `Prints the completion script of the given shell, which completes the
+commands, actions and flags, and the names of the boards, todos, tags,
+members, statuses and priorities of the repository. The scripts find the values by
+running todoman completion args <command> [<arg>...] and todoman completion
+flags <command> [<flag>], so todoman must be on the PATH.`,
+		Examples: []climax.Example{
+			{
+				Usecase:     "bash > ~/.local/share/bash-completion/completions/todoman",
+				Description: "Installs the bash completion",
+			},
+			{
+				Usecase:     `zsh > "${fpath[1]}/_todoman"`,
+				Description: "Installs the zsh completion",
+			},
+			{
+				Usecase:     "fish > ~/.config/fish/completions/todoman.fish",
+				Description: "Installs the fish completion",
+			},
+		},
+		Handle: c.Run,
+	}
+}
+
+// Run executes this command
+func (c *CompletionCommand) Run(ctx climax.Context) int {
+	if len(ctx.Args) == 0 {
+		return fail(errors.New("usage: completion bash | zsh | fish"))
+	}
+	var (
+		values []string
+		err    error
+	)
+	switch ctx.Args[0] {
+	case "bash", "zsh", "fish":
+		script, err := completions.ReadFile("completions/todoman." + ctx.Args[0])
+		if err != nil {
+			return fail(err)
+		}
+		fmt.Print(string(script))
+		return 0
+	case "args":
+		values, err = c.args(ctx.Args[1:])
+	case "flags":
+		values, err = c.flags(ctx.Args[1:])
+	default:
+		return fail(errors.Errorf("unknown shell %q", ctx.Args[0]))
+	}
+	if err != nil {
+		return fail(err)
+	}
+	for _, value := range values {
+		fmt.Println(value)
+	}
+	return 0
+}
+
+// command returns the command with the given name, or nil if there is none
+func (c *CompletionCommand) command(name string) Command {
+	for _, command := range c.commands {
+		if command.Name() == name {
+			return command
+		}
+	}
+	return nil
+}
+
+// args returns the values of the argument that follows the given ones, starting with the command
+func (c *CompletionCommand) args(args []string) ([]string, error) {
+	if len(args) == 0 || (len(args) == 1 && args[0] == "help") {
+		values := make([]string, 0, len(c.commands)+1)
+		for _, command := range c.commands {
+			values = append(values, command.Name()+"\t"+command.Configure().Brief)
+		}
+		if len(args) == 0 {
+			values = append(values, "help\tshow the help of a command")
+		}
+		for _, p := range plugin.List() {
+			if c.command(p.Name) == nil {
+				values = append(values, p.Name+"\tplugin "+p.Path)
+			}
+		}
+		return values, nil
+	}
+	command := c.command(args[0])
+	if command == nil {
+		return nil, nil
+	}
+	forms := parseUsage(command.Configure().Usage)
+	args = args[1:]
+	if forms[0].action != "" {
+		if len(args) == 0 {
+			values := make([]string, 0, len(forms))
+			for _, form := range forms {
+				values = append(values, form.action)
+			}
+			return values, nil
+		}
+		for _, form := range forms {
+			if form.action == args[0] {
+				return c.complete(form, len(args)-1)
+			}
+		}
+		return nil, nil
+	}
+	return c.complete(forms[0], len(args))
+}
+
+// complete returns the values of the placeholder of the given form in the given position
+func (c *CompletionCommand) complete(form usageForm, position int) ([]string, error) {
+	if position >= len(form.args) {
+		if !form.repeated {
+			return nil, nil
+		}
+		position = len(form.args) - 1
+	}
+	if complete, ok := c.completers[form.args[position]]; ok {
+		return complete()
+	}
+	return nil, nil
+}
+
+// flags returns the flags of the given command, or the values of one of its flags
+func (c *CompletionCommand) flags(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	command := c.command(args[0])
+	if command == nil {
+		return nil, nil
+	}
+	flags := command.Configure().Flags
+	if len(args) > 1 {
+		for _, flag := range flags {
+			if flag.Name != args[1] && flag.Short != args[1] {
+				continue
+			}
+			if complete, ok := c.completers[flag.Name]; ok {
+				return complete()
+			}
+		}
+		return nil, nil
+	}
+	values := make([]string, 0, len(flags))
+	for _, flag := range flags {
+		name := "--" + flag.Name
+		if flag.Variable {
+			name += "="
+		}
+		values = append(values, name+"\t"+flag.Help)
+	}
+	return values, nil
+}
+
+// shortIDs returns the short ids of the items of the given index, with their names as the description
+func shortIDs(index *model.Index) []string {
+	values := make([]string, 0, index.Items.Len())
+	index.Items.Each(func(_ int, item *model.Item) {
+		values = append(values, index.ShortID(item.ID)+"\t"+item.Name)
+	})
+	return values
+}
+
+// boards returns the names of the boards
+func (c *CompletionCommand) boards() ([]string, error) {
+	s, err := openReader()
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	values := make([]string, 0)
+	for _, board := range s.Boards() {
+		values = append(values, board.Name)
+	}
+	return values, nil
+}
+
+// todos returns the short ids of the todos, with their names as the description
+func (c *CompletionCommand) todos() ([]string, error) {
+	s, err := openReader()
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	return shortIDs(s.TodoIndex()), nil
+}
+
+// removed returns the names of the removed boards and the short ids of the removed todos
+func (c *CompletionCommand) removed() ([]string, error) {
+	s, err := openReader()
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	trash, err := s.Trash()
+	if err != nil {
+		return nil, err
+	}
+	removed, err := s.Removed()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0)
+	for _, board := range trash.Boards() {
+		if _, ok := removed[board.ID]; ok {
+			values = append(values, board.Name)
+		}
+	}
+	return append(values, shortIDs(trash.TodoIndex())...), nil
+}
+
+// tags returns the names of the tags
+func (c *CompletionCommand) tags() ([]string, error) {
+	s, err := openReader()
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	values := make([]string, 0)
+	for _, tag := range s.Tags() {
+		values = append(values, tag.Name)
+	}
+	return values, nil
+}
+
+// members returns the names of the members of the repository
+func (c *CompletionCommand) members() ([]string, error) {
+	s, err := openReader()
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	values := make([]string, 0)
+	for _, user := range s.Users() {
+		values = append(values, user.Name)
+	}
+	return values, nil
+}
+
+// statuses returns the names of the todo statuses
+func (c *CompletionCommand) statuses() ([]string, error) {
+	return model.TodoStatusNames(), nil
+}
+
+// priorities returns the names of the todo priorities
+func (c *CompletionCommand) priorities() ([]string, error) {
+	return model.TodoPriorityNames(), nil
+}
+
+// hooks returns the names of the configured hooks
+func (c *CompletionCommand) hooks() ([]string, error) {
+	hooks, err := hook.Load(config.Dir())
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, len(hooks))
+	for _, h := range hooks {
+		values = append(values, h.Name)
+	}
+	return values, nil
+}
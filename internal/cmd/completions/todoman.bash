@@ -0,0 +1,41 @@
+# bash completion for todoman, load it with: source <(todoman completion bash)
+
+_todoman() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}" flag="" word i candidates
+    local -a args=()
+
+    # bash splits --flag=value into three words
+    if [[ $cur == "=" ]]; then
+        flag="$prev" cur=""
+    elif [[ $prev == "=" ]]; then
+        flag="${COMP_WORDS[COMP_CWORD-2]}"
+    fi
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        word="${COMP_WORDS[i]}"
+        if [[ $word == "=" ]]; then
+            ((i++))
+        elif [[ $word != -* ]]; then
+            args+=("$word")
+        fi
+    done
+
+    if [[ -n $flag ]]; then
+        flag="${flag#-}"
+        candidates="$(todoman completion flags "${args[0]}" "${flag#-}" 2>/dev/null)"
+    elif [[ $cur == -* ]]; then
+        candidates="$(todoman completion flags "${args[0]}" 2>/dev/null)"
+    else
+        candidates="$(todoman completion args "${args[@]}" 2>/dev/null)"
+    fi
+
+    local IFS=$'\n'
+    COMPREPLY=($(compgen -W "$(cut -f1 <<<"$candidates")" -- "$cur"))
+    if [[ ${#COMPREPLY[@]} -eq 1 && ${COMPREPLY[0]} == *= ]]; then
+        compopt -o nospace
+    fi
+    for i in "${!COMPREPLY[@]}"; do
+        COMPREPLY[i]="$(printf '%q' "${COMPREPLY[i]}")"
+    done
+}
+
+complete -o default -F _todoman todoman
@@ -0,0 +1,31 @@
+# fish completion for todoman, load it with: todoman completion fish | source
+
+function __todoman_complete
+    set -l tokens (commandline -opc)
+    set -l current (commandline -ct)
+    set -l args
+    set -e tokens[1]
+    for token in $tokens
+        string match -q -- '-*' $token; or set -a args $token
+    end
+
+    set -l candidates
+    if string match -q -- '-*=*' $current
+        set -l flag (string replace -r -- '=.*' '' $current)
+        for line in (todoman completion flags $args[1] (string trim -l -c - -- $flag) 2>/dev/null)
+            set -a candidates "$flag=$line"
+        end
+    else if string match -q -- '-*' $current
+        set candidates (todoman completion flags $args[1] 2>/dev/null)
+    else
+        set candidates (todoman completion args $args 2>/dev/null)
+    end
+
+    if test (count $candidates) -eq 0
+        __fish_complete_path $current
+    else
+        printf '%s\n' $candidates
+    end
+end
+
+complete -c todoman -f -a '(__todoman_complete)'
@@ -0,0 +1,417 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/config"
+	"github.com/chordflower/todoman/internal/exchange"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/chordflower/todoman/internal/utils"
+	"github.com/gofrs/uuid"
+	"github.com/tucnak/climax"
+)
+
+// importer reads the models of a format from the given reader into the store
+type importer func(s store.Store, ctx climax.Context, r io.Reader) (exchange.ImportResult, error)
+
+// ImportCommand reads todos from the formats of other applications
+type ImportCommand struct {
+	formats map[string]importer
+}
+
+// NewImportCommand creates a new import command
+func NewImportCommand() *ImportCommand {
+	c := &ImportCommand{}
+	c.formats = map[string]importer{
+		"todotxt": c.todoTxt,
+		"ics":     c.ical,
+		"trello":  c.trello,
+		"github":  c.github,
+		"gitlab":  c.gitlab,
+		"json":    c.json,
+	}
+	return c
+}
+
+// Name returns the name of this command
+func (c *ImportCommand) Name() string {
+	return "import"
+}
+
+// Configure returns the climax definition of this command
+func (c *ImportCommand) Configure() *climax.Command {
+	return &climax.Command{
+		Name:  c.Name(),
+		Brief: "import todos from other applications",
+		Usage: "todotxt | ics | trello | github | gitlab | json [<file>]",
+		Help: `Imports the todos of a file, or of the standard input without one.
+For todo.txt files the first +project is the board, the @contexts are the tags,
+and lines with an id: extension update the todo they were exported from. The
+priorities (A), (B) and (C) become highest, higher and high, (D) and no
+priority become normal, (E) and (F) become low and lower, and (G) to (Z) become
+lowest. The letter of a todo is kept, so that export writes (D) or (H) to (Z)
+back while its priority is not changed.
+For iCalendar files every VTODO becomes a todo of the board given by --board,
+a VTODO whose UID is the id of a todo updates it and its COMMENTs become notes.
+For trello board exports the board keeps its name and colour, the cards become
+todos with the status guessed from the name of their list, or given by --lists,
+and the comments become notes. For github and gitlab issue exports the issues
+go to the board given by --board, or named after their repository.
+The labels become tags and importing the same file again updates the todos
+created before. Use --dry-run to only show what would change.
+For json documents written by export json the boards, tags and todos are
+created or replaced, keeping their ids, and the todos without an id are
+created.`,
+		Flags: []climax.Flag{
+			{
+				Name:     "board",
+				Short:    "b",
+				Usage:    `--board="inbox"`,
+				Help:     "The board to import into, or of the todos without one",
+				Variable: true,
+			},
+			{
+				Name:     "lists",
+				Short:    "l",
+				Usage:    `--lists="Doing=started,QA=finished"`,
+				Help:     "The status of the cards of each trello list",
+				Variable: true,
+			},
+			{
+				Name:  "dry-run",
+				Short: "n",
+				Usage: "--dry-run",
+				Help:  "Only show what would be imported",
+			},
+		},
+		Examples: []climax.Example{
+			{
+				Usecase:     "todotxt ~/todo.txt",
+				Description: "Imports the todos of a todo.txt file",
+			},
+			{
+				Usecase:     "trello board.json --dry-run",
+				Description: "Shows what importing a trello board would change",
+			},
+		},
+		Handle: c.Run,
+	}
+}
+
+// Run executes this command
+func (c *ImportCommand) Run(ctx climax.Context) int {
+	if len(ctx.Args) < 1 || len(ctx.Args) > 2 {
+		return fail(errors.New("usage: import <format> [<file>]"))
+	}
+	format, ok := c.formats[ctx.Args[0]]
+	if !ok {
+		return fail(errors.Errorf("unknown import format %q", ctx.Args[0]))
+	}
+	s, err := openStore()
+	if err != nil {
+		return fail(err)
+	}
+	defer s.Close()
+	input := os.Stdin
+	if len(ctx.Args) == 2 {
+		if input, err = os.Open(ctx.Args[1]); err != nil {
+			return fail(err)
+		}
+		defer input.Close()
+	}
+	result, err := format(s, ctx, input)
+	if err != nil {
+		return fail(err)
+	}
+	if ctx.Is("dry-run") {
+		utils.Info("Would import %d new todos and update %d", result.Created, result.Updated)
+		return 0
+	}
+	utils.Info("Imported %d new todos and updated %d", result.Created, result.Updated)
+	return 0
+}
+
+func (c *ImportCommand) todoTxt(s store.Store, ctx climax.Context, r io.Reader) (exchange.ImportResult, error) {
+	if ctx.Is("dry-run") {
+		return exchange.ImportResult{}, errors.New("the todotxt format does not support --dry-run")
+	}
+	board, ok := ctx.Get("board")
+	if !ok {
+		board = "inbox"
+	}
+	return exchange.ImportTodoTxt(s, r, board)
+}
+
+func (c *ImportCommand) ical(s store.Store, ctx climax.Context, r io.Reader) (exchange.ImportResult, error) {
+	if ctx.Is("dry-run") {
+		return exchange.ImportResult{}, errors.New("the ics format does not support --dry-run")
+	}
+	name, ok := ctx.Get("board")
+	if !ok {
+		return exchange.ImportResult{}, errors.New("the board to import into must be given with --board")
+	}
+	board, err := findBoard(s, name)
+	if err != nil {
+		return exchange.ImportResult{}, err
+	}
+	return exchange.ImportICal(s, r, board.ID)
+}
+
+func (c *ImportCommand) json(s store.Store, ctx climax.Context, r io.Reader) (exchange.ImportResult, error) {
+	if ctx.Is("dry-run") {
+		return exchange.ImportResult{}, errors.New("the json format does not support --dry-run")
+	}
+	return exchange.ImportJSON(s, r)
+}
+
+// importOptions returns the options of the imports from trello, github and gitlab
+func importOptions(ctx climax.Context) (exchange.ImportOptions, error) {
+	options := exchange.ImportOptions{Statuses: make(map[string]model.TodoStatus)}
+	options.Board, _ = ctx.Get("board")
+	if ctx.Is("dry-run") {
+		options.DryRun = os.Stdout
+	}
+	if lists, ok := ctx.Get("lists"); ok {
+		for _, pair := range strings.Split(lists, ",") {
+			list, name, found := strings.Cut(pair, "=")
+			if !found {
+				return options, errors.Errorf("invalid list status %q, expected <list>=<status>", pair)
+			}
+			status, err := model.ParseTodoStatus(strings.TrimSpace(name))
+			if err != nil {
+				return options, err
+			}
+			options.Statuses[strings.TrimSpace(list)] = status
+		}
+	}
+	return options, nil
+}
+
+func (c *ImportCommand) trello(s store.Store, ctx climax.Context, r io.Reader) (exchange.ImportResult, error) {
+	options, err := importOptions(ctx)
+	if err != nil {
+		return exchange.ImportResult{}, err
+	}
+	return exchange.ImportTrello(s, r, options)
+}
+
+func (c *ImportCommand) github(s store.Store, ctx climax.Context, r io.Reader) (exchange.ImportResult, error) {
+	options, err := importOptions(ctx)
+	if err != nil {
+		return exchange.ImportResult{}, err
+	}
+	return exchange.ImportGitHub(s, r, options)
+}
+
+func (c *ImportCommand) gitlab(s store.Store, ctx climax.Context, r io.Reader) (exchange.ImportResult, error) {
+	options, err := importOptions(ctx)
+	if err != nil {
+		return exchange.ImportResult{}, err
+	}
+	return exchange.ImportGitLab(s, r, options)
+}
+
+// exporter writes the todos given by the arguments to the given writer
+type exporter func(s store.Store, ctx climax.Context, w io.Writer, args []string) error
+
+// ExportCommand writes todos in the formats of other applications
+type ExportCommand struct {
+	formats map[string]exporter
+}
+
+// NewExportCommand creates a new export command
+func NewExportCommand() *ExportCommand {
+	c := &ExportCommand{}
+	c.formats = map[string]exporter{
+		"todotxt":  c.todoTxt,
+		"ics":      c.ical,
+		"markdown": c.markdown,
+		"html":     c.html,
+		"json":     c.json,
+	}
+	return c
+}
+
+// Name returns the name of this command
+func (c *ExportCommand) Name() string {
+	return "export"
+}
+
+// Configure returns the climax definition of this command
+func (c *ExportCommand) Configure() *climax.Command {
+	return &climax.Command{
+		Name:  c.Name(),
+		Brief: "export todos to other applications",
+		Usage: "todotxt [<board>] | ics [<board>] | markdown <board> | html <board> | json [<board>]",
+		Help: `Exports the todos of a board, or of every board, to the standard output or
+to the file given by --output.
+
+The todotxt format writes a line for every todo, with its id: extension. The
+priorities highest, higher and high become (A), (B) and (C), normal has no
+letter, and low, lower and lowest become (E), (F) and (G), unless the todo was
+imported with another letter of its priority. The letter of a done todo goes to
+its pri: extension.
+
+The ics format writes a VTODO for every todo, with a COMMENT for every note,
+which import ics reads back as notes.
+
+The markdown and html formats write a report of a board, with its todos grouped
+by status and priority. The report templates can be replaced by placing a
+report.md.tmpl or report.html.tmpl file in the templates folder of the
+configuration directory.
+
+The json format writes the boards, tags and todos as they are stored in the
+repository, for scripts and plugins, and import json reads them back.`,
+		Flags: append([]climax.Flag{
+			{
+				Name:     "output",
+				Short:    "O",
+				Usage:    `--output="todo.txt"`,
+				Help:     "The file to write, instead of the standard output",
+				Variable: true,
+			},
+		}, filterFlags...),
+		Examples: []climax.Example{
+			{
+				Usecase:     "todotxt work --output=todo.txt",
+				Description: "Exports the todos of the work board to a todo.txt file",
+			},
+			{
+				Usecase:     "html work --output=work.html",
+				Description: "Writes a html report of the work board",
+			},
+		},
+		Handle: c.Run,
+	}
+}
+
+// Run executes this command
+func (c *ExportCommand) Run(ctx climax.Context) int {
+	if len(ctx.Args) == 0 {
+		return fail(errors.New("usage: export <format> [<board>]"))
+	}
+	format, ok := c.formats[ctx.Args[0]]
+	if !ok {
+		return fail(errors.Errorf("unknown export format %q", ctx.Args[0]))
+	}
+	s, err := openReader()
+	if err != nil {
+		return fail(err)
+	}
+	defer s.Close()
+	output := os.Stdout
+	if path, ok := ctx.Get("output"); ok {
+		if output, err = os.Create(path); err != nil {
+			return fail(err)
+		}
+		defer output.Close()
+	}
+	if err := format(s, ctx, output, ctx.Args[1:]); err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+// selectTasks returns the filtered todos of the board given in the arguments, or of every board
+func selectTasks(s store.Store, ctx climax.Context, args []string) ([]model.Task, error) {
+	board := uuid.Nil
+	switch len(args) {
+	case 0:
+	case 1:
+		b, err := findBoard(s, args[0])
+		if err != nil {
+			return nil, err
+		}
+		board = b.ID
+	default:
+		return nil, errors.New("too many arguments")
+	}
+	query, err := queryFrom(ctx, board)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := s.FindTodos(query)
+	if err != nil {
+		return nil, err
+	}
+	return filterTasks(s, ctx, tasks)
+}
+
+func (c *ExportCommand) todoTxt(s store.Store, ctx climax.Context, w io.Writer, args []string) error {
+	tasks, err := selectTasks(s, ctx, args)
+	if err != nil {
+		return err
+	}
+	return exchange.ExportTodoTxt(s, w, tasks)
+}
+
+func (c *ExportCommand) ical(s store.Store, ctx climax.Context, w io.Writer, args []string) error {
+	tasks, err := selectTasks(s, ctx, args)
+	if err != nil {
+		return err
+	}
+	name := "todoman"
+	if len(args) == 1 {
+		name = args[0]
+	}
+	return exchange.ExportICal(w, name, tasks)
+}
+
+// report builds the report of the board given in the arguments
+func (c *ExportCommand) report(s store.Store, ctx climax.Context, args []string) (*exchange.Report, error) {
+	if len(args) != 1 {
+		return nil, errors.New("a report needs exactly one board")
+	}
+	board, err := findBoard(s, args[0])
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := selectTasks(s, ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return exchange.NewReport(board, tasks), nil
+}
+
+func (c *ExportCommand) markdown(s store.Store, ctx climax.Context, w io.Writer, args []string) error {
+	report, err := c.report(s, ctx, args)
+	if err != nil {
+		return err
+	}
+	return exchange.ExportMarkdown(w, filepath.Join(config.Dir(), "templates"), report)
+}
+
+func (c *ExportCommand) html(s store.Store, ctx climax.Context, w io.Writer, args []string) error {
+	report, err := c.report(s, ctx, args)
+	if err != nil {
+		return err
+	}
+	return exchange.ExportHTML(w, filepath.Join(config.Dir(), "templates"), report)
+}
+
+func (c *ExportCommand) json(s store.Store, ctx climax.Context, w io.Writer, args []string) error {
+	tasks, err := selectTasks(s, ctx, args)
+	if err != nil {
+		return err
+	}
+	return exchange.ExportJSON(s, w, tasks)
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+	"time"
+
+	"github.com/chordflower/todoman/internal/config"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/gofrs/uuid"
+	"github.com/tucnak/climax"
+)
+
+// taskFilter decides if a task should be shown by a list command
+type taskFilter func(task model.Task) bool
+
+// filterFlags are the flags understood by every command that lists todos
+var filterFlags = []climax.Flag{
+	{
+		Name:     "tag",
+		Short:    "t",
+		Usage:    `--tag="tag1,tag2"`,
+		Help:     "Only shows the todos that have all of the given tags",
+		Variable: true,
+	},
+	{
+		Name:  "overdue",
+		Short: "o",
+		Usage: "--overdue",
+		Help:  "Only shows the unfinished todos whose due date has passed",
+	},
+	{
+		Name:     "soon",
+		Short:    "s",
+		Usage:    `--soon="3d"`,
+		Help:     "Only shows the unfinished todos that are due within the given duration",
+		Variable: true,
+	},
+	{
+		Name:     "assignee",
+		Short:    "u",
+		Usage:    `--assignee="jane,joe"`,
+		Help:     "Only shows the todos assigned to all of the given members",
+		Variable: true,
+	},
+	{
+		Name:  "mine",
+		Short: "m",
+		Usage: "--mine",
+		Help:  "Only shows the todos assigned to the current member",
+	},
+}
+
+// queryFrom builds the query of the todos of the board with the given id, or of every board for the nil id, with the
+// flags of the given context that the store looks up itself
+func queryFrom(ctx climax.Context, board uuid.UUID) (*store.TodoQuery, error) {
+	query := &store.TodoQuery{Board: board, Now: time.Now(), Overdue: ctx.Is("overdue")}
+	if value, ok := ctx.Get("soon"); ok {
+		within, err := model.ParseDuration(value)
+		if err != nil {
+			return nil, err
+		}
+		query.Soon = within
+	}
+	return query, nil
+}
+
+// filtersFrom builds the filters given by the other flags of the given context, for the todos of the given store
+func filtersFrom(s store.Store, ctx climax.Context) ([]taskFilter, error) {
+	filters := make([]taskFilter, 0)
+	if value, ok := ctx.Get("tag"); ok {
+		tags := strings.Split(value, ",")
+		filters = append(filters, func(task model.Task) bool {
+			for _, tag := range tags {
+				if !task.Base().HasTag(strings.TrimSpace(tag)) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+	assignees := make([]string, 0)
+	if value, ok := ctx.Get("assignee"); ok {
+		for _, ref := range strings.Split(value, ",") {
+			ref = strings.TrimSpace(ref)
+			if user, err := store.FindUser(s, ref); err == nil {
+				ref = user.Name
+			}
+			assignees = append(assignees, ref)
+		}
+	}
+	if ctx.Is("mine") {
+		cfg, err := config.Load()
+		if err != nil {
+			return nil, err
+		}
+		assignees = append(assignees, currentMember(s, cfg))
+	}
+	if len(assignees) > 0 {
+		filters = append(filters, func(task model.Task) bool {
+			for _, name := range assignees {
+				if !task.Base().IsAssigned(name) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+	return filters, nil
+}
+
+// filterTasks returns the given tasks of the given store that pass all of the filters given by the other flags of the
+// given context
+func filterTasks(s store.Store, ctx climax.Context, tasks []model.Task) ([]model.Task, error) {
+	filters, err := filtersFrom(s, ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]model.Task, 0, len(tasks))
+	for _, task := range tasks {
+		keep := true
+		for _, filter := range filters {
+			if !filter(task) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			ret = append(ret, task)
+		}
+	}
+	return ret, nil
+}
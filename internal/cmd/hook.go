@@ -0,0 +1,173 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/config"
+	"github.com/chordflower/todoman/internal/hook"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/chordflower/todoman/internal/utils"
+	"github.com/gofrs/uuid"
+	"github.com/tucnak/climax"
+)
+
+// hookAction is an action of the hook command
+type hookAction func(ctx climax.Context, cfg *config.Config, args []string) error
+
+// HookCommand shows the hooks that receive the changes of the repository
+type HookCommand struct {
+	actions map[string]hookAction
+}
+
+// NewHookCommand creates a new hook command
+func NewHookCommand() *HookCommand {
+	c := &HookCommand{}
+	c.actions = map[string]hookAction{
+		"list": c.list,
+		"log":  c.log,
+		"test": c.test,
+	}
+	return c
+}
+
+// Name returns the name of this command
+func (c *HookCommand) Name() string {
+	return "hook"
+}
+
+// Configure returns the climax definition of this command
+func (c *HookCommand) Configure() *climax.Command {
+	return &climax.Command{
+		Name:  c.Name(),
+		Brief: "show the hooks of the repository changes",
+		Usage: "list | log [--limit=20] | test <hook>",
+		Help: `The hooks are defined in the hooks.json file of the configuration directory,
+as a list of objects with a name, the events they receive, like todo.created,
+todo.* or *, and either a command that receives the event json on its input or
+an url the event json is posted to. Failed deliveries are retried, 2 times by
+default or as many as the retries of the hook, and every attempt is kept in the
+delivery log of the repository. The events are delivered when the command that
+made the changes is done with the repository, so a hook can run todoman too.
+The events are board.created, board.updated, board.deleted, board.archived,
+board.restored, board.recovered, todo.created, todo.updated,
+todo.status_changed, todo.moved, todo.deleted, todo.archived, todo.restored,
+todo.recovered, note.added, effort.logged, tag.created, tag.updated,
+tag.deleted, user.created, user.updated, user.deleted, milestone.created,
+milestone.updated and milestone.deleted.`,
+		Flags: []climax.Flag{
+			{
+				Name:     "limit",
+				Short:    "n",
+				Usage:    `--limit=20`,
+				Help:     "How many deliveries the log shows, 20 by default",
+				Variable: true,
+			},
+		},
+		Examples: []climax.Example{
+			{
+				Usecase:     "test notify",
+				Description: "Sends a test event to the notify hook",
+			},
+		},
+		Handle: c.Run,
+	}
+}
+
+// Run executes this command
+func (c *HookCommand) Run(ctx climax.Context) int {
+	if len(ctx.Args) == 0 {
+		return fail(errors.New("usage: hook <action> [<arguments>]"))
+	}
+	action, ok := c.actions[ctx.Args[0]]
+	if !ok {
+		return fail(errors.Errorf("unknown hook action %q", ctx.Args[0]))
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fail(err)
+	}
+	if err := action(ctx, cfg, ctx.Args[1:]); err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+func (c *HookCommand) list(ctx climax.Context, cfg *config.Config, args []string) error {
+	hooks, err := hook.Load(config.Dir())
+	if err != nil {
+		return err
+	}
+	for _, h := range hooks {
+		target := h.URL
+		if h.Command != "" {
+			target = strings.Join(append([]string{h.Command}, h.Args...), " ")
+		}
+		fmt.Printf("%-16s  %-30s  %s\n", h.Name, strings.Join(h.Events, ","), target)
+	}
+	return nil
+}
+
+func (c *HookCommand) log(ctx climax.Context, cfg *config.Config, args []string) error {
+	limit := 20
+	if value, ok := ctx.Get("limit"); ok {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			return errors.Errorf("invalid limit %q", value)
+		}
+	}
+	deliveries, err := hook.ReadLog(cfg.Repository)
+	if err != nil {
+		return err
+	}
+	if len(deliveries) > limit {
+		deliveries = deliveries[len(deliveries)-limit:]
+	}
+	for _, d := range deliveries {
+		state := utils.Colour("ok    ", color.RGBA{G: 200, A: 255})
+		if !d.Success {
+			state = utils.Colour("failed", color.RGBA{R: 255, A: 255})
+		}
+		fmt.Printf("%s  %s  %-16s  %-20s  #%d  %-8s  %s\n", d.Time.Local().Format("2006-01-02 15:04:05"), state,
+			d.Hook, d.Type, d.Attempt, d.Result, d.Error)
+	}
+	return nil
+}
+
+func (c *HookCommand) test(ctx climax.Context, cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: hook test <hook>")
+	}
+	hooks, err := hook.Load(config.Dir())
+	if err != nil {
+		return err
+	}
+	for _, h := range hooks {
+		if h.Name == args[0] {
+			event := store.NewEvent("hook.test", uuid.Nil, uuid.Nil, map[string]string{"hook": h.Name})
+			if err := hook.NewDispatcher(hooks, cfg.Repository).Deliver(h, event); err != nil {
+				return err
+			}
+			utils.Info("Delivered a test event to %s", h.Name)
+			return nil
+		}
+	}
+	return errors.Errorf("unknown hook %q", args[0])
+}
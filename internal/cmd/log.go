@@ -0,0 +1,181 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/config"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/tucnak/climax"
+)
+
+// maxValueWidth is the number of characters shown of the values of a changed field
+const maxValueWidth = 60
+
+// LogCommand shows who changed the repository and when
+type LogCommand struct{}
+
+// NewLogCommand creates a new log command
+func NewLogCommand() *LogCommand {
+	return &LogCommand{}
+}
+
+// Name returns the name of this command
+func (c *LogCommand) Name() string {
+	return "log"
+}
+
+// Configure returns the climax definition of this command
+func (c *LogCommand) Configure() *climax.Command {
+	return &climax.Command{
+		Name:  c.Name(),
+		Brief: "show who changed what and when",
+		Usage: `[<model>] [--since="7d"] [--author="name"]`,
+		Help: `Shows the audit log of the repository, from the oldest to the newest change,
+with who made each change, the command that made it and the fields it changed.
+The changes are made by the user of the configuration, or the TODOMAN_USER
+variable, and by the login name otherwise. The log can be narrowed to a board,
+todo or tag, referenced by a prefix of its id or a part of its name, even when
+it no longer exists. The changes are kept in the log even after they are
+undone, and undoing them is logged too.`,
+		Flags: []climax.Flag{
+			{
+				Name:     "since",
+				Short:    "s",
+				Usage:    `--since="7d"`,
+				Help:     "Only the changes since the given date, or the given time ago",
+				Variable: true,
+			},
+			{
+				Name:     "author",
+				Short:    "a",
+				Usage:    `--author="name"`,
+				Help:     "Only the changes made by the given user",
+				Variable: true,
+			},
+		},
+		Examples: []climax.Example{
+			{
+				Usecase:     `"write tests" --since=2022-10-01`,
+				Description: "Shows the changes of the todo write tests since October",
+			},
+		},
+		Handle: c.Run,
+	}
+}
+
+// Run executes this command
+func (c *LogCommand) Run(ctx climax.Context) int {
+	if len(ctx.Args) > 1 {
+		return fail(errors.New(`usage: log [<model>] [--since="7d"] [--author="name"]`))
+	}
+	since := time.Time{}
+	if value, ok := ctx.Get("since"); ok {
+		var err error
+		if since, err = parseSince(value); err != nil {
+			return fail(err)
+		}
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fail(err)
+	}
+	entries, err := store.ReadAudit(filepath.Join(cfg.Repository, store.AuditFile))
+	if err != nil {
+		return fail(err)
+	}
+	author, _ := ctx.Get("author")
+	for _, entry := range entries {
+		if entry.Time.Before(since) || (author != "" && !strings.EqualFold(entry.Actor, author)) {
+			continue
+		}
+		if len(ctx.Args) == 1 && !matchesModel(entry, ctx.Args[0]) {
+			continue
+		}
+		printEntry(entry)
+	}
+	return 0
+}
+
+// parseSince parses the start of the log, either as a date or as a duration before now
+func parseSince(value string) (time.Time, error) {
+	if day, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return day, nil
+	}
+	if duration, err := model.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	date, err := model.ParseDateTime(value)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid time %q, use a date like 2022-10-01 or a duration like 7d", value)
+	}
+	return date.Time(), nil
+}
+
+// matchesModel checks if the given entry changed the model referenced by a prefix of its id or a part of its name
+func matchesModel(entry *store.AuditEntry, ref string) bool {
+	ref = strings.ToLower(ref)
+	return strings.HasPrefix(entry.Model.String(), ref) || strings.Contains(strings.ToLower(entry.Name), ref)
+}
+
+// printEntry prints the given entry and the fields it changed
+func printEntry(entry *store.AuditEntry) {
+	name := entry.Name
+	if entry.Area != "" {
+		// The archived and removed todos are changed too, like by the renaming of a tag
+		name += " [" + entry.Area + "]"
+	}
+	fmt.Printf("%s  %-12s  %-20s  %-8s  %s  (%s)\n", entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Actor,
+		entry.Type, entry.Model.String()[:8], name, entry.Line)
+	fields := make([]string, 0, len(entry.Changes))
+	for field := range entry.Changes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		change := entry.Changes[field]
+		fmt.Printf("    %s: %s -> %s\n", field, formatValue(field, change.From), formatValue(field, change.To))
+	}
+}
+
+// formatValue returns the given value of the given field as shown by the log, with the names of the statuses and
+// priorities of the todos
+func formatValue(field string, value any) string {
+	if number, ok := value.(float64); ok {
+		switch field {
+		case "status":
+			return model.TodoStatus(number).Name()
+		case "priority":
+			return model.TodoPriority(number).Name()
+		}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "?"
+	}
+	text := []rune(string(data))
+	if len(text) > maxValueWidth {
+		return string(text[:maxValueWidth-3]) + "..."
+	}
+	return string(text)
+}
@@ -0,0 +1,171 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/config"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/chordflower/todoman/internal/utils"
+	"github.com/tucnak/climax"
+)
+
+// MemberCommand manages the users of the repository
+type MemberCommand struct{}
+
+// NewMemberCommand creates a new member command
+func NewMemberCommand() *MemberCommand {
+	return &MemberCommand{}
+}
+
+// Name returns the name of this command
+func (c *MemberCommand) Name() string {
+	return "member"
+}
+
+// Configure returns the climax definition of this command
+func (c *MemberCommand) Configure() *climax.Command {
+	return &climax.Command{
+		Name:  c.Name(),
+		Brief: "manage the members of the repository",
+		Usage: "add <name> | edit <member> | rm <member> | list",
+		Help: `Adds, lists, changes and removes the members of the repository, who can be
+assigned to todos with todo assign. A member is referenced by its name or its
+email, and removing a member also unassigns it from every todo, the archived
+and removed ones too. The member whose name or email is the user of the configuration, or of the TODOMAN_USER
+variable, is the current member, marked in the listing, whose todos are shown
+by todo list --mine and remind --mine.`,
+		Flags: []climax.Flag{
+			{
+				Name:     "display",
+				Short:    "n",
+				Usage:    `--display="Jane Doe"`,
+				Help:     "The display name of the member being added or edited",
+				Variable: true,
+			},
+			{
+				Name:     "email",
+				Short:    "e",
+				Usage:    `--email="jane@example.com"`,
+				Help:     "The email of the member being added or edited",
+				Variable: true,
+			},
+		},
+		Examples: []climax.Example{
+			{
+				Usecase:     `add jane --display="Jane Doe" --email="jane@example.com"`,
+				Description: "Adds jane as a member of the repository",
+			},
+		},
+		Handle: c.Run,
+	}
+}
+
+// Run executes this command
+func (c *MemberCommand) Run(ctx climax.Context) int {
+	if len(ctx.Args) == 0 {
+		return fail(errors.New("missing member action"))
+	}
+	open := openStore
+	if ctx.Args[0] == "list" {
+		open = openReader
+	}
+	s, err := open()
+	if err != nil {
+		return fail(err)
+	}
+	defer s.Close()
+	args := ctx.Args[1:]
+	display, _ := ctx.Get("display")
+	email, _ := ctx.Get("email")
+	switch ctx.Args[0] {
+	case "add":
+		if len(args) != 1 {
+			return fail(errors.New("usage: member add <name>"))
+		}
+		user := model.NewUser(args[0], display, email)
+		if err := s.SaveUser(user); err != nil {
+			return fail(err)
+		}
+		utils.Info("Added member %s", user.Name)
+	case "edit":
+		if len(args) != 1 {
+			return fail(errors.New("usage: member edit <member>"))
+		}
+		user, err := store.FindUser(s, args[0])
+		if err != nil {
+			return fail(err)
+		}
+		if ctx.Is("display") {
+			user.DisplayName = display
+		}
+		if ctx.Is("email") {
+			user.Email = email
+		}
+		if err := s.SaveUser(user); err != nil {
+			return fail(err)
+		}
+		utils.Info("Changed member %s", user.Name)
+	case "rm":
+		if len(args) != 1 {
+			return fail(errors.New("usage: member rm <member>"))
+		}
+		user, err := store.FindUser(s, args[0])
+		if err != nil {
+			return fail(err)
+		}
+		if err := store.RemoveUser(s, user.Name); err != nil {
+			return fail(err)
+		}
+		utils.Info("Removed member %s", user.Name)
+	case "list":
+		cfg, err := config.Load()
+		if err != nil {
+			return fail(err)
+		}
+		current := currentMember(s, cfg)
+		for _, user := range s.Users() {
+			mark := " "
+			if user.Name == current {
+				mark = "*"
+			}
+			fmt.Printf("%s %-16s  %-24s  %s\n", mark, user.Name, user.DisplayName, user.Email)
+		}
+	default:
+		return fail(errors.Errorf("unknown member action %q", ctx.Args[0]))
+	}
+	return 0
+}
+
+// currentMember returns the name of the member that is the user of the given configuration, or the user itself when
+// it is not a member
+func currentMember(s store.Store, cfg *config.Config) string {
+	if user, err := store.FindUser(s, cfg.User); err == nil {
+		return user.Name
+	}
+	return cfg.User
+}
+
+// formatAssignees returns the assignees of the given task, each one with an @ before its name
+func formatAssignees(task model.Task) string {
+	ret := ""
+	for _, name := range task.Base().Assignees {
+		ret += " @" + name
+	}
+	return ret
+}
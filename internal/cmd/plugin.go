@@ -0,0 +1,63 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/plugin"
+	"github.com/tucnak/climax"
+)
+
+// PluginCommand shows the plugins found on the PATH
+type PluginCommand struct{}
+
+// NewPluginCommand creates a new plugin command
+func NewPluginCommand() *PluginCommand {
+	return &PluginCommand{}
+}
+
+// Name returns the name of this command
+func (c *PluginCommand) Name() string {
+	return "plugin"
+}
+
+// Configure returns the climax definition of this command
+func (c *PluginCommand) Configure() *climax.Command {
+	return &climax.Command{
+		Name:  c.Name(),
+		Brief: "list the plugins",
+		Usage: "list",
+		Help: `Every todoman-<name> executable of the PATH can be run as todoman <name>,
+unless there is a todoman command with that name. The plugins receive their
+arguments and the standard streams of todoman, and the TODOMAN_REPOSITORY,
+TODOMAN_CONFIG, TODOMAN_BIN, TODOMAN_VERSION and TODOMAN_PLUGIN variables.
+They can read the models with "$TODOMAN_BIN export json" and write them back
+with "$TODOMAN_BIN import json".`,
+		Handle: c.Run,
+	}
+}
+
+// Run executes this command
+func (c *PluginCommand) Run(ctx climax.Context) int {
+	if len(ctx.Args) != 1 || ctx.Args[0] != "list" {
+		return fail(errors.New("usage: plugin list"))
+	}
+	for _, p := range plugin.List() {
+		fmt.Printf("%-16s  %s\n", p.Name, p.Path)
+	}
+	return 0
+}
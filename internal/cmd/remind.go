@@ -0,0 +1,192 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/config"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/tucnak/climax"
+)
+
+// reminder represents a deadline, ready to be shown as a desktop notification
+type reminder struct {
+	ID      string `json:"id"`       // The id of the todo
+	Name    string `json:"name"`     // The name of the todo
+	Board   string `json:"board"`    // The name of the board of the todo
+	DueDate string `json:"due_date"` // The due date of the todo
+	Overdue bool   `json:"overdue"`  // True if the due date has already passed
+	Summary string `json:"summary"`  // The title of the notification
+	Body    string `json:"body"`     // The text of the notification
+	Urgency string `json:"urgency"`  // The urgency of the notification, as used by notify-send
+}
+
+// RemindCommand shows the todos whose deadline has passed or is near
+type RemindCommand struct{}
+
+// NewRemindCommand creates a new remind command
+func NewRemindCommand() *RemindCommand {
+	return &RemindCommand{}
+}
+
+// Name returns the name of this command
+func (c *RemindCommand) Name() string {
+	return "remind"
+}
+
+// Configure returns the climax definition of this command
+func (c *RemindCommand) Configure() *climax.Command {
+	return &climax.Command{
+		Name:  c.Name(),
+		Brief: "show upcoming and missed deadlines",
+		Usage: `[--within="1d"] [--mine] [--format="text|json"]`,
+		Help: `Shows the unfinished todos of every board that are overdue or due soon, and
+prints nothing if there are none, so that it can be run from cron or a systemd
+timer. With --mine only the todos assigned to the current member are shown.
+The json format emits one notification per todo, with a summary, body
+and urgency that can be given to notify-send.`,
+		Flags: []climax.Flag{
+			{
+				Name:     "within",
+				Short:    "w",
+				Usage:    `--within="1d"`,
+				Help:     "How far ahead to look for deadlines, defaults to one day",
+				Variable: true,
+			},
+			{
+				Name:  "mine",
+				Short: "m",
+				Usage: "--mine",
+				Help:  "Only shows the todos assigned to the current member",
+			},
+			{
+				Name:     "format",
+				Short:    "f",
+				Usage:    `--format="text"`,
+				Help:     "The output format, either text or json",
+				Variable: true,
+			},
+		},
+		Examples: []climax.Example{
+			{
+				Usecase:     `--format=json | jq -r '.[] | [.urgency, .summary, .body] | @tsv' | while IFS="$(printf '\t')" read -r u s b; do notify-send -u "$u" "$s" "$b"; done`,
+				Description: "Shows a desktop notification for each deadline",
+			},
+		},
+		Handle: c.Run,
+	}
+}
+
+// Run executes this command
+func (c *RemindCommand) Run(ctx climax.Context) int {
+	within := 24 * time.Hour
+	if value, ok := ctx.Get("within"); ok {
+		var err error
+		if within, err = model.ParseDuration(value); err != nil {
+			return fail(err)
+		}
+	}
+	s, err := openReader()
+	if err != nil {
+		return fail(err)
+	}
+	defer s.Close()
+	overdue, soon, err := store.Upcoming(s, time.Now(), within)
+	if err != nil {
+		return fail(err)
+	}
+	if ctx.Is("mine") {
+		cfg, err := config.Load()
+		if err != nil {
+			return fail(err)
+		}
+		member := currentMember(s, cfg)
+		overdue, soon = assignedTo(overdue, member), assignedTo(soon, member)
+	}
+	reminders := make([]reminder, 0, len(overdue)+len(soon))
+	for _, task := range overdue {
+		reminders = append(reminders, newReminder(s, task, true))
+	}
+	for _, task := range soon {
+		reminders = append(reminders, newReminder(s, task, false))
+	}
+
+	format, _ := ctx.Get("format")
+	switch format {
+	case "", "text":
+		for _, r := range reminders {
+			state := "DUE"
+			if r.Overdue {
+				state = "OVERDUE"
+			}
+			fmt.Printf("%-8s %s  %s (%s)\n", state, r.DueDate, r.Name, r.Board)
+		}
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(reminders); err != nil {
+			return fail(err)
+		}
+	default:
+		return fail(errors.Errorf("unknown format %q", format))
+	}
+	return 0
+}
+
+// assignedTo returns the given tasks that are assigned to the given member
+func assignedTo(tasks []model.Task, member string) []model.Task {
+	result := make([]model.Task, 0, len(tasks))
+	for _, task := range tasks {
+		if task.Base().IsAssigned(member) {
+			result = append(result, task)
+		}
+	}
+	return result
+}
+
+// newReminder creates the reminder of the given task
+func newReminder(s store.Store, task model.Task, overdue bool) reminder {
+	t := task.Base()
+	board := ""
+	if b, err := s.BoardOf(t.ID); err == nil {
+		board = b.Name
+	}
+	due := t.DueDate.Time().Format("2006-01-02 15:04")
+	r := reminder{
+		ID:      t.ID.String(),
+		Name:    t.Name,
+		Board:   board,
+		DueDate: due,
+		Overdue: overdue,
+		Summary: "Due soon: " + t.Name,
+		Body:    fmt.Sprintf("%s is due at %s", t.Name, due),
+		Urgency: "normal",
+	}
+	if overdue {
+		r.Summary = "Overdue: " + t.Name
+		r.Body = fmt.Sprintf("%s was due at %s", t.Name, due)
+		r.Urgency = "critical"
+	}
+	if board != "" {
+		r.Body += " (" + board + ")"
+	}
+	return r
+}
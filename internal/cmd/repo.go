@@ -0,0 +1,102 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/config"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/chordflower/todoman/internal/utils"
+	"github.com/tucnak/climax"
+)
+
+// RepoCommand manages the storage of the repository
+type RepoCommand struct{}
+
+// NewRepoCommand creates a new repo command
+func NewRepoCommand() *RepoCommand {
+	return &RepoCommand{}
+}
+
+// Name returns the name of this command
+func (c *RepoCommand) Name() string {
+	return "repo"
+}
+
+// Configure returns the climax definition of this command
+func (c *RepoCommand) Configure() *climax.Command {
+	return &climax.Command{
+		Name:  c.Name(),
+		Brief: "manage the storage of the repository",
+		Usage: "convert --to=sqlite|json",
+		Help: `A repository keeps each board, todo and tag in its own json file by default,
+or everything in a single sqlite database, which suits large repositories
+better. Converting a repository copies it to the other backend, including its
+archive and trash, and switches to it once the copy is complete.`,
+		Flags: []climax.Flag{
+			{
+				Name:     "to",
+				Short:    "t",
+				Usage:    `--to=sqlite`,
+				Help:     "The backend the repository is converted to, sqlite or json",
+				Variable: true,
+			},
+		},
+		Examples: []climax.Example{
+			{
+				Usecase:     "convert --to=sqlite",
+				Description: "Moves the repository into a sqlite database",
+			},
+		},
+		Handle: c.Run,
+	}
+}
+
+// Run executes this command
+func (c *RepoCommand) Run(ctx climax.Context) int {
+	if len(ctx.Args) == 0 {
+		return fail(errors.New("missing repo action"))
+	}
+	switch ctx.Args[0] {
+	case "convert":
+		backend, ok := ctx.Get("to")
+		if !ok {
+			return fail(errors.New("usage: repo convert --to=sqlite|json"))
+		}
+		if err := c.convert(backend); err != nil {
+			return fail(err)
+		}
+		utils.Info("Converted the repository to %s", backend)
+	default:
+		return fail(errors.Errorf("unknown repo action %q", ctx.Args[0]))
+	}
+	return 0
+}
+
+// convert converts the repository to the given backend, while nothing else uses it
+func (c *RepoCommand) convert(backend string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	lock, err := store.LockRepository(cfg.Repository, true, time.Duration(cfg.LockTimeout)*time.Second)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	return store.Convert(cfg.Repository, backend)
+}
@@ -0,0 +1,205 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/api"
+	"github.com/chordflower/todoman/internal/config"
+	"github.com/chordflower/todoman/internal/exchange"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/chordflower/todoman/internal/utils"
+	"github.com/tucnak/climax"
+)
+
+// server creates the http handler of a serve mode
+type server func(ctx climax.Context) (http.Handler, error)
+
+// ServeCommand serves the repository over http
+type ServeCommand struct {
+	modes map[string]server
+}
+
+// NewServeCommand creates a new serve command
+func NewServeCommand() *ServeCommand {
+	c := &ServeCommand{}
+	c.modes = map[string]server{
+		"ics": c.ical,
+		"api": c.api,
+	}
+	return c
+}
+
+// Name returns the name of this command
+func (c *ServeCommand) Name() string {
+	return "serve"
+}
+
+// Configure returns the climax definition of this command
+func (c *ServeCommand) Configure() *climax.Command {
+	return &climax.Command{
+		Name:  c.Name(),
+		Brief: "serve the repository over http",
+		Usage: `ics | api [--listen="127.0.0.1:8080"]`,
+		Help: `Serves the repository over http until interrupted.
+The ics mode is a read only iCalendar feed, with one calendar of VTODOs per
+board at /<board>.ics, where the board is either its id or name, and an index
+of the feeds at /.
+The api mode is a json rest api to list, create, change and remove boards,
+todos, notes and efforts, described by the openapi document at /openapi.json.
+Every other request must send the token given by --token, the api_token of
+the configuration or the TODOMAN_API_TOKEN variable, as a bearer token in the
+Authorization header. Without a token a random one is created and shown.`,
+		Flags: []climax.Flag{
+			{
+				Name:     "listen",
+				Short:    "l",
+				Usage:    `--listen="127.0.0.1:8080"`,
+				Help:     "The address to listen on, defaults to 127.0.0.1:8080",
+				Variable: true,
+			},
+			{
+				Name:     "token",
+				Short:    "t",
+				Usage:    `--token="secret"`,
+				Help:     "The token the clients of the api must send",
+				Variable: true,
+			},
+		},
+		Examples: []climax.Example{
+			{
+				Usecase:     "ics --listen=127.0.0.1:9000",
+				Description: "Serves the calendar feeds on port 9000",
+			},
+			{
+				Usecase:     `api --token="secret"`,
+				Description: "Serves the rest api, which the clients use with the secret token",
+			},
+		},
+		Handle: c.Run,
+	}
+}
+
+// Run executes this command
+func (c *ServeCommand) Run(ctx climax.Context) int {
+	if len(ctx.Args) != 1 {
+		return fail(errors.New("usage: serve <mode>"))
+	}
+	mode, ok := c.modes[ctx.Args[0]]
+	if !ok {
+		return fail(errors.Errorf("unknown serve mode %q", ctx.Args[0]))
+	}
+	handler, err := mode(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	listen, ok := ctx.Get("listen")
+	if !ok {
+		listen = "127.0.0.1:8080"
+	}
+	utils.Info("Listening on http://%s", listen)
+	if err := http.ListenAndServe(listen, handler); err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+// ical serves a read only iCalendar feed per board, the repository is read again on every request so that the
+// feeds are always up to date
+func (c *ServeCommand) ical(ctx climax.Context) (http.Handler, error) {
+	s, err := openReader()
+	if err != nil {
+		return nil, err
+	}
+	s.Close()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "the feed is read only", http.StatusMethodNotAllowed)
+			return
+		}
+		s, err := openReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer s.Close()
+		if r.URL.Path == "/" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprintln(w, "<!DOCTYPE html><html><head><title>todoman</title></head><body><ul>")
+			for _, board := range s.Boards() {
+				fmt.Fprintf(w, "<li><a href=\"/%s.ics\">%s</a></li>\n", board.ID, html.EscapeString(board.Name))
+			}
+			fmt.Fprintln(w, "</ul></body></html>")
+			return
+		}
+		ref := strings.TrimPrefix(r.URL.Path, "/")
+		if !strings.HasSuffix(ref, ".ics") {
+			http.NotFound(w, r)
+			return
+		}
+		board, err := findBoard(s, strings.TrimSuffix(ref, ".ics"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		tasks, err := s.Todos(board.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		if err := exchange.ExportICal(w, board.Name, tasks); err != nil {
+			utils.Error("Unable to write the feed of %s: %s", board.Name, err)
+		}
+	}), nil
+}
+
+// api serves the json rest api of the repository
+func (c *ServeCommand) api(ctx climax.Context) (http.Handler, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	s, err := openReader()
+	if err != nil {
+		return nil, err
+	}
+	s.Close()
+	token, ok := ctx.Get("token")
+	if !ok {
+		token = cfg.APIToken
+	}
+	if token == "" {
+		random := make([]byte, 16)
+		if _, err := rand.Read(random); err != nil {
+			return nil, errors.Wrap(err, "unable to create a token")
+		}
+		token = hex.EncodeToString(random)
+		utils.Info("Using the token %s", token)
+	}
+	return api.NewServer(func(write bool) (store.Store, error) {
+		if write {
+			return openStore()
+		}
+		return openReader()
+	}, token), nil
+}
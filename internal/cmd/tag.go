@@ -0,0 +1,135 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/chordflower/todoman/internal/utils"
+	"github.com/tucnak/climax"
+)
+
+// TagCommand manages the tags of the repository
+type TagCommand struct{}
+
+// NewTagCommand creates a new tag command
+func NewTagCommand() *TagCommand {
+	return &TagCommand{}
+}
+
+// Name returns the name of this command
+func (c *TagCommand) Name() string {
+	return "tag"
+}
+
+// Configure returns the climax definition of this command
+func (c *TagCommand) Configure() *climax.Command {
+	return &climax.Command{
+		Name:  c.Name(),
+		Brief: "manage the tags",
+		Usage: "add <name> | rm <tag> | list | rename <tag> <new>",
+		Help: `Creates, lists, renames and removes tags, renaming or removing a tag also
+changes every todo that has it, the archived and removed ones too. Use todo tag
+and todo untag to attach tags.`,
+		Flags: []climax.Flag{
+			{
+				Name:     "colour",
+				Short:    "c",
+				Usage:    `--colour="(r,g,b,a)"`,
+				Help:     "The colour of the tag being added",
+				Variable: true,
+			},
+		},
+		Examples: []climax.Example{
+			{
+				Usecase:     `add urgent --colour="(255,0,0,255)"`,
+				Description: "Creates a red tag named urgent",
+			},
+			{
+				Usecase:     `rename urgent asap`,
+				Description: "Renames the urgent tag in every todo",
+			},
+		},
+		Handle: c.Run,
+	}
+}
+
+// Run executes this command
+func (c *TagCommand) Run(ctx climax.Context) int {
+	if len(ctx.Args) == 0 {
+		return fail(errors.New("missing tag action"))
+	}
+	open := openStore
+	if ctx.Args[0] == "list" {
+		open = openReader
+	}
+	s, err := open()
+	if err != nil {
+		return fail(err)
+	}
+	defer s.Close()
+	args := ctx.Args[1:]
+	switch ctx.Args[0] {
+	case "add":
+		if len(args) != 1 {
+			return fail(errors.New("usage: tag add <name>"))
+		}
+		colour, _ := ctx.Get("colour")
+		tag := model.NewTag2(args[0], colour)
+		if err := s.SaveTag(tag); err != nil {
+			return fail(err)
+		}
+		utils.Info("Created tag %s", tag.Name)
+	case "rm":
+		if len(args) != 1 {
+			return fail(errors.New("usage: tag rm <tag>"))
+		}
+		if err := store.RemoveTag(s, args[0]); err != nil {
+			return fail(err)
+		}
+		utils.Info("Removed tag %s", args[0])
+	case "list":
+		for _, tag := range s.Tags() {
+			fmt.Printf("%-17s  %s\n", tag.ColourToString(), utils.Colour(tag.Name, tag.Colour))
+		}
+	case "rename":
+		if len(args) != 2 {
+			return fail(errors.New("usage: tag rename <tag> <new>"))
+		}
+		if err := store.RenameTag(s, args[0], args[1]); err != nil {
+			return fail(err)
+		}
+		utils.Info("Renamed tag %s to %s", args[0], args[1])
+	default:
+		return fail(errors.Errorf("unknown tag action %q", ctx.Args[0]))
+	}
+	return 0
+}
+
+// formatTags returns the tags of the given task, each one with its colour
+func formatTags(s store.Store, task model.Task) string {
+	ret := ""
+	for _, name := range task.Base().Tags {
+		text := "#" + name
+		if tag, err := s.Tag(name); err == nil {
+			text = utils.Colour(text, tag.Colour)
+		}
+		ret += " " + text
+	}
+	return ret
+}
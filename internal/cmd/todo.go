@@ -0,0 +1,813 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/config"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/chordflower/todoman/internal/utils"
+	"github.com/tucnak/climax"
+)
+
+// readActions are the actions of the todo command that only read the todos, which can also look at the archived ones
+var readActions = map[string]bool{"list": true, "show": true, "notes": true, "deps": true}
+
+// todoAction represents an action of the todo command, receiving the arguments after the action name
+type todoAction func(s store.Store, ctx climax.Context, args []string) error
+
+// TodoCommand manages the todos of the boards
+type TodoCommand struct {
+	actions map[string]todoAction
+}
+
+// NewTodoCommand creates a new todo command
+func NewTodoCommand() *TodoCommand {
+	c := &TodoCommand{}
+	c.actions = map[string]todoAction{
+		"add":      c.add,
+		"list":     c.list,
+		"show":     c.show,
+		"rm":       c.remove,
+		"status":   c.status,
+		"depend":   c.depend,
+		"undepend": c.undepend,
+		"deps":     c.deps,
+		"subtask":  c.subtask,
+		"item":     c.item,
+		"check":    c.check,
+		"uncheck":  c.uncheck,
+		"tag":      c.tag,
+		"untag":    c.untag,
+		"assign":   c.assign,
+		"unassign": c.unassign,
+		"due":      c.due,
+		"repeat":   c.repeat,
+		"move":     c.move,
+		"copy":     c.copy,
+		"note":     c.note,
+		"notes":    c.notes,
+		"unnote":   c.unnote,
+		"archive":  c.archive,
+		"restore":  c.restore,
+	}
+	return c
+}
+
+// Name returns the name of this command
+func (c *TodoCommand) Name() string {
+	return "todo"
+}
+
+// Configure returns the climax definition of this command
+func (c *TodoCommand) Configure() *climax.Command {
+	return &climax.Command{
+		Name:  c.Name(),
+		Brief: "manage the todos of a board",
+		Usage: "add <board> <name> | list [<board>] | show <todo> | rm <todo> | status <todo> <status> | depend <todo> <on> | undepend <todo> <on> | deps <todo> | subtask <todo> <name> | item <todo> <text> | check <todo> <n> | uncheck <todo> <n> | tag <todo> <tag> | untag <todo> <tag> | assign <todo> <member>... | unassign <todo> <member> | due <todo> <date|none> | repeat <todo> <rule|none> | note <todo> <text> | notes <todo> | unnote <todo> <note> | move <todo> [--to=<board>] [--before=<todo>|--after=<todo>|--top] | copy <todo> [--to=<board>] | archive <todo> | restore <todo>",
+		Help: `Creates, lists, changes and removes todos. A todo, like a board or a note, can be
+referenced by its id, the short id shown by the listings or any other unique
+prefix of its id, its name, or a part of its name when only one todo has it.
+The status is one of new, started, paused, finished or done, a todo can only be
+started after all of the todos it depends on are finished or done.
+A todo can be broken down into subtasks, which are full todos of the same board,
+and checklist items, which are numbered from 1 and can only be checked or
+unchecked, the progress of a todo is computed from both.
+A todo can be assigned to any number of the members of the repository, which
+are referenced by their name or email.
+Dates are given as YYYY-MM-DD, YYYY-MM-DD HH:mm, today or tomorrow, listing
+without a board shows the todos of every board.
+The todos of a board are listed in their order, the new todos go last and move
+places a todo before or after another todo of its board or at the top.
+Moving a todo to another board takes its subtasks along, and copying a todo
+copies its subtasks, notes, efforts and dates into new todos.
+A repeat rule is either daily, weekly, monthly, yearly or an RRULE using FREQ,
+INTERVAL, BYDAY and UNTIL, when a repeating todo is done its next occurrence
+is created in the same board.
+An archived todo and its subtasks are moved to the archive of the repository,
+where list, show, notes and deps only find them with --archived, until they are
+restored to their board. The done todos are archived on their own after the
+archive_after days of the configuration, when it is set.`,
+		Flags: append([]climax.Flag{
+			{
+				Name:  "agile",
+				Short: "a",
+				Usage: "--agile",
+				Help:  "Creates an agile todo",
+			},
+			{
+				Name:     "priority",
+				Short:    "p",
+				Usage:    `--priority="normal"`,
+				Help:     "The priority of the todo being added, from lowest to highest",
+				Variable: true,
+			},
+			{
+				Name:     "description",
+				Short:    "d",
+				Usage:    `--description="text"`,
+				Help:     "The description of the todo being added",
+				Variable: true,
+			},
+			{
+				Name:     "due",
+				Usage:    `--due="2022-12-31"`,
+				Help:     "The due date of the todo being added",
+				Variable: true,
+			},
+			{
+				Name:     "repeat",
+				Short:    "r",
+				Usage:    `--repeat="FREQ=WEEKLY;BYDAY=MO"`,
+				Help:     "The recurrence rule of the todo being added",
+				Variable: true,
+			},
+			{
+				Name:     "before",
+				Short:    "B",
+				Usage:    `--before="todo"`,
+				Help:     "Moves the todo right before the given todo",
+				Variable: true,
+			},
+			{
+				Name:     "after",
+				Short:    "A",
+				Usage:    `--after="todo"`,
+				Help:     "Moves the todo right after the given todo",
+				Variable: true,
+			},
+			{
+				Name:  "top",
+				Usage: "--top",
+				Help:  "Moves the todo to the top of its board",
+			},
+			{
+				Name:     "to",
+				Usage:    `--to="board"`,
+				Help:     "The board a todo is moved or copied to",
+				Variable: true,
+			},
+			{
+				Name:  "archived",
+				Usage: "--archived",
+				Help:  "Looks for the todos in the archive instead",
+			},
+		}, filterFlags...),
+		Examples: []climax.Example{
+			{
+				Usecase:     `list --overdue`,
+				Description: "Lists the overdue todos of every board",
+			},
+			{
+				Usecase:     `list work --tag="urgent"`,
+				Description: "Lists the todos of the work board tagged as urgent",
+			},
+			{
+				Usecase:     `depend "deploy" "write tests"`,
+				Description: "Makes deploy wait until write tests is finished",
+			},
+			{
+				Usecase:     `deps "deploy"`,
+				Description: "Shows the dependency tree of deploy",
+			},
+		},
+		Handle: c.Run,
+	}
+}
+
+// Run executes this command
+func (c *TodoCommand) Run(ctx climax.Context) int {
+	if len(ctx.Args) == 0 {
+		return fail(errors.New("missing todo action"))
+	}
+	action, ok := c.actions[ctx.Args[0]]
+	if !ok {
+		return fail(errors.Errorf("unknown todo action %q", ctx.Args[0]))
+	}
+	open := openStore
+	if readActions[ctx.Args[0]] {
+		open = openReader
+	}
+	s, err := open()
+	if err != nil {
+		return fail(err)
+	}
+	defer s.Close()
+	if ctx.Is("archived") {
+		if !readActions[ctx.Args[0]] {
+			return fail(errors.Errorf("the todo action %q can not be used with --archived", ctx.Args[0]))
+		}
+		archive, err := s.Archive()
+		if err != nil {
+			return fail(err)
+		}
+		defer archive.Close()
+		s = archive
+	}
+	if err := action(s, ctx, ctx.Args[1:]); err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+// printTodo prints a todo as a single line
+func printTodo(s store.Store, task model.Task) {
+	t := task.Base()
+	due := ""
+	if !t.DueDate.IsZero() {
+		due = " (due " + t.DueDate.Time().Format("2006-01-02 15:04") + ")"
+		if t.IsOverdue(time.Now()) {
+			due = utils.Colour(due, color.RGBA{R: 255, A: 255})
+		}
+	}
+	fmt.Printf("%-8s  %-8s  %-8s  %s%s%s%s\n", s.TodoIndex().ShortID(t.ID), t.Status.Name(), t.Priority.Name(), t.Name,
+		due, formatTags(s, task), formatAssignees(task))
+}
+
+func (c *TodoCommand) add(s store.Store, ctx climax.Context, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: todo add <board> <name>")
+	}
+	board, err := findBoard(s, args[0])
+	if err != nil {
+		return err
+	}
+	var task model.Task = model.NewTodo(args[1])
+	if ctx.Is("agile") {
+		task = model.NewAgileTodo(args[1])
+	}
+	if value, ok := ctx.Get("priority"); ok {
+		priority, err := model.ParseTodoPriority(value)
+		if err != nil {
+			return err
+		}
+		task.Base().Priority = priority
+	}
+	task.Base().Description, _ = ctx.Get("description")
+	if value, ok := ctx.Get("due"); ok {
+		due, err := model.ParseDateTime(value)
+		if err != nil {
+			return err
+		}
+		task.Base().DueDate = due
+	}
+	if value, ok := ctx.Get("repeat"); ok {
+		rule, err := model.ParseRecurrence(value)
+		if err != nil {
+			return err
+		}
+		task.Base().Recurrence = rule
+	}
+	if err := s.SaveTodo(board.ID, task); err != nil {
+		return err
+	}
+	utils.Info("Created todo %s (%s)", task.Base().Name, task.Base().ID)
+	return nil
+}
+
+func (c *TodoCommand) list(s store.Store, ctx climax.Context, args []string) error {
+	todos, err := selectTasks(s, ctx, args)
+	if err != nil {
+		return err
+	}
+	if ctx.Is("overdue") || ctx.Is("soon") {
+		store.SortByDueDate(todos)
+	}
+	for _, task := range todos {
+		printTodo(s, task)
+	}
+	return nil
+}
+
+func (c *TodoCommand) show(s store.Store, ctx climax.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: todo show <todo>")
+	}
+	task, err := findTodo(s, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(task.String())
+	if task.Base().Recurrence != nil {
+		fmt.Printf("Repeats: %s\n", task.Base().Recurrence)
+	}
+	done, total, err := store.Progress(s, task.Base().ID)
+	if err != nil {
+		return err
+	}
+	if total > 0 {
+		fmt.Printf("Progress: %d/%d (%d%%)\n", done, total, done*100/total)
+	}
+	subtasks := store.Subtasks(s, task.Base().ID)
+	if len(subtasks) > 0 {
+		fmt.Println("Subtasks:")
+		for _, sub := range subtasks {
+			printTodo(s, sub)
+		}
+	}
+	if len(task.Base().Checklist) > 0 {
+		fmt.Println("Checklist:")
+		for i, item := range task.Base().Checklist {
+			fmt.Printf("%3d %s\n", i+1, item)
+		}
+	}
+	return nil
+}
+
+func (c *TodoCommand) remove(s store.Store, ctx climax.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: todo rm <todo>")
+	}
+	task, err := findTodo(s, args[0])
+	if err != nil {
+		return err
+	}
+	if err := store.RemoveTodoTree(s, task.Base().ID); err != nil {
+		return err
+	}
+	utils.Info("Removed todo %s", task.Base().Name)
+	return nil
+}
+
+func (c *TodoCommand) status(s store.Store, ctx climax.Context, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: todo status <todo> <status>")
+	}
+	task, err := findTodo(s, args[0])
+	if err != nil {
+		return err
+	}
+	status, err := model.ParseTodoStatus(args[1])
+	if err != nil {
+		return err
+	}
+	next, err := store.SetStatus(s, task.Base().ID, status)
+	if err != nil {
+		return err
+	}
+	utils.Info("Todo %s is now %s", task.Base().Name, status.Name())
+	if next != nil {
+		utils.Info("Created the next occurrence %s (%s) starting at %s", next.Base().Name, next.Base().ID,
+			next.Base().StartDate.Time().Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+// findTodoPair finds the two todos of a dependency action
+func findTodoPair(s store.Store, args []string, usage string) (model.Task, model.Task, error) {
+	if len(args) != 2 {
+		return nil, nil, errors.New("usage: " + usage)
+	}
+	task, err := findTodo(s, args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	other, err := findTodo(s, args[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	return task, other, nil
+}
+
+func (c *TodoCommand) depend(s store.Store, ctx climax.Context, args []string) error {
+	task, other, err := findTodoPair(s, args, "todo depend <todo> <on>")
+	if err != nil {
+		return err
+	}
+	if err := store.AddDependency(s, task.Base().ID, other.Base().ID); err != nil {
+		return err
+	}
+	utils.Info("Todo %s now depends on %s", task.Base().Name, other.Base().Name)
+	return nil
+}
+
+func (c *TodoCommand) undepend(s store.Store, ctx climax.Context, args []string) error {
+	task, other, err := findTodoPair(s, args, "todo undepend <todo> <on>")
+	if err != nil {
+		return err
+	}
+	if err := store.RemoveDependency(s, task.Base().ID, other.Base().ID); err != nil {
+		return err
+	}
+	utils.Info("Todo %s no longer depends on %s", task.Base().Name, other.Base().Name)
+	return nil
+}
+
+func (c *TodoCommand) deps(s store.Store, ctx climax.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: todo deps <todo>")
+	}
+	task, err := findTodo(s, args[0])
+	if err != nil {
+		return err
+	}
+	graph := store.DependencyGraph(s)
+	printDependencyNode(s, graph.Tree(task.Base().ID), "", "")
+	blocks := graph.Blocks(task.Base().ID)
+	if len(blocks) > 0 {
+		fmt.Println("Blocks:")
+		for _, t := range blocks {
+			fmt.Printf("  %s\n", describeDependency(s, t))
+		}
+	}
+	return nil
+}
+
+// describeDependency returns the name, status and board of a todo in a dependency tree
+func describeDependency(s store.Store, t *model.Todo) string {
+	board := "?"
+	if b, err := s.BoardOf(t.ID); err == nil {
+		board = b.Name
+	}
+	return fmt.Sprintf("%s [%s] (%s)", t.Name, t.Status.Name(), board)
+}
+
+// printDependencyNode prints the given dependency tree, one todo per line
+func printDependencyNode(s store.Store, node *model.DependencyNode, prefix, childPrefix string) {
+	line := prefix + describeDependency(s, node.Todo)
+	if node.Repeated {
+		line += " ..."
+	}
+	fmt.Println(line)
+	for i, child := range node.DependsOn {
+		if i == len(node.DependsOn)-1 {
+			printDependencyNode(s, child, childPrefix+"└── ", childPrefix+"    ")
+		} else {
+			printDependencyNode(s, child, childPrefix+"├── ", childPrefix+"│   ")
+		}
+	}
+}
+
+func (c *TodoCommand) subtask(s store.Store, ctx climax.Context, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: todo subtask <todo> <name>")
+	}
+	parent, err := findTodo(s, args[0])
+	if err != nil {
+		return err
+	}
+	sub := model.NewSubtask(args[1], parent.Base())
+	if err := store.AddSubtask(s, parent.Base().ID, sub); err != nil {
+		return err
+	}
+	utils.Info("Created subtask %s (%s) of %s", sub.Name, sub.ID, parent.Base().Name)
+	return nil
+}
+
+func (c *TodoCommand) item(s store.Store, ctx climax.Context, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: todo item <todo> <text>")
+	}
+	task, err := findTodo(s, args[0])
+	if err != nil {
+		return err
+	}
+	task.Base().AddChecklistItem(args[1])
+	if err := store.SaveTask(s, task); err != nil {
+		return err
+	}
+	utils.Info("Added checklist item %d to %s", len(task.Base().Checklist), task.Base().Name)
+	return nil
+}
+
+func (c *TodoCommand) check(s store.Store, ctx climax.Context, args []string) error {
+	return c.setChecked(s, args, true)
+}
+
+func (c *TodoCommand) uncheck(s store.Store, ctx climax.Context, args []string) error {
+	return c.setChecked(s, args, false)
+}
+
+// setChecked checks or unchecks the checklist item given in the arguments
+func (c *TodoCommand) setChecked(s store.Store, args []string, checked bool) error {
+	if len(args) != 2 {
+		return errors.New("usage: todo check|uncheck <todo> <n>")
+	}
+	task, err := findTodo(s, args[0])
+	if err != nil {
+		return err
+	}
+	position, err := strconv.Atoi(args[1])
+	if err != nil {
+		return errors.Errorf("invalid checklist item %q", args[1])
+	}
+	if err := task.Base().CheckItem(position, checked); err != nil {
+		return err
+	}
+	return store.SaveTask(s, task)
+}
+
+func (c *TodoCommand) tag(s store.Store, ctx climax.Context, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: todo tag <todo> <tag>")
+	}
+	task, err := findTodo(s, args[0])
+	if err != nil {
+		return err
+	}
+	return store.TagTodo(s, task, args[1])
+}
+
+func (c *TodoCommand) untag(s store.Store, ctx climax.Context, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: todo untag <todo> <tag>")
+	}
+	task, err := findTodo(s, args[0])
+	if err != nil {
+		return err
+	}
+	task.Base().RemoveTag(args[1])
+	return store.SaveTask(s, task)
+}
+
+func (c *TodoCommand) assign(s store.Store, ctx climax.Context, args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: todo assign <todo> <member>...")
+	}
+	task, err := findTodo(s, args[0])
+	if err != nil {
+		return err
+	}
+	return store.AssignTodo(s, task, args[1:]...)
+}
+
+func (c *TodoCommand) unassign(s store.Store, ctx climax.Context, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: todo unassign <todo> <member>")
+	}
+	task, err := findTodo(s, args[0])
+	if err != nil {
+		return err
+	}
+	name := args[1]
+	if user, err := store.FindUser(s, name); err == nil {
+		name = user.Name
+	}
+	if !task.Base().IsAssigned(name) {
+		return errors.Errorf("the todo %q is not assigned to %q", task.Base().Name, name)
+	}
+	task.Base().Unassign(name)
+	return store.SaveTask(s, task)
+}
+
+func (c *TodoCommand) due(s store.Store, ctx climax.Context, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: todo due <todo> <date|none>")
+	}
+	task, err := findTodo(s, args[0])
+	if err != nil {
+		return err
+	}
+	due := model.DateTime{}
+	if args[1] != "none" {
+		if due, err = model.ParseDateTime(args[1]); err != nil {
+			return err
+		}
+	}
+	task.Base().DueDate = due
+	return store.SaveTask(s, task)
+}
+
+func (c *TodoCommand) repeat(s store.Store, ctx climax.Context, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: todo repeat <todo> <rule|none>")
+	}
+	task, err := findTodo(s, args[0])
+	if err != nil {
+		return err
+	}
+	var rule *model.Recurrence
+	if args[1] != "none" {
+		if rule, err = model.ParseRecurrence(args[1]); err != nil {
+			return err
+		}
+	}
+	task.Base().Recurrence = rule
+	return store.SaveTask(s, task)
+}
+
+func (c *TodoCommand) note(s store.Store, ctx climax.Context, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: todo note <todo> <text>")
+	}
+	task, err := findTodo(s, args[0])
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	note := model.NewNote(args[1], cfg.User)
+	if err := note.Validate(); err != nil {
+		return err
+	}
+	task.Base().AddNote(note)
+	if err := store.SaveTask(s, task); err != nil {
+		return err
+	}
+	utils.Info("Added note %s to %s", noteIndex(task).ShortID(note.ID), task.Base().Name)
+	return nil
+}
+
+func (c *TodoCommand) notes(s store.Store, ctx climax.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: todo notes <todo>")
+	}
+	task, err := findTodo(s, args[0])
+	if err != nil {
+		return err
+	}
+	index := noteIndex(task)
+	task.Base().Notes.Each(func(_ int, note *model.Note) {
+		fmt.Printf("%-8s  %s  %-12s  %s\n", index.ShortID(note.ID), note.CreationDate.Time().Format("2006-01-02 15:04"),
+			note.Author, note.Name)
+	})
+	return nil
+}
+
+func (c *TodoCommand) unnote(s store.Store, ctx climax.Context, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: todo unnote <todo> <note>")
+	}
+	task, err := findTodo(s, args[0])
+	if err != nil {
+		return err
+	}
+	id, err := resolve(noteIndex(task), args[1], "note")
+	if err != nil {
+		return err
+	}
+	task.Base().RemoveNote(id)
+	return store.SaveTask(s, task)
+}
+
+// noteIndex returns an index of the notes of the given todo, so that they can be referenced like the todos
+func noteIndex(task model.Task) *model.Index {
+	index := model.NewIndex()
+	task.Base().Notes.Each(func(_ int, note *model.Note) {
+		index.AddItem(model.NewItem(note.ID, note.Name))
+	})
+	return index
+}
+
+func (c *TodoCommand) move(s store.Store, ctx climax.Context, args []string) error {
+	before, isBefore := ctx.Get("before")
+	after, isAfter := ctx.Get("after")
+	to, isTo := ctx.Get("to")
+	options := 0
+	for _, set := range []bool{isBefore, isAfter, ctx.Is("top")} {
+		if set {
+			options++
+		}
+	}
+	if len(args) != 1 || options > 1 || (options == 0 && !isTo) {
+		return errors.New("usage: todo move <todo> [--to=<board>] --before=<todo> | --after=<todo> | --top")
+	}
+	task, err := findTodo(s, args[0])
+	if err != nil {
+		return err
+	}
+	from, err := s.BoardOf(task.Base().ID)
+	if err != nil {
+		return err
+	}
+	board := from
+	if isTo {
+		if board, err = findBoard(s, to); err != nil {
+			return err
+		}
+	}
+	position := 0
+	if isBefore || isAfter {
+		ref := before
+		if isAfter {
+			ref = after
+		}
+		if position, err = relativePosition(s, task, board, ref); err != nil {
+			return err
+		}
+		if isAfter {
+			position++
+		}
+	}
+	// Only a todo that changes board takes its subtasks along, a subtask is placed within its board like any todo
+	switch {
+	case board.ID != from.ID && options == 1:
+		err = store.TransferTodoTreeAt(s, task.Base().ID, board.ID, position)
+	case board.ID != from.ID:
+		err = store.TransferTodoTree(s, task.Base().ID, board.ID)
+	case options == 1:
+		err = store.MoveTodo(s, task.Base().ID, position)
+	}
+	if err != nil {
+		return err
+	}
+	utils.Info("Moved todo %s", task.Base().Name)
+	return nil
+}
+
+func (c *TodoCommand) copy(s store.Store, ctx climax.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: todo copy <todo> [--to=<board>]")
+	}
+	task, err := findTodo(s, args[0])
+	if err != nil {
+		return err
+	}
+	board, err := s.BoardOf(task.Base().ID)
+	if err != nil {
+		return err
+	}
+	if to, ok := ctx.Get("to"); ok {
+		if board, err = findBoard(s, to); err != nil {
+			return err
+		}
+	}
+	copied, err := store.CopyTodoTree(s, task.Base().ID, board.ID)
+	if err != nil {
+		return err
+	}
+	utils.Info("Copied todo %s to %s as %s", task.Base().Name, board.Name, s.TodoIndex().ShortID(copied.Base().ID))
+	return nil
+}
+
+func (c *TodoCommand) archive(s store.Store, ctx climax.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: todo archive <todo>")
+	}
+	task, err := findTodo(s, args[0])
+	if err != nil {
+		return err
+	}
+	if err := store.ArchiveTodoTree(s, task.Base().ID); err != nil {
+		return err
+	}
+	utils.Info("Archived todo %s", task.Base().Name)
+	return nil
+}
+
+func (c *TodoCommand) restore(s store.Store, ctx climax.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: todo restore <todo>")
+	}
+	archive, err := s.Archive()
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	task, err := findTodo(archive, args[0])
+	if err != nil {
+		return err
+	}
+	if err := store.RestoreTodoTree(s, task.Base().ID); err != nil {
+		return err
+	}
+	utils.Info("Restored todo %s", task.Base().Name)
+	return nil
+}
+
+// relativePosition returns the position of the referenced todo among the todos of the given board other than the
+// given todo
+func relativePosition(s store.Store, task model.Task, board *model.Board, ref string) (int, error) {
+	target, err := findTodo(s, ref)
+	if err != nil {
+		return 0, err
+	}
+	if target.Base().ID == task.Base().ID {
+		return 0, errors.New("a todo can not be moved next to itself")
+	}
+	tasks, err := s.Todos(board.ID)
+	if err != nil {
+		return 0, err
+	}
+	position := 0
+	for _, other := range tasks {
+		switch other.Base().ID {
+		case task.Base().ID:
+			continue
+		case target.Base().ID:
+			return position, nil
+		}
+		position++
+	}
+	return 0, errors.Errorf("todo %s is not in the board %s", target.Base().Name, board.Name)
+}
@@ -0,0 +1,159 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/chordflower/todoman/internal/utils"
+	"github.com/gofrs/uuid"
+	"github.com/tucnak/climax"
+)
+
+// TrashCommand manages the removed boards and todos
+type TrashCommand struct{}
+
+// NewTrashCommand creates a new trash command
+func NewTrashCommand() *TrashCommand {
+	return &TrashCommand{}
+}
+
+// Name returns the name of this command
+func (c *TrashCommand) Name() string {
+	return "trash"
+}
+
+// Configure returns the climax definition of this command
+func (c *TrashCommand) Configure() *climax.Command {
+	return &climax.Command{
+		Name:  c.Name(),
+		Brief: "manage the removed boards and todos",
+		Usage: "list | restore <removed> | empty",
+		Help: `Removed boards and todos are moved to the trash of the repository, where they
+are kept for the trash_retention days of the configuration, 30 by default or
+forever when it is 0. Restoring a board brings back its removed todos too, and
+restoring a todo brings back its removed subtasks, and its board when that was
+removed as well. Emptying the trash removes everything in it for good.`,
+		Examples: []climax.Example{
+			{
+				Usecase:     `restore "write tests"`,
+				Description: "Brings back the removed todo write tests",
+			},
+		},
+		Handle: c.Run,
+	}
+}
+
+// Run executes this command
+func (c *TrashCommand) Run(ctx climax.Context) int {
+	if len(ctx.Args) == 0 {
+		return fail(errors.New("missing trash action"))
+	}
+	open := openStore
+	if ctx.Args[0] == "list" {
+		open = openReader
+	}
+	s, err := open()
+	if err != nil {
+		return fail(err)
+	}
+	defer s.Close()
+	args := ctx.Args[1:]
+	switch ctx.Args[0] {
+	case "list":
+		if err := c.list(s); err != nil {
+			return fail(err)
+		}
+	case "restore":
+		if len(args) != 1 {
+			return fail(errors.New("usage: trash restore <removed>"))
+		}
+		if err := c.restore(s, args[0]); err != nil {
+			return fail(err)
+		}
+	case "empty":
+		if err := s.EmptyTrash(time.Now()); err != nil {
+			return fail(err)
+		}
+		utils.Info("Emptied the trash")
+	default:
+		return fail(errors.Errorf("unknown trash action %q", ctx.Args[0]))
+	}
+	return 0
+}
+
+// list prints the removed boards and todos, from the oldest to the newest removal
+func (c *TrashCommand) list(s store.Store) error {
+	trash, err := s.Trash()
+	if err != nil {
+		return err
+	}
+	removed, err := s.Removed()
+	if err != nil {
+		return err
+	}
+	ids := make([]uuid.UUID, 0, len(removed))
+	for id := range removed {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return removed[ids[i]].Before(removed[ids[j]])
+	})
+	for _, id := range ids {
+		when := removed[id].Local().Format("2006-01-02 15:04")
+		if board, err := trash.Board(id); err == nil {
+			fmt.Printf("%s  board  %-8s  %s\n", when, trash.BoardIndex().ShortID(id), board.Name)
+		} else if task, err := trash.Todo(id); err == nil {
+			board, _ := trash.BoardOf(id)
+			fmt.Printf("%s  todo   %-8s  %s (%s)\n", when, trash.TodoIndex().ShortID(id), task.Base().Name, board.Name)
+		}
+	}
+	return nil
+}
+
+// restore moves the referenced board or todo out of the trash, a todo together with its removed subtasks
+func (c *TrashCommand) restore(s store.Store, ref string) error {
+	trash, err := s.Trash()
+	if err != nil {
+		return err
+	}
+	removed, err := s.Removed()
+	if err != nil {
+		return err
+	}
+	// The trash keeps a copy of the board of every removed todo, but only the removed boards can be restored
+	if board, err := findBoard(trash, ref); err == nil {
+		if _, ok := removed[board.ID]; ok {
+			if err := s.Recover(board.ID); err != nil {
+				return err
+			}
+			utils.Info("Restored board %s", board.Name)
+			return nil
+		}
+	}
+	task, err := findTodo(trash, ref)
+	if err != nil {
+		return err
+	}
+	if err := store.RecoverTodoTree(s, task.Base().ID); err != nil {
+		return err
+	}
+	utils.Info("Restored todo %s", task.Base().Name)
+	return nil
+}
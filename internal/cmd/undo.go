@@ -0,0 +1,95 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"path/filepath"
+	"strconv"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/config"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/chordflower/todoman/internal/utils"
+	"github.com/tucnak/climax"
+)
+
+// UndoCommand reverts the last changes made to the repository
+type UndoCommand struct{}
+
+// NewUndoCommand creates a new undo command
+func NewUndoCommand() *UndoCommand {
+	return &UndoCommand{}
+}
+
+// Name returns the name of this command
+func (c *UndoCommand) Name() string {
+	return "undo"
+}
+
+// Configure returns the climax definition of this command
+func (c *UndoCommand) Configure() *climax.Command {
+	return &climax.Command{
+		Name:  c.Name(),
+		Brief: "revert the last commands that changed the repository",
+		Usage: "[<count>]",
+		Help: `Reverts the changes of the last command that changed the repository, or of the
+last count of them, newest first. Every change is kept in the history of the
+repository with the changed model as it was before and after it, and undoing
+a command removes its changes from the history, so it can not be redone.
+Undoing the creation of a board or todo moves it to the trash.`,
+		Examples: []climax.Example{
+			{
+				Usecase:     "3",
+				Description: "Reverts the last 3 commands that changed the repository",
+			},
+		},
+		Handle: c.Run,
+	}
+}
+
+// Run executes this command
+func (c *UndoCommand) Run(ctx climax.Context) int {
+	count := 1
+	if len(ctx.Args) > 1 {
+		return fail(errors.New("usage: undo [<count>]"))
+	} else if len(ctx.Args) == 1 {
+		var err error
+		if count, err = strconv.Atoi(ctx.Args[0]); err != nil || count < 1 {
+			return fail(errors.Errorf("invalid count %q", ctx.Args[0]))
+		}
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fail(err)
+	}
+	// The reverting changes are not kept in the history
+	s, err := openRepository(true, false)
+	if err != nil {
+		return fail(err)
+	}
+	defer s.Close()
+	lines, err := store.Undo(s, filepath.Join(cfg.Repository, store.HistoryFile), count)
+	// Closing the store releases the repository and delivers the events of the reverting changes to the hooks
+	if err := errors.Combine(err, s.Close()); err != nil {
+		return fail(err)
+	}
+	if len(lines) == 0 {
+		utils.Info("Nothing to undo")
+	}
+	for _, line := range lines {
+		utils.Info("Undid todoman %s", line)
+	}
+	return 0
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/chordflower/todoman/internal/config"
+	"github.com/chordflower/todoman/internal/hook"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/tucnak/climax"
+)
+
+// setupRepository points the configuration to a new repository, with a hook that runs the given command on every
+// event, and returns the repository
+func setupRepository(t *testing.T, command string) string {
+	t.Helper()
+	repository := t.TempDir()
+	dir := t.TempDir()
+	t.Setenv(config.ConfigEnv, dir)
+	t.Setenv(config.RepositoryEnv, repository)
+	data, err := json.Marshal([]*hook.Hook{{Name: "all", Events: []string{"*"}, Command: command}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, hook.FileName), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return repository
+}
+
+func TestUndoDeliversHooks(t *testing.T) {
+	command, err := exec.LookPath("true")
+	if err != nil {
+		t.Skip("there is no true command to run as a hook")
+	}
+	repository := setupRepository(t, command)
+	s, err := openStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	board := model.NewBoard2("main", "")
+	if err := s.SaveBoard(board); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := NewUndoCommand().Run(climax.Context{}); code != 0 {
+		t.Fatalf("undo exited with %d", code)
+	}
+	deliveries, err := hook.ReadLog(repository)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delivered := false
+	for _, delivery := range deliveries {
+		if delivery.Type == store.EVENT_BOARD_DELETED && delivery.Success {
+			delivered = true
+		}
+	}
+	if !delivered {
+		t.Errorf("the removal of the board by undo was not delivered to the hook: %v", deliveries)
+	}
+	holders, err := os.ReadDir(filepath.Join(repository, store.HoldersDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holders) > 0 {
+		t.Errorf("undo left the lock holder %s", holders[0].Name())
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"os/user"
+	"path/filepath"
+
+	"emperror.dev/errors"
+)
+
+const (
+	// ConfigEnv is the environment variable that overrides the configuration directory
+	ConfigEnv = "TODOMAN_CONFIG"
+	// RepositoryEnv is the environment variable that overrides the repository location
+	RepositoryEnv = "TODOMAN_REPOSITORY"
+	// APITokenEnv is the environment variable that overrides the token of the rest api
+	APITokenEnv = "TODOMAN_API_TOKEN"
+	// UserEnv is the environment variable that overrides the user of the configuration
+	UserEnv = "TODOMAN_USER"
+)
+
+const (
+	// DefaultTrashRetention is the number of days the removed boards and todos are kept by default
+	DefaultTrashRetention = 30
+	// DefaultLockTimeout is the number of seconds to wait for the lock of the repository by default
+	DefaultLockTimeout = 10
+)
+
+// Config represents the application configuration
+type Config struct {
+	Repository     string `json:"repository"`      // The location of the repository
+	APIToken       string `json:"api_token"`       // The token the clients of the rest api must send
+	ArchiveAfter   int    `json:"archive_after"`   // The days after which the done todos are archived, never when 0
+	TrashRetention int    `json:"trash_retention"` // The days the removed boards and todos are kept, forever when 0
+	LockTimeout    int    `json:"lock_timeout"`    // The seconds to wait for the other processes using the repository
+	User           string `json:"user"`            // Who makes the changes, the login name by default
+}
+
+// Dir returns the directory that contains the configuration files
+func Dir() string {
+	if dir := os.Getenv(ConfigEnv); dir != "" {
+		return dir
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "todoman")
+}
+
+// defaultUser returns the login name of the current user, or unknown when it has none
+func defaultUser() string {
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		return current.Username
+	}
+	return "unknown"
+}
+
+// defaultRepository returns the default location of the repository
+func defaultRepository() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "todoman")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
+	}
+	return filepath.Join(home, ".local", "share", "todoman")
+}
+
+// Load reads the configuration file, using the defaults for everything that is not defined
+func Load() (*Config, error) {
+	cfg := &Config{
+		Repository:     defaultRepository(),
+		TrashRetention: DefaultTrashRetention,
+		LockTimeout:    DefaultLockTimeout,
+	}
+	data, err := os.ReadFile(filepath.Join(Dir(), "config.json"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, errors.Wrap(err, "unable to read the configuration")
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, errors.Wrap(err, "unable to parse the configuration")
+		}
+	}
+	if repo := os.Getenv(RepositoryEnv); repo != "" {
+		cfg.Repository = repo
+	}
+	if token := os.Getenv(APITokenEnv); token != "" {
+		cfg.APIToken = token
+	}
+	if name := os.Getenv(UserEnv); name != "" {
+		cfg.User = name
+	}
+	if cfg.User == "" {
+		cfg.User = defaultUser()
+	}
+	return cfg, nil
+}
@@ -0,0 +1,268 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchange
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	date "github.com/bykof/gostradamus"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/gofrs/uuid"
+)
+
+// foreignNamespace is the namespace of the ids derived from the ids of other applications, so that importing the
+// same file again updates the models created by the previous import
+var foreignNamespace = uuid.Must(uuid.FromString("517fe8ac-8955-4835-b560-a9276beaa9ba"))
+
+// foreignID returns the id of the model created from the model with the given kind and id of the given source
+func foreignID(source, kind, id string) uuid.UUID {
+	return uuid.NewV5(foreignNamespace, source+"/"+kind+"/"+id)
+}
+
+// foreignBoard is a board read from another application
+type foreignBoard struct {
+	ID          string // The id of the board in its application, empty for sources without boards
+	Name        string
+	Description string
+	Colour      color.RGBA
+	Cards       []foreignCard
+}
+
+// foreignCard is a card or issue read from another application
+type foreignCard struct {
+	ID          string
+	Name        string
+	Description string
+	Status      model.TodoStatus
+	Created     time.Time
+	Closed      time.Time
+	Due         time.Time
+	Labels      []foreignLabel
+	Comments    []foreignComment
+}
+
+// foreignLabel is a label read from another application
+type foreignLabel struct {
+	Name   string
+	Colour color.RGBA
+}
+
+// foreignComment is a comment read from another application
+type foreignComment struct {
+	ID     string
+	Author string
+	Text   string
+	Date   time.Time
+}
+
+// parseHexColour parses a colour with the #rrggbb or rrggbb form, returning a transparent colour if it is invalid
+func parseHexColour(value string) color.RGBA {
+	value = strings.TrimPrefix(value, "#")
+	rgb, err := strconv.ParseUint(value, 16, 32)
+	if len(value) != 6 || err != nil {
+		return color.RGBA{}
+	}
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}
+}
+
+// tagName converts a label into a valid tag name, which can not have spaces or commas
+func tagName(label string) string {
+	return strings.NewReplacer(" ", "_", ",", "_").Replace(strings.TrimSpace(label))
+}
+
+// noteName returns the name of a note with the given text, its first line shortened
+func noteName(text string) string {
+	name, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	if runes := []rune(name); len(runes) > 60 {
+		name = string(runes[:59]) + "…"
+	}
+	if name == "" {
+		name = "Comment"
+	}
+	return name
+}
+
+// toDateTime converts the given time, keeping the zero time empty
+func toDateTime(value time.Time) model.DateTime {
+	if value.IsZero() {
+		return model.DateTime{}
+	}
+	return model.DateTime{DateTime: date.DateTimeFromTime(value)}
+}
+
+// foreignImporter saves the boards read from another application into the store, or when dry running only
+// describes what would change
+type foreignImporter struct {
+	s       store.Store
+	source  string
+	preview io.Writer
+	tags    map[string]bool
+	result  ImportResult
+}
+
+// ImportOptions changes how the boards of other applications are imported
+type ImportOptions struct {
+	Board    string                      // The board of the todos from sources without boards
+	DryRun   io.Writer                   // If not nil, the changes are only described here instead of saved
+	Statuses map[string]model.TodoStatus // The status of the todos of each list, besides the guessed ones
+}
+
+// newForeignImporter creates an importer of the boards of the given source
+func newForeignImporter(s store.Store, source string, options ImportOptions) *foreignImporter {
+	return &foreignImporter{s: s, source: source, preview: options.DryRun, tags: make(map[string]bool)}
+}
+
+// describe writes the given change to the preview
+func (fi *foreignImporter) describe(format string, args ...any) {
+	fmt.Fprintf(fi.preview, format+"\n", args...)
+}
+
+// importBoards saves the given boards and their cards
+func (fi *foreignImporter) importBoards(boards []foreignBoard) (ImportResult, error) {
+	for _, fb := range boards {
+		board, err := fi.board(fb)
+		if err != nil {
+			return fi.result, err
+		}
+		for _, card := range fb.Cards {
+			if err := fi.card(board, card); err != nil {
+				return fi.result, err
+			}
+		}
+	}
+	return fi.result, nil
+}
+
+// board returns the board for the given foreign board: the one created by a previous import, the one with the
+// same name, or a new one
+func (fi *foreignImporter) board(fb foreignBoard) (*model.Board, error) {
+	if fb.ID != "" {
+		if board, err := fi.s.Board(foreignID(fi.source, "board", fb.ID)); err == nil {
+			if fb.Colour.A != 0 {
+				board.Colour = fb.Colour
+			}
+			if fb.Description != "" {
+				board.Description = fb.Description
+			}
+			if fi.preview != nil {
+				fi.describe("update board %s", board.Name)
+				return board, nil
+			}
+			return board, fi.s.SaveBoard(board)
+		}
+	}
+	if items := fi.s.BoardIndex().FindByName(fb.Name); len(items) > 0 {
+		return fi.s.Board(items[0].ID)
+	}
+	board := model.NewBoard(fb.Name, fb.Colour)
+	board.Description = fb.Description
+	if fb.ID != "" {
+		board.ID = foreignID(fi.source, "board", fb.ID)
+	}
+	if fi.preview != nil {
+		fi.describe("create board %s", board.Name)
+		return board, nil
+	}
+	return board, fi.s.SaveBoard(board)
+}
+
+// tag creates the tag of the given label if it does not exist
+func (fi *foreignImporter) tag(label foreignLabel) (string, error) {
+	name := tagName(label.Name)
+	if fi.tags[name] {
+		return name, nil
+	}
+	fi.tags[name] = true
+	if _, err := fi.s.Tag(name); err == nil {
+		return name, nil
+	}
+	if fi.preview != nil {
+		fi.describe("create tag %s", name)
+		return name, nil
+	}
+	return name, fi.s.SaveTag(model.NewTag(name, label.Colour))
+}
+
+// card saves the given card as a todo of the given board, updating the todo of a previous import
+func (fi *foreignImporter) card(board *model.Board, card foreignCard) error {
+	id := foreignID(fi.source, "card", card.ID)
+	task, err := fi.s.Todo(id)
+	created := err != nil
+	if created {
+		task = model.NewTodo(card.Name)
+		task.Base().ID = id
+	} else if owner, _ := fi.s.BoardOf(id); owner != nil {
+		board = owner
+	}
+
+	t := task.Base()
+	t.Name = card.Name
+	t.Description = card.Description
+	if !card.Created.IsZero() {
+		t.CreationDate = toDateTime(card.Created)
+	}
+	t.SetStatus(card.Status)
+	if t.IsFinished() && !card.Closed.IsZero() {
+		t.CompleteDate = toDateTime(card.Closed)
+	}
+	t.DueDate = toDateTime(card.Due)
+	for _, label := range card.Labels {
+		name, err := fi.tag(label)
+		if err != nil {
+			return err
+		}
+		t.AddTag(name)
+	}
+	notes := 0
+	for _, comment := range card.Comments {
+		noteID := foreignID(fi.source, "comment", comment.ID)
+		if t.HasNote(noteID) {
+			continue
+		}
+		author := comment.Author
+		if author == "" {
+			author = fi.source
+		}
+		note := model.NewNote(noteName(comment.Text), author)
+		note.ID = noteID
+		note.Description = comment.Text
+		if !comment.Date.IsZero() {
+			note.CreationDate = toDateTime(comment.Date)
+		}
+		t.AddNote(note)
+		notes++
+	}
+
+	if created {
+		fi.result.Created++
+	} else {
+		fi.result.Updated++
+	}
+	if fi.preview != nil {
+		action := "create"
+		if !created {
+			action = "update"
+		}
+		fi.describe("%s todo %s on %s (%s, %d new notes)", action, t.Name, board.Name, t.Status.Name(), notes)
+		return nil
+	}
+	return fi.s.SaveTodo(board.ID, task)
+}
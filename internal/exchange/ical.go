@@ -0,0 +1,481 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchange
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	date "github.com/bykof/gostradamus"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	icalDateTime    = "20060102T150405Z"
+	icalLocalTime   = "20060102T150405"
+	icalDate        = "20060102"
+	icalLineLength  = 75
+	icalStatusExtra = "X-TODOMAN-STATUS"
+	// The parameters of the COMMENT of a note, whose value is the description of the note
+	icalNoteID     = "X-TODOMAN-ID"
+	icalNoteName   = "X-TODOMAN-NAME"
+	icalNoteAuthor = "X-TODOMAN-AUTHOR"
+	// icalNoteSource is the author of the notes of the comments of other applications
+	icalNoteSource = "ical"
+)
+
+// icalProperty represents a content line of an iCalendar file
+type icalProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// icalTodo represents a VTODO component, with its properties by name in their order, since some of them like COMMENT
+// can be repeated
+type icalTodo map[string][]icalProperty
+
+// get returns the first property of this component with the given name, and if it has one
+func (vtodo icalTodo) get(name string) (icalProperty, bool) {
+	if props := vtodo[name]; len(props) > 0 {
+		return props[0], true
+	}
+	return icalProperty{Params: make(map[string]string)}, false
+}
+
+// value returns the value of the first property of this component with the given name, empty when it has none
+func (vtodo icalTodo) value(name string) string {
+	prop, _ := vtodo.get(name)
+	return prop.Value
+}
+
+// escapeICalText escapes a TEXT value as defined by RFC 5545
+func escapeICalText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+// unescapeICalText reverts escapeICalText
+func unescapeICalText(value string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(value)
+}
+
+// splitICalList splits a list of TEXT values on the commas that are not escaped
+func splitICalList(value string) []string {
+	ret := make([]string, 0)
+	current := strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value):
+			current.WriteByte(value[i])
+			current.WriteByte(value[i+1])
+			i++
+		case value[i] == ',':
+			ret = append(ret, unescapeICalText(current.String()))
+			current.Reset()
+		default:
+			current.WriteByte(value[i])
+		}
+	}
+	if current.Len() > 0 {
+		ret = append(ret, unescapeICalText(current.String()))
+	}
+	return ret
+}
+
+// icalWriter writes content lines, folding them at 75 octets
+type icalWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (iw *icalWriter) line(name, value string) {
+	if iw.err != nil {
+		return
+	}
+	line := name + ":" + value
+	var b strings.Builder
+	for len(line) > icalLineLength {
+		cut := icalLineLength
+		// Never split a multi byte character
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+	}
+	b.WriteString(line + "\r\n")
+	_, iw.err = io.WriteString(iw.w, b.String())
+}
+
+func (iw *icalWriter) date(name string, value model.DateTime) {
+	if !value.IsZero() {
+		iw.line(name, value.Time().UTC().Format(icalDateTime))
+	}
+}
+
+// icalStatus converts a todo status into a VTODO status
+func icalStatus(status model.TodoStatus) string {
+	switch status {
+	case model.STATUS_STARTED, model.STATUS_PAUSED:
+		return "IN-PROCESS"
+	case model.STATUS_FINISHED, model.STATUS_DONE:
+		return "COMPLETED"
+	}
+	return "NEEDS-ACTION"
+}
+
+// icalPriority converts a todo priority into a VTODO priority, where 1 is the highest and 9 the lowest
+func icalPriority(priority model.TodoPriority) int {
+	switch priority {
+	case model.PRIORITY_HIGHEST:
+		return 1
+	case model.PRIORITY_HIGHER:
+		return 2
+	case model.PRIORITY_HIGH:
+		return 4
+	case model.PRIORITY_LOW:
+		return 6
+	case model.PRIORITY_LOWER:
+		return 8
+	case model.PRIORITY_LOWEST:
+		return 9
+	}
+	return 5
+}
+
+// todoPriority converts a VTODO priority into a todo priority, 0 means undefined
+func todoPriority(priority int) model.TodoPriority {
+	switch {
+	case priority == 1:
+		return model.PRIORITY_HIGHEST
+	case priority == 2:
+		return model.PRIORITY_HIGHER
+	case priority == 3 || priority == 4:
+		return model.PRIORITY_HIGH
+	case priority == 6 || priority == 7:
+		return model.PRIORITY_LOW
+	case priority == 8:
+		return model.PRIORITY_LOWER
+	case priority == 9:
+		return model.PRIORITY_LOWEST
+	}
+	return model.PRIORITY_NORMAL
+}
+
+// icalParam returns a quoted parameter value, with its quotes and line breaks encoded as defined by RFC 6868
+func icalParam(value string) string {
+	return `"` + strings.NewReplacer("^", "^^", `"`, "^'", "\r\n", "^n", "\n", "^n").Replace(value) + `"`
+}
+
+// unescapeICalParam reverts the RFC 6868 encoding of a parameter value
+func unescapeICalParam(value string) string {
+	return strings.NewReplacer("^^", "^", "^'", `"`, "^n", "\n").Replace(value)
+}
+
+// icalNote returns the name with the parameters and the value of the COMMENT of the given note
+func icalNote(note *model.Note) (string, string) {
+	name := fmt.Sprintf("COMMENT;%s=%s;%s=%s;%s=%s", icalNoteID, note.ID, icalNoteName, icalParam(note.Name),
+		icalNoteAuthor, icalParam(note.Author))
+	return name, escapeICalText(note.Description)
+}
+
+// ExportICal writes the given todos as VTODO components of an iCalendar file, with the given calendar name
+func ExportICal(w io.Writer, name string, tasks []model.Task) error {
+	iw := &icalWriter{w: w}
+	iw.line("BEGIN", "VCALENDAR")
+	iw.line("VERSION", "2.0")
+	iw.line("PRODID", "-//chordflower//todoman//EN")
+	if name != "" {
+		iw.line("X-WR-CALNAME", escapeICalText(name))
+	}
+	stamp := time.Now().UTC().Format(icalDateTime)
+	for _, task := range tasks {
+		t := task.Base()
+		iw.line("BEGIN", "VTODO")
+		iw.line("UID", t.ID.String())
+		iw.line("DTSTAMP", stamp)
+		iw.date("CREATED", t.CreationDate)
+		iw.line("SUMMARY", escapeICalText(t.Name))
+		if t.Description != "" {
+			iw.line("DESCRIPTION", escapeICalText(t.Description))
+		}
+		// The notes are kept apart from the description, so that importing the todo again does not repeat them
+		t.Notes.Each(func(index int, note *model.Note) {
+			iw.line(icalNote(note))
+		})
+		iw.line("STATUS", icalStatus(t.Status))
+		iw.line(icalStatusExtra, t.Status.Name())
+		iw.line("PRIORITY", strconv.Itoa(icalPriority(t.Priority)))
+		iw.date("DTSTART", t.StartDate)
+		iw.date("DUE", t.DueDate)
+		iw.date("COMPLETED", t.CompleteDate)
+		if len(t.Tags) > 0 {
+			tags := make([]string, 0, len(t.Tags))
+			for _, tag := range t.Tags {
+				tags = append(tags, escapeICalText(tag))
+			}
+			iw.line("CATEGORIES", strings.Join(tags, ","))
+		}
+		if t.Recurrence != nil {
+			iw.line("RRULE", t.Recurrence.String())
+		}
+		if t.IsSubtask() {
+			iw.line("RELATED-TO", t.Parent.String())
+		}
+		iw.line("END", "VTODO")
+	}
+	iw.line("END", "VCALENDAR")
+	return iw.err
+}
+
+// parseICalProperty parses an unfolded content line
+func parseICalProperty(line string) (icalProperty, error) {
+	prop := icalProperty{Params: make(map[string]string)}
+	// The value starts at the first colon that is not inside a quoted parameter
+	quoted, colon := false, -1
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon == -1 {
+		return prop, errors.Errorf("invalid iCalendar line %q", line)
+	}
+	prop.Value = line[colon+1:]
+	parts := splitICalParams(line[:colon])
+	prop.Name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		prop.Params[strings.ToUpper(key)] = unescapeICalParam(strings.Trim(value, `"`))
+	}
+	return prop, nil
+}
+
+// splitICalParams splits the name and parameters of a content line on the semicolons that are not quoted
+func splitICalParams(value string) []string {
+	parts := make([]string, 0)
+	quoted, start := false, 0
+	for i, r := range value {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ';' && !quoted {
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+// parseICalDate parses a DATE or DATE-TIME value, using the TZID parameter for local times
+func parseICalDate(prop icalProperty) (model.DateTime, error) {
+	location := time.Local
+	if tzid, ok := prop.Params["TZID"]; ok {
+		if loc, err := time.LoadLocation(tzid); err == nil {
+			location = loc
+		}
+	}
+	if parsed, err := time.Parse(icalDateTime, prop.Value); err == nil {
+		return model.DateTime{DateTime: date.DateTimeFromTime(parsed)}, nil
+	}
+	if parsed, err := time.ParseInLocation(icalLocalTime, prop.Value, location); err == nil {
+		return model.DateTime{DateTime: date.DateTimeFromTime(parsed)}, nil
+	}
+	if parsed, err := time.ParseInLocation(icalDate, prop.Value, location); err == nil {
+		return model.DateTime{DateTime: date.DateTimeFromTime(parsed).CeilDay()}, nil
+	}
+	return model.DateTime{}, errors.Errorf("invalid iCalendar date %q in %s", prop.Value, prop.Name)
+}
+
+// readICalTodos reads the VTODO components of an iCalendar file
+func readICalTodos(r io.Reader) ([]icalTodo, error) {
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+		} else if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	todos := make([]icalTodo, 0)
+	var current icalTodo
+	depth := 0
+	for _, line := range lines {
+		prop, err := parseICalProperty(line)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case prop.Name == "BEGIN" && strings.EqualFold(prop.Value, "VTODO"):
+			current, depth = make(icalTodo), 0
+		case current != nil && prop.Name == "BEGIN":
+			// Skip the properties of nested components, like VALARM
+			depth++
+		case current != nil && prop.Name == "END" && depth > 0:
+			depth--
+		case current != nil && prop.Name == "END" && strings.EqualFold(prop.Value, "VTODO"):
+			todos = append(todos, current)
+			current = nil
+		case current != nil && depth == 0:
+			current[prop.Name] = append(current[prop.Name], prop)
+		}
+	}
+	return todos, nil
+}
+
+// ImportICal reads the VTODO components of an iCalendar file into the given board, the components whose UID
+// is the id of an existing todo update it
+func ImportICal(s store.Store, r io.Reader, board uuid.UUID) (result ImportResult, err error) {
+	todos, err := readICalTodos(r)
+	if err != nil {
+		return result, err
+	}
+	for _, vtodo := range todos {
+		created, err := importICalTodo(s, vtodo, board)
+		if err != nil {
+			return result, errors.Wrapf(err, "VTODO %s", vtodo.value("UID"))
+		}
+		if created {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+	}
+	return result, nil
+}
+
+// importICalTodo saves the given VTODO as a todo, returning if it was created
+func importICalTodo(s store.Store, vtodo icalTodo, board uuid.UUID) (bool, error) {
+	summary := unescapeICalText(vtodo.value("SUMMARY"))
+	if summary == "" {
+		summary = "(untitled)"
+	}
+	var task model.Task
+	created := true
+	if id, err := uuid.FromString(vtodo.value("UID")); err == nil {
+		if existing, err := s.Todo(id); err == nil {
+			task, created = existing, false
+			if owner, err := s.BoardOf(id); err == nil {
+				board = owner.ID
+			}
+		}
+	}
+	if task == nil {
+		task = model.NewTodo(summary)
+	}
+	t := task.Base()
+	t.Name = summary
+	t.Description = unescapeICalText(vtodo.value("DESCRIPTION"))
+	for _, comment := range vtodo["COMMENT"] {
+		importICalNote(t, comment)
+	}
+
+	switch strings.ToUpper(vtodo.value("STATUS")) {
+	case "IN-PROCESS":
+		t.Status = model.STATUS_STARTED
+	case "COMPLETED":
+		t.Status = model.STATUS_DONE
+	default:
+		t.Status = model.STATUS_NEW
+	}
+	if extra, ok := vtodo.get(icalStatusExtra); ok {
+		if status, err := model.ParseTodoStatus(extra.Value); err == nil {
+			t.Status = status
+		}
+	}
+	priority, _ := strconv.Atoi(vtodo.value("PRIORITY"))
+	t.Priority = todoPriority(priority)
+
+	dates := map[string]*model.DateTime{
+		"CREATED":   &t.CreationDate,
+		"DTSTART":   &t.StartDate,
+		"DUE":       &t.DueDate,
+		"COMPLETED": &t.CompleteDate,
+	}
+	for name, field := range dates {
+		prop, ok := vtodo.get(name)
+		if !ok {
+			continue
+		}
+		value, err := parseICalDate(prop)
+		if err != nil {
+			return false, err
+		}
+		*field = value
+	}
+	if categories, ok := vtodo.get("CATEGORIES"); ok {
+		t.Tags = make([]string, 0)
+		for _, name := range splitICalList(categories.Value) {
+			name = strings.ReplaceAll(strings.TrimSpace(name), " ", "-")
+			if name == "" {
+				continue
+			}
+			if err := findOrCreateTag(s, name); err != nil {
+				return false, err
+			}
+			t.AddTag(name)
+		}
+	}
+	if rule, ok := vtodo.get("RRULE"); ok {
+		recurrence, err := model.ParseRecurrence(rule.Value)
+		if err != nil {
+			return false, err
+		}
+		t.Recurrence = recurrence
+	}
+	return created, s.SaveTodo(board, task)
+}
+
+// importICalNote adds the given COMMENT to the notes of the given todo, or updates the note it was exported from. The
+// comments of other applications become notes whose id depends on their text, so they are only added once.
+func importICalNote(t *model.Todo, comment icalProperty) {
+	text := unescapeICalText(comment.Value)
+	id, err := uuid.FromString(comment.Params[icalNoteID])
+	if err != nil {
+		id = uuid.NewV5(t.ID, "comment:"+text)
+	}
+	name := comment.Params[icalNoteName]
+	if name == "" {
+		name = noteName(text)
+	}
+	author := comment.Params[icalNoteAuthor]
+	if author == "" {
+		author = icalNoteSource
+	}
+	if note, ok := t.Notes.Get(id); ok {
+		note.Name, note.Description, note.Author = name, text, author
+		return
+	}
+	note := model.NewNote(name, author)
+	note.ID = id
+	note.Description = text
+	t.AddNote(note)
+}
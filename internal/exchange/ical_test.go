@@ -0,0 +1,135 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchange
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+)
+
+// newTestStore returns a new json store with a board
+func newTestStore(t *testing.T) (store.Store, *model.Board) {
+	t.Helper()
+	s, err := store.NewJSONStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	board := model.NewBoard2("main", "")
+	if err := s.SaveBoard(board); err != nil {
+		t.Fatal(err)
+	}
+	return s, board
+}
+
+// noteTexts returns the name, author and description of every note of the given todo
+func noteTexts(t *model.Todo) []string {
+	texts := make([]string, 0)
+	t.Notes.Each(func(index int, note *model.Note) {
+		texts = append(texts, note.Name+"|"+note.Author+"|"+note.Description)
+	})
+	return texts
+}
+
+func TestICalRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		notes       [][3]string // The name, author and description of each note
+	}{
+		{"without notes", "the description", nil},
+		{"without a description", "", [][3]string{{"first", "ann", "some text"}}},
+		{"with notes", "line one\nline two", [][3]string{{"first", "ann", ""}, {"second", "bob", "more; text, here"}}},
+		{"with quotes and separators", "a;b,c\\d", [][3]string{{`say "hi"; now`, "ann:x", "multi\nline"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, board := newTestStore(t)
+			todo := model.NewTodo("round trip")
+			todo.Description = test.description
+			for _, n := range test.notes {
+				note := model.NewNote(n[0], n[1])
+				note.Description = n[2]
+				todo.AddNote(note)
+			}
+			if err := s.SaveTodo(board.ID, todo); err != nil {
+				t.Fatal(err)
+			}
+			want := noteTexts(todo)
+			for i := 0; i < 3; i++ {
+				var buffer bytes.Buffer
+				if err := ExportICal(&buffer, board.Name, []model.Task{todo}); err != nil {
+					t.Fatal(err)
+				}
+				result, err := ImportICal(s, &buffer, board.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Updated != 1 || result.Created != 0 {
+					t.Fatalf("import %d created %d and updated %d todos", i+1, result.Created, result.Updated)
+				}
+			}
+			task, err := s.Todo(todo.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := task.Base().Description; got != test.description {
+				t.Errorf("the description is %q, expected %q", got, test.description)
+			}
+			if got := noteTexts(task.Base()); strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("the notes are %q, expected %q", got, want)
+			}
+		})
+	}
+}
+
+func TestICalImportComments(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string // The COMMENT lines of the VTODO
+		notes []string // The name, author and description of each imported note
+	}{
+		{"none", nil, []string{}},
+		{"foreign", []string{`COMMENT:Call back\nabout the offer`},
+			[]string{"Call back|ical|Call back\nabout the offer"}},
+		{"repeated", []string{"COMMENT:first", "COMMENT:second", "COMMENT:first"},
+			[]string{"first|ical|first", "second|ical|second"}},
+		{"exported", []string{`COMMENT;X-TODOMAN-ID=6ba7b810-9dad-11d1-80b4-00c04fd430c8;X-TODOMAN-NAME="a;b";` +
+			`X-TODOMAN-AUTHOR="ann":text\, more`}, []string{"a;b|ann|text, more"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, board := newTestStore(t)
+			ics := "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:foreign-1\r\nSUMMARY:call\r\n" +
+				strings.Join(append(test.lines, ""), "\r\n") + "END:VTODO\r\nEND:VCALENDAR\r\n"
+			if _, err := ImportICal(s, strings.NewReader(ics), board.ID); err != nil {
+				t.Fatal(err)
+			}
+			tasks, err := s.Todos(board.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tasks) != 1 {
+				t.Fatalf("imported %d todos, expected 1", len(tasks))
+			}
+			if got := noteTexts(tasks[0].Base()); strings.Join(got, "\n") != strings.Join(test.notes, "\n") {
+				t.Errorf("the notes are %q, expected %q", got, test.notes)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchange
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+)
+
+// githubIssue is an issue of the json exported by the github api or by the gh cli, which uses camel case names
+// and includes the comments
+type githubIssue struct {
+	ID          json.RawMessage `json:"id"`
+	Title       string          `json:"title"`
+	Body        string          `json:"body"`
+	State       string          `json:"state"`
+	HTMLURL     string          `json:"html_url"`
+	URL         string          `json:"url"`
+	CreatedAt   time.Time       `json:"created_at"`
+	CreatedAt2  time.Time       `json:"createdAt"`
+	ClosedAt    time.Time       `json:"closed_at"`
+	ClosedAt2   time.Time       `json:"closedAt"`
+	PullRequest json.RawMessage `json:"pull_request"`
+	Labels      []struct {
+		Name  string `json:"name"`
+		Color string `json:"color"`
+	} `json:"labels"`
+	Milestone *struct {
+		DueOn  time.Time `json:"due_on"`
+		DueOn2 time.Time `json:"dueOn"`
+	} `json:"milestone"`
+	Comments json.RawMessage `json:"comments"`
+}
+
+// githubComment is a comment of an issue exported by the gh cli
+type githubComment struct {
+	ID     string `json:"id"`
+	Author struct {
+		Login string `json:"login"`
+	} `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// gitlabIssue is an issue of the json exported by the gitlab api, the notes are not part of the api response but
+// are read if they were added to the issue
+type gitlabIssue struct {
+	ID          json.RawMessage `json:"id"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	State       string          `json:"state"`
+	WebURL      string          `json:"web_url"`
+	CreatedAt   time.Time       `json:"created_at"`
+	ClosedAt    time.Time       `json:"closed_at"`
+	DueDate     string          `json:"due_date"`
+	Labels      json.RawMessage `json:"labels"`
+	Notes       []struct {
+		ID     json.RawMessage `json:"id"`
+		Body   string          `json:"body"`
+		System bool            `json:"system"`
+		Author struct {
+			Name     string `json:"name"`
+			Username string `json:"username"`
+		} `json:"author"`
+		CreatedAt time.Time `json:"created_at"`
+	} `json:"notes"`
+}
+
+// rawID converts an id that can be a json number or string into a string
+func rawID(id json.RawMessage) string {
+	return strings.Trim(string(id), `"`)
+}
+
+// firstTime returns the first of the given times that is not zero
+func firstTime(times ...time.Time) time.Time {
+	for _, t := range times {
+		if !t.IsZero() {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// issueStatus returns the status of an issue with the given state
+func issueStatus(state string) model.TodoStatus {
+	switch strings.ToLower(state) {
+	case "closed":
+		return model.STATUS_DONE
+	default:
+		return model.STATUS_NEW
+	}
+}
+
+// projectFromURL returns the name of the project of the issue with the given url, like repo in
+// https://github.com/owner/repo/issues/1 or https://gitlab.com/group/repo/-/issues/1
+func projectFromURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := len(parts) - 1; i > 0; i-- {
+		if parts[i] != "issues" {
+			continue
+		}
+		for j := i - 1; j >= 0; j-- {
+			if parts[j] != "-" {
+				return parts[j]
+			}
+		}
+	}
+	return ""
+}
+
+// issueBoard returns the board of the given issues, named by the given options or else by their project
+func issueBoard(options ImportOptions, fallback, url string, cards []foreignCard) foreignBoard {
+	name := options.Board
+	if name == "" {
+		name = projectFromURL(url)
+	}
+	if name == "" {
+		name = fallback
+	}
+	return foreignBoard{Name: name, Cards: cards}
+}
+
+// readGitHub converts a json array of github issues, skipping the pull requests
+func readGitHub(r io.Reader, options ImportOptions) (foreignBoard, error) {
+	var issues []githubIssue
+	if err := json.NewDecoder(r).Decode(&issues); err != nil {
+		return foreignBoard{}, errors.Wrap(err, "invalid github export")
+	}
+	cards := make([]foreignCard, 0, len(issues))
+	first := ""
+	for _, issue := range issues {
+		if len(issue.PullRequest) > 0 && string(issue.PullRequest) != "null" {
+			continue
+		}
+		link := issue.HTMLURL
+		if link == "" {
+			link = issue.URL
+		}
+		if first == "" {
+			first = link
+		}
+		card := foreignCard{
+			ID:          link,
+			Name:        issue.Title,
+			Description: issue.Body,
+			Status:      issueStatus(issue.State),
+			Created:     firstTime(issue.CreatedAt, issue.CreatedAt2),
+			Closed:      firstTime(issue.ClosedAt, issue.ClosedAt2),
+			Labels:      make([]foreignLabel, 0, len(issue.Labels)),
+		}
+		if card.ID == "" {
+			card.ID = rawID(issue.ID)
+		}
+		if card.ID == "" {
+			return foreignBoard{}, errors.Errorf("invalid github export: the issue %q has no id", issue.Title)
+		}
+		if issue.Milestone != nil {
+			card.Due = firstTime(issue.Milestone.DueOn, issue.Milestone.DueOn2)
+		}
+		for _, label := range issue.Labels {
+			card.Labels = append(card.Labels, foreignLabel{Name: label.Name, Colour: parseHexColour(label.Color)})
+		}
+		var comments []githubComment
+		if json.Unmarshal(issue.Comments, &comments) == nil {
+			for _, comment := range comments {
+				card.Comments = append(card.Comments, foreignComment{
+					ID:     comment.ID,
+					Author: comment.Author.Login,
+					Text:   comment.Body,
+					Date:   comment.CreatedAt,
+				})
+			}
+		}
+		cards = append(cards, card)
+	}
+	return issueBoard(options, "github", first, cards), nil
+}
+
+// readGitLab converts a json array of gitlab issues, skipping the system notes
+func readGitLab(r io.Reader, options ImportOptions) (foreignBoard, error) {
+	var issues []gitlabIssue
+	if err := json.NewDecoder(r).Decode(&issues); err != nil {
+		return foreignBoard{}, errors.Wrap(err, "invalid gitlab export")
+	}
+	cards := make([]foreignCard, 0, len(issues))
+	first := ""
+	for _, issue := range issues {
+		if first == "" {
+			first = issue.WebURL
+		}
+		card := foreignCard{
+			ID:          issue.WebURL,
+			Name:        issue.Title,
+			Description: issue.Description,
+			Status:      issueStatus(issue.State),
+			Created:     issue.CreatedAt,
+			Closed:      issue.ClosedAt,
+		}
+		if card.ID == "" {
+			card.ID = rawID(issue.ID)
+		}
+		if card.ID == "" {
+			return foreignBoard{}, errors.Errorf("invalid gitlab export: the issue %q has no id", issue.Title)
+		}
+		if issue.DueDate != "" {
+			due, err := model.ParseDateTime(issue.DueDate)
+			if err != nil {
+				return foreignBoard{}, errors.Wrapf(err, "invalid due date of the issue %q", issue.Title)
+			}
+			card.Due = due.Time()
+		}
+		var names []string
+		var labels []struct {
+			Name  string `json:"name"`
+			Color string `json:"color"`
+		}
+		if json.Unmarshal(issue.Labels, &names) == nil {
+			for _, name := range names {
+				card.Labels = append(card.Labels, foreignLabel{Name: name})
+			}
+		} else if json.Unmarshal(issue.Labels, &labels) == nil {
+			for _, label := range labels {
+				card.Labels = append(card.Labels, foreignLabel{Name: label.Name, Colour: parseHexColour(label.Color)})
+			}
+		}
+		for _, note := range issue.Notes {
+			if note.System {
+				continue
+			}
+			author := note.Author.Name
+			if author == "" {
+				author = note.Author.Username
+			}
+			card.Comments = append(card.Comments, foreignComment{
+				ID:     rawID(note.ID),
+				Author: author,
+				Text:   note.Body,
+				Date:   note.CreatedAt,
+			})
+		}
+		cards = append(cards, card)
+	}
+	return issueBoard(options, "gitlab", first, cards), nil
+}
+
+// ImportGitHub reads a json array of github issues into the store, as exported by the api or by
+// gh issue list --json, the issues imported before are updated
+func ImportGitHub(s store.Store, r io.Reader, options ImportOptions) (ImportResult, error) {
+	board, err := readGitHub(r, options)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	return newForeignImporter(s, "github", options).importBoards([]foreignBoard{board})
+}
+
+// ImportGitLab reads a json array of gitlab issues into the store, as exported by the api, the issues imported
+// before are updated
+func ImportGitLab(s store.Store, r io.Reader, options ImportOptions) (ImportResult, error) {
+	board, err := readGitLab(r, options)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	return newForeignImporter(s, "gitlab", options).importBoards([]foreignBoard{board})
+}
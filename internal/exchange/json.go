@@ -0,0 +1,121 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchange
+
+import (
+	"encoding/json"
+	"io"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/gofrs/uuid"
+)
+
+// JSONVersion is the version of the json documents written by ExportJSON
+const JSONVersion = 1
+
+// JSONTodo is a todo of a json document, with the id of its board
+type JSONTodo struct {
+	Board uuid.UUID       `json:"board"`
+	Todo  json.RawMessage `json:"todo"` // Either a todo or an agile todo, as stored in the repository
+}
+
+// JSONDocument is the json document written by ExportJSON, with the same models stored in the repository
+type JSONDocument struct {
+	Version int            `json:"version"`
+	Boards  []*model.Board `json:"boards"`
+	Tags    []*model.Tag   `json:"tags"`
+	Todos   []JSONTodo     `json:"todos"`
+}
+
+// ExportJSON writes the given todos, their boards and every tag as a json document that ImportJSON reads back
+func ExportJSON(s store.Store, w io.Writer, tasks []model.Task) error {
+	doc := &JSONDocument{Version: JSONVersion, Boards: make([]*model.Board, 0), Tags: s.Tags(), Todos: make([]JSONTodo, 0, len(tasks))}
+	boards := make(map[uuid.UUID]bool)
+	for _, task := range tasks {
+		board, err := s.BoardOf(task.Base().ID)
+		if err != nil {
+			return err
+		}
+		if !boards[board.ID] {
+			boards[board.ID] = true
+			doc.Boards = append(doc.Boards, board)
+		}
+		data, err := json.Marshal(task)
+		if err != nil {
+			return errors.Wrapf(err, "unable to encode todo %s", task.Base().Name)
+		}
+		doc.Todos = append(doc.Todos, JSONTodo{Board: board.ID, Todo: data})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// ImportJSON reads a json document written by ExportJSON into the store, the boards, tags and todos that already
+// exist are replaced by the ones of the document, and a todo stays in the board it already belongs to. The todos
+// without an id are created.
+func ImportJSON(s store.Store, r io.Reader) (result ImportResult, err error) {
+	doc := &JSONDocument{}
+	if err := json.NewDecoder(r).Decode(doc); err != nil {
+		return result, errors.Wrap(err, "invalid json document")
+	}
+	if doc.Version < 1 || doc.Version > JSONVersion {
+		return result, errors.Errorf("unsupported json document version %d", doc.Version)
+	}
+	for _, board := range doc.Boards {
+		if existing, err := s.Board(board.ID); err == nil {
+			existing.Name, existing.Description, existing.Colour = board.Name, board.Description, board.Colour
+			board = existing
+		}
+		if err := s.SaveBoard(board); err != nil {
+			return result, errors.Wrapf(err, "board %s", board.Name)
+		}
+	}
+	for _, tag := range doc.Tags {
+		if existing, err := s.Tag(tag.Name); err == nil {
+			existing.Colour = tag.Colour
+			tag = existing
+		}
+		if err := s.SaveTag(tag); err != nil {
+			return result, errors.Wrapf(err, "tag %s", tag.Name)
+		}
+	}
+	for i, item := range doc.Todos {
+		task, err := store.DecodeTask(item.Todo)
+		if err != nil {
+			return result, errors.Wrapf(err, "todo %d", i+1)
+		}
+		t := task.Base()
+		if t.ID == uuid.Nil {
+			t.ID, _ = uuid.NewV1()
+		}
+		if t.CreationDate.IsZero() {
+			t.CreationDate = model.Now()
+		}
+		board := item.Board
+		if owner, err := s.BoardOf(task.Base().ID); err == nil {
+			board = owner.ID
+			result.Updated++
+		} else {
+			result.Created++
+		}
+		if err := s.SaveTodo(board, task); err != nil {
+			return result, errors.Wrapf(err, "todo %s", task.Base().Name)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,212 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchange
+
+import (
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	texttemplate "text/template"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+)
+
+//go:embed templates
+var defaultTemplates embed.FS
+
+// ReportNote represents a note in a report
+type ReportNote struct {
+	Name        string
+	Description string
+	Author      string
+	Date        time.Time
+}
+
+// ReportTodo represents a todo in a report
+type ReportTodo struct {
+	ID          string
+	Name        string
+	Description string
+	Status      string
+	Priority    string
+	DueDate     time.Time
+	Tags        []string
+	Notes       []ReportNote
+	Agile       bool
+	Points      uint8
+	Estimated   time.Duration
+	Effort      time.Duration
+}
+
+// ReportPriorityGroup represents the todos of a report with the same status and priority
+type ReportPriorityGroup struct {
+	Priority string
+	Todos    []ReportTodo
+}
+
+// ReportStatusGroup represents the todos of a report with the same status, grouped by priority
+type ReportStatusGroup struct {
+	Status     string
+	Count      int
+	Priorities []ReportPriorityGroup
+}
+
+// Report is the data given to the report templates
+type Report struct {
+	Name        string              // The name of the board
+	Description string              // The description of the board
+	Colour      string              // The colour of the board, as a css hex colour
+	Generated   time.Time           // When the report was generated
+	Total       int                 // The number of todos
+	Statuses    []ReportStatusGroup // The todos grouped by status and then priority
+	Points      int                 // The sum of the points of the agile todos
+	Estimated   time.Duration       // The sum of the estimated durations of the agile todos
+	Effort      time.Duration       // The sum of the efforts of the agile todos
+}
+
+// NewReport builds the report of the given board and todos
+func NewReport(board *model.Board, tasks []model.Task) *Report {
+	report := &Report{
+		Name:        board.Name,
+		Description: board.Description,
+		Colour:      fmt.Sprintf("#%02x%02x%02x", board.Colour.R, board.Colour.G, board.Colour.B),
+		Generated:   time.Now(),
+		Total:       len(tasks),
+		Statuses:    make([]ReportStatusGroup, 0),
+	}
+	statuses := []model.TodoStatus{model.STATUS_STARTED, model.STATUS_PAUSED, model.STATUS_NEW, model.STATUS_FINISHED, model.STATUS_DONE}
+	for _, status := range statuses {
+		group := ReportStatusGroup{Status: status.Name(), Priorities: make([]ReportPriorityGroup, 0)}
+		for priority := model.PRIORITY_HIGHEST; priority >= model.PRIORITY_LOWEST; priority-- {
+			byPriority := ReportPriorityGroup{Priority: priority.Name(), Todos: make([]ReportTodo, 0)}
+			for _, task := range tasks {
+				if task.Base().Status == status && task.Base().Priority == priority {
+					byPriority.Todos = append(byPriority.Todos, report.newTodo(task))
+				}
+			}
+			if len(byPriority.Todos) > 0 {
+				group.Count += len(byPriority.Todos)
+				group.Priorities = append(group.Priorities, byPriority)
+			}
+		}
+		if group.Count > 0 {
+			report.Statuses = append(report.Statuses, group)
+		}
+	}
+	return report
+}
+
+// newTodo converts the given task for the report, adding its efforts to the totals of the report
+func (r *Report) newTodo(task model.Task) ReportTodo {
+	t := task.Base()
+	todo := ReportTodo{
+		ID:          t.ID.String(),
+		Name:        t.Name,
+		Description: t.Description,
+		Status:      t.Status.Name(),
+		Priority:    t.Priority.Name(),
+		DueDate:     t.DueDate.Time(),
+		Tags:        t.Tags,
+		Notes:       make([]ReportNote, 0),
+	}
+	t.Notes.Each(func(index int, note *model.Note) {
+		todo.Notes = append(todo.Notes, ReportNote{
+			Name:        note.Name,
+			Description: note.Description,
+			Author:      note.Author,
+			Date:        note.CreationDate.Time(),
+		})
+	})
+	sort.SliceStable(todo.Notes, func(i, j int) bool {
+		return todo.Notes[i].Date.Before(todo.Notes[j].Date)
+	})
+	if ag, ok := task.(*model.AgileTodo); ok {
+		todo.Agile = true
+		todo.Points = ag.Points
+		todo.Estimated = ag.EstimatedDuration
+		ag.Effort.Each(func(index int, effort *model.Effort) {
+			todo.Effort += effort.Duration
+		})
+		r.Points += int(ag.Points)
+		r.Estimated += ag.EstimatedDuration
+		r.Effort += todo.Effort
+	}
+	return todo
+}
+
+// reportFuncs are the functions available to the report templates
+var reportFuncs = map[string]any{
+	"date": func(value time.Time) string {
+		if value.IsZero() {
+			return ""
+		}
+		return value.Local().Format("2006-01-02 15:04")
+	},
+	"duration": func(value time.Duration) string {
+		return value.Round(time.Minute).String()
+	},
+}
+
+// readTemplate returns the template with the given name, from the given directory if it exists there, or else
+// the default one
+func readTemplate(dir, name string) (string, error) {
+	if dir != "" {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return string(data), nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", errors.Wrapf(err, "unable to read template %s", name)
+		}
+	}
+	data, err := defaultTemplates.ReadFile("templates/" + name)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to read the default template %s", name)
+	}
+	return string(data), nil
+}
+
+// ExportMarkdown writes the given report as markdown, using the report.md.tmpl template of the given directory if
+// it exists there
+func ExportMarkdown(w io.Writer, templateDir string, report *Report) error {
+	source, err := readTemplate(templateDir, "report.md.tmpl")
+	if err != nil {
+		return err
+	}
+	tmpl, err := texttemplate.New("report.md.tmpl").Funcs(reportFuncs).Parse(source)
+	if err != nil {
+		return errors.Wrap(err, "invalid markdown template")
+	}
+	return tmpl.Execute(w, report)
+}
+
+// ExportHTML writes the given report as html, using the report.html.tmpl template of the given directory if it
+// exists there
+func ExportHTML(w io.Writer, templateDir string, report *Report) error {
+	source, err := readTemplate(templateDir, "report.html.tmpl")
+	if err != nil {
+		return err
+	}
+	tmpl, err := htmltemplate.New("report.html.tmpl").Funcs(reportFuncs).Parse(source)
+	if err != nil {
+		return errors.Wrap(err, "invalid html template")
+	}
+	return tmpl.Execute(w, report)
+}
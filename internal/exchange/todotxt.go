@@ -0,0 +1,371 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package exchange converts the models from and to the formats used by other applications
+package exchange
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	date "github.com/bykof/gostradamus"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/gofrs/uuid"
+)
+
+const todoTxtDate = "2006-01-02"
+
+// TodoTxtItem represents a line of a todo.txt file
+type TodoTxtItem struct {
+	Done           bool              // True if the line starts with x
+	Priority       byte              // The priority letter, from A to Z, or zero if there is none
+	CompletionDate time.Time         // The optional completion date
+	CreationDate   time.Time         // The optional creation date
+	Text           string            // The text without the projects, contexts and extensions
+	Projects       []string          // The +project words
+	Contexts       []string          // The @context words
+	Extensions     map[string]string // The key:value words
+}
+
+// ParseTodoTxt parses a line of a todo.txt file
+func ParseTodoTxt(line string) (*TodoTxtItem, error) {
+	item := &TodoTxtItem{
+		Projects:   make([]string, 0),
+		Contexts:   make([]string, 0),
+		Extensions: make(map[string]string),
+	}
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return nil, errors.New("empty todo.txt line")
+	}
+	if words[0] == "x" {
+		item.Done = true
+		words = words[1:]
+	}
+	if len(words) > 0 && len(words[0]) == 3 && words[0][0] == '(' && words[0][2] == ')' && words[0][1] >= 'A' && words[0][1] <= 'Z' {
+		item.Priority = words[0][1]
+		words = words[1:]
+	}
+	// A completed task can have a completion date followed by a creation date
+	dates := make([]time.Time, 0, 2)
+	for len(words) > 0 && len(dates) < 2 {
+		parsed, err := time.ParseInLocation(todoTxtDate, words[0], time.Local)
+		if err != nil {
+			break
+		}
+		dates = append(dates, parsed)
+		words = words[1:]
+	}
+	switch {
+	case item.Done && len(dates) == 2:
+		item.CompletionDate, item.CreationDate = dates[0], dates[1]
+	case item.Done && len(dates) == 1:
+		item.CompletionDate = dates[0]
+	case len(dates) >= 1:
+		item.CreationDate = dates[0]
+		if len(dates) == 2 {
+			words = append([]string{dates[1].Format(todoTxtDate)}, words...)
+		}
+	}
+
+	text := make([]string, 0, len(words))
+	for _, word := range words {
+		switch {
+		case len(word) > 1 && word[0] == '+':
+			item.Projects = append(item.Projects, word[1:])
+		case len(word) > 1 && word[0] == '@':
+			item.Contexts = append(item.Contexts, word[1:])
+		case isExtension(word):
+			key, value, _ := strings.Cut(word, ":")
+			item.Extensions[key] = value
+		default:
+			text = append(text, word)
+		}
+	}
+	item.Text = strings.Join(text, " ")
+	if item.Text == "" {
+		return nil, errors.Errorf("todo.txt line without text: %q", line)
+	}
+	return item, nil
+}
+
+// isExtension checks if the given word is a key:value extension, urls are not extensions
+func isExtension(word string) bool {
+	key, value, found := strings.Cut(word, ":")
+	return found && key != "" && value != "" && !strings.Contains(key, "/") && !strings.HasPrefix(value, "//")
+}
+
+// String converts this item into a todo.txt line
+func (item *TodoTxtItem) String() string {
+	words := make([]string, 0)
+	if item.Done {
+		words = append(words, "x")
+	} else if item.Priority != 0 {
+		words = append(words, fmt.Sprintf("(%c)", item.Priority))
+	}
+	if item.Done && !item.CompletionDate.IsZero() {
+		words = append(words, item.CompletionDate.Format(todoTxtDate))
+	}
+	// The creation date of a completed task is only valid after its completion date
+	if !item.CreationDate.IsZero() && (!item.Done || !item.CompletionDate.IsZero()) {
+		words = append(words, item.CreationDate.Format(todoTxtDate))
+	}
+	words = append(words, item.Text)
+	for _, project := range item.Projects {
+		words = append(words, "+"+project)
+	}
+	for _, context := range item.Contexts {
+		words = append(words, "@"+context)
+	}
+	keys := make([]string, 0, len(item.Extensions))
+	for key := range item.Extensions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		words = append(words, key+":"+item.Extensions[key])
+	}
+	return strings.Join(words, " ")
+}
+
+// priorityToLetter converts a todo priority into a todo.txt priority, the normal priority has no letter. The letters
+// D and H to Z have no priority of their own, see letterToPriority.
+func priorityToLetter(priority model.TodoPriority) byte {
+	switch priority {
+	case model.PRIORITY_HIGHEST:
+		return 'A'
+	case model.PRIORITY_HIGHER:
+		return 'B'
+	case model.PRIORITY_HIGH:
+		return 'C'
+	case model.PRIORITY_LOW:
+		return 'E'
+	case model.PRIORITY_LOWER:
+		return 'F'
+	case model.PRIORITY_LOWEST:
+		return 'G'
+	}
+	return 0
+}
+
+// letterToPriority converts a todo.txt priority into a todo priority, D is the normal priority and every letter after
+// G is the lowest priority
+func letterToPriority(letter byte) model.TodoPriority {
+	switch {
+	case letter == 0:
+		return model.PRIORITY_NORMAL
+	case letter == 'A':
+		return model.PRIORITY_HIGHEST
+	case letter == 'B':
+		return model.PRIORITY_HIGHER
+	case letter == 'C':
+		return model.PRIORITY_HIGH
+	case letter == 'D':
+		return model.PRIORITY_NORMAL
+	case letter == 'E':
+		return model.PRIORITY_LOW
+	case letter == 'F':
+		return model.PRIORITY_LOWER
+	}
+	return model.PRIORITY_LOWEST
+}
+
+// todoTxtLetter returns the todo.txt priority of the given todo, which is the letter it was imported with while its
+// priority is still the one of that letter
+func todoTxtLetter(t *model.Todo) byte {
+	if len(t.PriorityLetter) == 1 && letterToPriority(t.PriorityLetter[0]) == t.Priority {
+		return t.PriorityLetter[0]
+	}
+	return priorityToLetter(t.Priority)
+}
+
+// projectName converts a board name into a todo.txt project, which can not have spaces
+func projectName(board string) string {
+	return strings.ReplaceAll(board, " ", "_")
+}
+
+// findOrCreateBoard returns the board referenced by the given todo.txt project, creating it if needed
+func findOrCreateBoard(s store.Store, project string) (*model.Board, error) {
+	for _, name := range []string{project, strings.ReplaceAll(project, "_", " ")} {
+		if items := s.BoardIndex().FindByName(name); len(items) > 0 {
+			return s.Board(items[0].ID)
+		}
+	}
+	board := model.NewBoard(project, color.RGBA{})
+	return board, s.SaveBoard(board)
+}
+
+// findOrCreateTag returns the tag with the given name, creating it if needed
+func findOrCreateTag(s store.Store, name string) error {
+	if _, err := s.Tag(name); err == nil {
+		return nil
+	}
+	return s.SaveTag(model.NewTag(name, color.RGBA{}))
+}
+
+// sameDay checks if the given dates are in the same day, so that a date from a file without a time does not
+// replace a more precise one
+func sameDay(a model.DateTime, b time.Time) bool {
+	ay, am, ad := a.Time().Local().Date()
+	by, bm, bd := b.Local().Date()
+	return !a.IsZero() && ay == by && am == bm && ad == bd
+}
+
+// ImportResult tells how many todos were created and updated by an import
+type ImportResult struct {
+	Created int
+	Updated int
+}
+
+// ImportTodoTxt reads the todos of a todo.txt file into the store, the todos without a project go to the board
+// with the given name. The lines exported with an id extension update the todo with that id.
+func ImportTodoTxt(s store.Store, r io.Reader, defaultBoard string) (result ImportResult, err error) {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		item, err := ParseTodoTxt(scanner.Text())
+		if err != nil {
+			return result, errors.Wrapf(err, "line %d", line)
+		}
+		created, err := importTodoTxtItem(s, item, defaultBoard)
+		if err != nil {
+			return result, errors.Wrapf(err, "line %d", line)
+		}
+		if created {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+	}
+	return result, scanner.Err()
+}
+
+// importTodoTxtItem saves the given item as a todo, returning if it was created
+func importTodoTxtItem(s store.Store, item *TodoTxtItem, defaultBoard string) (bool, error) {
+	project := defaultBoard
+	if len(item.Projects) > 0 {
+		project = item.Projects[0]
+	}
+	board, err := findOrCreateBoard(s, project)
+	if err != nil {
+		return false, err
+	}
+
+	var task model.Task
+	created := true
+	if id, err := uuid.FromString(item.Extensions["id"]); err == nil {
+		if existing, err := s.Todo(id); err == nil {
+			task, created = existing, false
+			if owner, _ := s.BoardOf(id); owner != nil {
+				board = owner
+			}
+		}
+	}
+	if task == nil {
+		task = model.NewTodo(item.Text)
+	}
+	t := task.Base()
+	t.Name = item.Text
+	letter := item.Priority
+	if pri, ok := item.Extensions["pri"]; ok && len(pri) == 1 {
+		letter = pri[0]
+	}
+	t.Priority = letterToPriority(letter)
+	// The letters that share a priority with another one are kept, so that they are exported again
+	t.PriorityLetter = ""
+	if letter != 0 && priorityToLetter(t.Priority) != letter {
+		t.PriorityLetter = string(letter)
+	}
+	if !item.CreationDate.IsZero() && !sameDay(t.CreationDate, item.CreationDate) {
+		t.CreationDate = model.DateTime{DateTime: date.DateTimeFromTime(item.CreationDate)}
+	}
+	t.Status = model.STATUS_NEW
+	if status, ok := item.Extensions["status"]; ok {
+		if t.Status, err = model.ParseTodoStatus(status); err != nil {
+			return false, err
+		}
+	}
+	if !item.Done {
+		t.CompleteDate = model.DateTime{}
+	} else {
+		t.Status = model.STATUS_DONE
+		if !item.CompletionDate.IsZero() && !sameDay(t.CompleteDate, item.CompletionDate) {
+			t.CompleteDate = model.DateTime{DateTime: date.DateTimeFromTime(item.CompletionDate)}
+		}
+	}
+	if due, ok := item.Extensions["due"]; ok {
+		if t.DueDate, err = model.ParseDateTime(due); err != nil {
+			return false, err
+		}
+	}
+	t.Tags = make([]string, 0, len(item.Contexts))
+	for _, context := range item.Contexts {
+		if err := findOrCreateTag(s, context); err != nil {
+			return false, err
+		}
+		t.AddTag(context)
+	}
+	return created, s.SaveTodo(board.ID, task)
+}
+
+// ExportTodoTxt writes the given todos as a todo.txt file, each line keeps the id of its todo so that it can be
+// imported again
+func ExportTodoTxt(s store.Store, w io.Writer, tasks []model.Task) error {
+	for _, task := range tasks {
+		item := NewTodoTxtItem(s, task)
+		if _, err := fmt.Fprintln(w, item.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewTodoTxtItem converts the given todo into a todo.txt item
+func NewTodoTxtItem(s store.Store, task model.Task) *TodoTxtItem {
+	t := task.Base()
+	item := &TodoTxtItem{
+		Done:         t.Status == model.STATUS_DONE,
+		Priority:     todoTxtLetter(t),
+		CreationDate: t.CreationDate.Time(),
+		Text:         strings.Join(strings.Fields(t.Name), " "),
+		Projects:     make([]string, 0),
+		Contexts:     make([]string, 0),
+		Extensions:   map[string]string{"id": t.ID.String()},
+	}
+	if item.Done {
+		item.CompletionDate = t.CompleteDate.Time()
+		if item.Priority != 0 {
+			item.Extensions["pri"] = string(item.Priority)
+		}
+	} else if t.Status != model.STATUS_NEW {
+		item.Extensions["status"] = t.Status.Name()
+	}
+	if !t.DueDate.IsZero() {
+		item.Extensions["due"] = t.DueDate.Time().Format(todoTxtDate)
+	}
+	if board, err := s.BoardOf(t.ID); err == nil {
+		item.Projects = append(item.Projects, projectName(board.Name))
+	}
+	item.Contexts = append(item.Contexts, t.Tags...)
+	return item
+}
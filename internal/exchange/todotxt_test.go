@@ -0,0 +1,154 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+package exchange
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/chordflower/todoman/internal/model"
+)
+
+func TestPriorityToLetter(t *testing.T) {
+	tests := []struct {
+		priority model.TodoPriority
+		letter   byte
+	}{
+		{model.PRIORITY_HIGHEST, 'A'},
+		{model.PRIORITY_HIGHER, 'B'},
+		{model.PRIORITY_HIGH, 'C'},
+		{model.PRIORITY_NORMAL, 0},
+		{model.PRIORITY_LOW, 'E'},
+		{model.PRIORITY_LOWER, 'F'},
+		{model.PRIORITY_LOWEST, 'G'},
+	}
+	for _, test := range tests {
+		if got := priorityToLetter(test.priority); got != test.letter {
+			t.Errorf("priority %s has the letter %q, expected %q", test.priority.Name(), got, test.letter)
+		}
+	}
+}
+
+func TestLetterToPriority(t *testing.T) {
+	tests := []struct {
+		letter   byte
+		priority model.TodoPriority
+	}{
+		{0, model.PRIORITY_NORMAL},
+		{'A', model.PRIORITY_HIGHEST},
+		{'B', model.PRIORITY_HIGHER},
+		{'C', model.PRIORITY_HIGH},
+		{'D', model.PRIORITY_NORMAL},
+		{'E', model.PRIORITY_LOW},
+		{'F', model.PRIORITY_LOWER},
+		{'G', model.PRIORITY_LOWEST},
+		{'H', model.PRIORITY_LOWEST},
+		{'Z', model.PRIORITY_LOWEST},
+	}
+	for _, test := range tests {
+		if got := letterToPriority(test.letter); got != test.priority {
+			t.Errorf("letter %q has the priority %s, expected %s", test.letter, got.Name(), test.priority.Name())
+		}
+	}
+}
+
+// exportedPriority returns the priority of the given todo.txt line, as its first word or its pri: extension
+func exportedPriority(line string) string {
+	for i, word := range strings.Fields(line) {
+		if i == 0 && strings.HasPrefix(word, "(") || strings.HasPrefix(word, "pri:") {
+			return word
+		}
+	}
+	return ""
+}
+
+func TestTodoTxtPriorityRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string             // The imported line
+		priority model.TodoPriority // The priority of the imported todo
+		letter   string             // The priority of the exported line, as its first word or its pri: extension
+	}{
+		{"without a priority", "call", model.PRIORITY_NORMAL, ""},
+		{"own letter", "(A) call", model.PRIORITY_HIGHEST, "(A)"},
+		{"normal letter", "(D) call", model.PRIORITY_NORMAL, "(D)"},
+		{"lowest letter", "(G) call", model.PRIORITY_LOWEST, "(G)"},
+		{"letter after lowest", "(H) call", model.PRIORITY_LOWEST, "(H)"},
+		{"last letter", "(Z) call", model.PRIORITY_LOWEST, "(Z)"},
+		{"done", "x call pri:Q", model.PRIORITY_LOWEST, "pri:Q"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, board := newTestStore(t)
+			if _, err := ImportTodoTxt(s, strings.NewReader(test.line+" +main"), board.Name); err != nil {
+				t.Fatal(err)
+			}
+			var first string
+			for i := 0; i < 3; i++ {
+				tasks, err := s.Todos(board.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(tasks) != 1 {
+					t.Fatalf("the board has %d todos after import %d, expected 1", len(tasks), i+1)
+				}
+				if got := tasks[0].Base().Priority; got != test.priority {
+					t.Errorf("import %d has the priority %s, expected %s", i+1, got.Name(), test.priority.Name())
+				}
+				var buffer bytes.Buffer
+				if err := ExportTodoTxt(s, &buffer, tasks); err != nil {
+					t.Fatal(err)
+				}
+				line := strings.TrimSpace(buffer.String())
+				if first == "" {
+					first = line
+				} else if line != first {
+					t.Errorf("export %d is %q, expected %q", i+1, line, first)
+				}
+				if got := exportedPriority(line); got != test.letter {
+					t.Errorf("export %d is %q, expected the priority %q", i+1, line, test.letter)
+				}
+				if _, err := ImportTodoTxt(s, &buffer, board.Name); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestTodoTxtChangedPriority(t *testing.T) {
+	s, board := newTestStore(t)
+	if _, err := ImportTodoTxt(s, strings.NewReader("(H) call +main"), board.Name); err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := s.Todos(board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The imported letter is not the one of the new priority, so the letter of the priority is exported
+	tasks[0].Base().Priority = model.PRIORITY_HIGH
+	if err := s.SaveTodo(board.ID, tasks[0]); err != nil {
+		t.Fatal(err)
+	}
+	var buffer bytes.Buffer
+	if err := ExportTodoTxt(s, &buffer, tasks); err != nil {
+		t.Fatal(err)
+	}
+	if line := buffer.String(); !strings.HasPrefix(line, "(C) ") {
+		t.Errorf("the export is %q, expected the priority (C)", line)
+	}
+}
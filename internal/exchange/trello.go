@@ -0,0 +1,225 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchange
+
+import (
+	"encoding/json"
+	"image/color"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/store"
+)
+
+// trelloBoard is the part of the json export of a trello board that is imported
+type trelloBoard struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Desc  string `json:"desc"`
+	Prefs struct {
+		BackgroundColor string `json:"backgroundColor"`
+	} `json:"prefs"`
+	Lists []struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Closed bool   `json:"closed"`
+	} `json:"lists"`
+	Cards []struct {
+		ID               string    `json:"id"`
+		Name             string    `json:"name"`
+		Desc             string    `json:"desc"`
+		Closed           bool      `json:"closed"`
+		IDList           string    `json:"idList"`
+		Due              time.Time `json:"due"`
+		DueComplete      bool      `json:"dueComplete"`
+		DateLastActivity time.Time `json:"dateLastActivity"`
+		Labels           []struct {
+			Name  string `json:"name"`
+			Color string `json:"color"`
+		} `json:"labels"`
+	} `json:"cards"`
+	Actions []struct {
+		ID   string    `json:"id"`
+		Type string    `json:"type"`
+		Date time.Time `json:"date"`
+		Data struct {
+			Text string `json:"text"`
+			Card struct {
+				ID string `json:"id"`
+			} `json:"card"`
+		} `json:"data"`
+		MemberCreator struct {
+			FullName string `json:"fullName"`
+			Username string `json:"username"`
+		} `json:"memberCreator"`
+	} `json:"actions"`
+}
+
+// trelloColours are the colours of the trello labels
+var trelloColours = map[string]color.RGBA{
+	"green":  {R: 0x61, G: 0xbd, B: 0x4f, A: 255},
+	"yellow": {R: 0xf2, G: 0xd6, B: 0x00, A: 255},
+	"orange": {R: 0xff, G: 0x9f, B: 0x1a, A: 255},
+	"red":    {R: 0xeb, G: 0x5a, B: 0x46, A: 255},
+	"purple": {R: 0xc3, G: 0x77, B: 0xe0, A: 255},
+	"blue":   {R: 0x00, G: 0x79, B: 0xbf, A: 255},
+	"sky":    {R: 0x00, G: 0xc2, B: 0xe0, A: 255},
+	"lime":   {R: 0x51, G: 0xe8, B: 0x98, A: 255},
+	"pink":   {R: 0xff, G: 0x78, B: 0xcb, A: 255},
+	"black":  {R: 0x34, G: 0x45, B: 0x63, A: 255},
+}
+
+// listStatuses are the words of the list names that tell the status of their cards
+var listStatuses = []struct {
+	word   string
+	status model.TodoStatus
+}{
+	{"done", model.STATUS_DONE},
+	{"complete", model.STATUS_DONE},
+	{"closed", model.STATUS_DONE},
+	{"review", model.STATUS_FINISHED},
+	{"test", model.STATUS_FINISHED},
+	{"finished", model.STATUS_FINISHED},
+	{"doing", model.STATUS_STARTED},
+	{"progress", model.STATUS_STARTED},
+	{"started", model.STATUS_STARTED},
+	{"blocked", model.STATUS_PAUSED},
+	{"hold", model.STATUS_PAUSED},
+	{"paused", model.STATUS_PAUSED},
+}
+
+// listStatus returns the status of the cards of the list with the given name, from the given statuses or else
+// guessed from the words of its name
+func listStatus(name string, statuses map[string]model.TodoStatus) model.TodoStatus {
+	for list, status := range statuses {
+		if strings.EqualFold(list, name) {
+			return status
+		}
+	}
+	lower := strings.ToLower(name)
+	for _, ls := range listStatuses {
+		if strings.Contains(lower, ls.word) {
+			return ls.status
+		}
+	}
+	return model.STATUS_NEW
+}
+
+// trelloDate returns the creation date of the trello model with the given id, whose first 8 digits are its
+// creation timestamp
+func trelloDate(id string) time.Time {
+	if len(id) != 24 {
+		return time.Time{}
+	}
+	seconds, err := strconv.ParseInt(id[:8], 16, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
+
+// readTrello converts the json export of a trello board, the archived cards and the cards of archived lists are
+// skipped
+func readTrello(r io.Reader, options ImportOptions) (foreignBoard, error) {
+	var tb trelloBoard
+	if err := json.NewDecoder(r).Decode(&tb); err != nil {
+		return foreignBoard{}, errors.Wrap(err, "invalid trello export")
+	}
+	if tb.ID == "" || tb.Name == "" {
+		return foreignBoard{}, errors.New("invalid trello export: the board has no id or name")
+	}
+	board := foreignBoard{
+		ID:          tb.ID,
+		Name:        tb.Name,
+		Description: tb.Desc,
+		Colour:      parseHexColour(tb.Prefs.BackgroundColor),
+		Cards:       make([]foreignCard, 0, len(tb.Cards)),
+	}
+
+	statuses := make(map[string]model.TodoStatus)
+	for _, list := range tb.Lists {
+		if !list.Closed {
+			statuses[list.ID] = listStatus(list.Name, options.Statuses)
+		}
+	}
+	comments := make(map[string][]foreignComment)
+	for _, action := range tb.Actions {
+		if action.Type != "commentCard" {
+			continue
+		}
+		author := action.MemberCreator.FullName
+		if author == "" {
+			author = action.MemberCreator.Username
+		}
+		comments[action.Data.Card.ID] = append(comments[action.Data.Card.ID], foreignComment{
+			ID:     action.ID,
+			Author: author,
+			Text:   action.Data.Text,
+			Date:   action.Date,
+		})
+	}
+
+	for _, tc := range tb.Cards {
+		status, ok := statuses[tc.IDList]
+		if tc.Closed || !ok {
+			continue
+		}
+		card := foreignCard{
+			ID:          tc.ID,
+			Name:        tc.Name,
+			Description: tc.Desc,
+			Status:      status,
+			Created:     trelloDate(tc.ID),
+			Due:         tc.Due,
+			Labels:      make([]foreignLabel, 0, len(tc.Labels)),
+			Comments:    comments[tc.ID],
+		}
+		if tc.DueComplete && status != model.STATUS_DONE {
+			card.Status = model.STATUS_DONE
+		}
+		if card.Status == model.STATUS_DONE {
+			card.Closed = tc.DateLastActivity
+		}
+		for _, label := range tc.Labels {
+			name := label.Name
+			if name == "" {
+				name = label.Color
+			}
+			if name != "" {
+				card.Labels = append(card.Labels, foreignLabel{Name: name, Colour: trelloColours[label.Color]})
+			}
+		}
+		sort.Slice(card.Comments, func(i, j int) bool {
+			return card.Comments[i].Date.Before(card.Comments[j].Date)
+		})
+		board.Cards = append(board.Cards, card)
+	}
+	return board, nil
+}
+
+// ImportTrello reads the json export of a trello board into the store, the board keeps its name and colour and the
+// cards become todos with the status of their list. The cards imported before are updated.
+func ImportTrello(s store.Store, r io.Reader, options ImportOptions) (ImportResult, error) {
+	board, err := readTrello(r, options)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	return newForeignImporter(s, "trello", options).importBoards([]foreignBoard{board})
+}
@@ -0,0 +1,282 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hook delivers the events of the store to the hooks of the configuration, which either run an executable
+// or post to an url
+package hook
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/store"
+	"github.com/chordflower/todoman/internal/utils"
+)
+
+const (
+	// FileName is the name of the file with the hooks, in the configuration directory
+	FileName = "hooks.json"
+	// LogName is the name of the delivery log, in the repository
+	LogName = "hooks.log"
+	// DefaultRetries is how many times a failed delivery is retried when the hook does not tell
+	DefaultRetries = 2
+	// Timeout is how long a delivery can take
+	Timeout = 30 * time.Second
+)
+
+// retryDelay is the delay before the first retry, which doubles on every retry
+var retryDelay = 500 * time.Millisecond
+
+// Hook is a script or url that receives the events of the store
+type Hook struct {
+	Name    string            `json:"name"`    // The name of the hook, unique in the configuration
+	Events  []string          `json:"events"`  // The events sent to the hook, like todo.created, todo.* or *
+	Command string            `json:"command"` // The executable that receives the event json on its input
+	Args    []string          `json:"args"`    // The arguments of the executable
+	URL     string            `json:"url"`     // The url the event json is posted to
+	Headers map[string]string `json:"headers"` // The extra headers of the posts
+	Retries *int              `json:"retries"` // How many times a failed delivery is retried
+}
+
+// Validate checks if this hook is valid
+func (h *Hook) Validate() error {
+	val := utils.NewValidator()
+	val.IsNotEmpty(h.Name, "The hook name must not be empty")
+	val.Check((h.Command == "") != (h.URL == ""), "The hook must have either a command or an url")
+	if h.URL != "" {
+		val.Check(strings.HasPrefix(h.URL, "http://") || strings.HasPrefix(h.URL, "https://"), "The hook url must be an http url")
+	}
+	val.Check(len(h.Events) > 0, "The hook must have at least one event")
+	val.Check(h.Retries == nil || *h.Retries >= 0, "The hook retries must not be negative")
+	return val.AllValid()
+}
+
+// Matches checks if the events of the given type are sent to this hook
+func (h *Hook) Matches(kind store.EventType) bool {
+	for _, pattern := range h.Events {
+		if pattern == "*" || pattern == string(kind) {
+			return true
+		}
+		if strings.HasSuffix(pattern, ".*") && strings.HasPrefix(string(kind), strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Load reads the hooks of the given configuration directory
+func Load(dir string) ([]*Hook, error) {
+	hooks := make([]*Hook, 0)
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return hooks, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "unable to read the hooks")
+	}
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		return nil, errors.Wrap(err, "unable to parse the hooks")
+	}
+	names := make(map[string]bool)
+	for _, h := range hooks {
+		if err := h.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid hook %s", h.Name)
+		}
+		if names[h.Name] {
+			return nil, errors.Errorf("there is more than one hook named %s", h.Name)
+		}
+		names[h.Name] = true
+	}
+	return hooks, nil
+}
+
+// Delivery is an attempt to deliver an event to a hook, as kept in the delivery log
+type Delivery struct {
+	Time     time.Time       `json:"time"`
+	Hook     string          `json:"hook"`
+	Event    string          `json:"event"`
+	Type     store.EventType `json:"type"`
+	Attempt  int             `json:"attempt"`
+	Success  bool            `json:"success"`
+	Result   string          `json:"result"` // The http status or the exit code
+	Error    string          `json:"error,omitempty"`
+	Duration time.Duration   `json:"duration"`
+}
+
+// Dispatcher delivers events to hooks, writing every attempt to a delivery log
+type Dispatcher struct {
+	hooks      []*Hook
+	repository string
+	client     *http.Client
+}
+
+// NewDispatcher creates a dispatcher of the given hooks, for the given repository which keeps the delivery log
+func NewDispatcher(hooks []*Hook, repository string) *Dispatcher {
+	return &Dispatcher{hooks: hooks, repository: repository, client: &http.Client{Timeout: Timeout}}
+}
+
+// Hooks returns the hooks of this dispatcher
+func (d *Dispatcher) Hooks() []*Hook {
+	return d.hooks
+}
+
+// Listen delivers the given event to every hook that wants it, once the change that caused it was committed and the
+// repository released, the failures are only reported since they must not stop the command that made the change
+func (d *Dispatcher) Listen(event *store.Event) {
+	for _, h := range d.hooks {
+		if h.Matches(event.Type) {
+			if err := d.Deliver(h, event); err != nil {
+				utils.Warning("The hook %s failed: %s", h.Name, err)
+			}
+		}
+	}
+}
+
+// Deliver sends the given event to the given hook, retrying with an increasing delay while it fails
+func (d *Dispatcher) Deliver(h *Hook, event *store.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode the event")
+	}
+	retries := DefaultRetries
+	if h.Retries != nil {
+		retries = *h.Retries
+	}
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		var result string
+		if h.Command != "" {
+			result, err = d.run(h, event, body)
+		} else {
+			result, err = d.post(h, event, body)
+		}
+		delivery := &Delivery{
+			Time:     start,
+			Hook:     h.Name,
+			Event:    event.ID.String(),
+			Type:     event.Type,
+			Attempt:  attempt,
+			Success:  err == nil,
+			Result:   result,
+			Duration: time.Since(start),
+		}
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		if logErr := d.log(delivery); logErr != nil {
+			utils.Warning("%s", logErr)
+		}
+		if err == nil || attempt > retries {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// run runs the executable of the given hook with the event on its input
+func (d *Dispatcher) run(h *Hook, event *store.Event, body []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	command := exec.CommandContext(ctx, h.Command, h.Args...)
+	command.Stdin = bytes.NewReader(body)
+	command.Env = append(os.Environ(),
+		"TODOMAN_EVENT="+string(event.Type),
+		"TODOMAN_EVENT_ID="+event.ID.String(),
+		"TODOMAN_REPOSITORY="+d.repository,
+	)
+	output, err := command.CombinedOutput()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			if text := strings.TrimSpace(string(output)); text != "" {
+				err = errors.Errorf("%s: %s", err, text)
+			}
+			return fmt.Sprintf("exit %d", exitErr.ExitCode()), err
+		}
+		return "", err
+	}
+	return "exit 0", nil
+}
+
+// post posts the event to the url of the given hook, any 2xx status is a success
+func (d *Dispatcher) post(h *Hook, event *store.Event, body []byte) (string, error) {
+	request, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "todoman")
+	request.Header.Set("X-Todoman-Event", string(event.Type))
+	request.Header.Set("X-Todoman-Delivery", event.ID.String())
+	for name, value := range h.Headers {
+		request.Header.Set(name, value)
+	}
+	response, err := d.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	result := fmt.Sprintf("http %d", response.StatusCode)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return result, errors.Errorf("the url answered %s", response.Status)
+	}
+	return result, nil
+}
+
+// log appends the given delivery to the delivery log
+func (d *Dispatcher) log(delivery *Delivery) error {
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode the delivery")
+	}
+	file, err := os.OpenFile(filepath.Join(d.repository, LogName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.Wrap(err, "unable to open the delivery log")
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	return errors.Wrap(err, "unable to write the delivery log")
+}
+
+// ReadLog reads the deliveries of the delivery log of the given repository, from the oldest to the newest
+func ReadLog(repository string) ([]*Delivery, error) {
+	deliveries := make([]*Delivery, 0)
+	file, err := os.Open(filepath.Join(repository, LogName))
+	if errors.Is(err, os.ErrNotExist) {
+		return deliveries, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "unable to open the delivery log")
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		delivery := &Delivery{}
+		if err := json.Unmarshal(scanner.Bytes(), delivery); err != nil {
+			return nil, errors.Wrap(err, "invalid delivery log")
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, scanner.Err()
+}
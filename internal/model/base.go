@@ -17,25 +17,35 @@ package model
 import (
 	"fmt"
 
-	date "github.com/bykof/gostradamus"
 	"github.com/gofrs/uuid"
 )
 
 // baseModel contains some shared fields for all models
 type baseModel struct {
-	ID           uuid.UUID     `json:"id"`            // An unique ID for the model
-	CreationDate date.DateTime `json:"creation_date"` // The creation date of the model
+	ID           uuid.UUID `json:"id"`            // An unique ID for the model
+	CreationDate DateTime  `json:"creation_date"` // The creation date of the model
 }
 
 func newBaseModel() *baseModel {
 	id, _ := uuid.NewV1()
 	return &baseModel{
 		ID:           id,
-		CreationDate: date.Now(),
+		CreationDate: Now(),
 	}
 }
 
+// Identity returns the id of this model
+func (b *baseModel) Identity() uuid.UUID {
+	return b.ID
+}
+
 type mmodel interface {
 	fmt.Stringer
 	Validate() error
 }
+
+// Task is implemented by every kind of todo, giving access to the shared todo fields
+type Task interface {
+	mmodel
+	Base() *Todo
+}
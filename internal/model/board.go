@@ -15,12 +15,12 @@
 package model
 
 import (
+	"encoding/json"
 	"fmt"
 	"image/color"
 
 	date "github.com/bykof/gostradamus"
 	"github.com/chordflower/todoman/internal/utils"
-	dll "github.com/emirpasic/gods/lists/doublylinkedlist"
 	"github.com/gofrs/uuid"
 )
 
@@ -30,7 +30,8 @@ type Board struct {
 	Name        string     `json:"name"`
 	Description string     `json:"description"`
 	Colour      color.RGBA `json:"colour"`
-	Todos       dll.List   `json:"-"`
+	// The todos of this board in their order, which are kept in their own files instead of the board json
+	Todos *Collection[*Todo] `json:"-"`
 }
 
 // NewBoard creates a new board with the given values
@@ -39,6 +40,7 @@ func NewBoard(name string, colour color.RGBA) (board *Board) {
 		baseModel: *newBaseModel(),
 		Name:      name,
 		Colour:    colour,
+		Todos:     NewCollection[*Todo](),
 	}
 	return
 }
@@ -49,48 +51,35 @@ func NewBoard2(name string, colour string) (board *Board) {
 		baseModel: *newBaseModel(),
 		Name:      name,
 		Colour:    color.RGBA{},
+		Todos:     NewCollection[*Todo](),
 	}
-	fmt.Sscanf(colour, "(%d,%d,%d,%d)", board.Colour.R, board.Colour.G, board.Colour.B, board.Colour.A)
+	fmt.Sscanf(colour, "(%d,%d,%d,%d)", &board.Colour.R, &board.Colour.G, &board.Colour.B, &board.Colour.A)
 	return
 }
 
 // AddTodo adds a new todo to this board
 func (b *Board) AddTodo(t *Todo) {
-	_, found := b.Todos.Find(func(index int, value any) bool {
-		t, err := value.(*Todo)
-		if !err && t.ID == t.ID {
-			return true
-		}
-		return false
-	})
-	if found == nil {
-		b.Todos.Add(t)
-	}
+	b.Todos.Add(t)
 }
 
 // RemoveTodo removes the todo with the given id from this board
 func (b *Board) RemoveTodo(id uuid.UUID) {
-	position, _ := b.Todos.Find(func(index int, value any) bool {
-		t, err := value.(*Todo)
-		if !err && t.ID == id {
-			return true
-		}
-		return false
-	})
-	if position != -1 {
-		b.Todos.Remove(position)
-	}
+	b.Todos.Remove(id)
 }
 
 // HasTodo checks if this board has a todo with the given id
 func (b *Board) HasTodo(id uuid.UUID) bool {
-	return b.Todos.Any(func(index int, value any) bool {
-		t, err := value.(*Todo)
-		if !err && t.ID == id {
-			return true
-		}
-		return false
-	})
+	return b.Todos.Has(id)
+}
+
+// UnmarshalJSON reads this board from json, starting without todos
+func (b *Board) UnmarshalJSON(data []byte) error {
+	type board Board
+	if err := json.Unmarshal(data, (*board)(b)); err != nil {
+		return err
+	}
+	b.Todos = NewCollection[*Todo]()
+	return nil
 }
 
 // Validate checks if this board is valid
@@ -0,0 +1,55 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+
+	"github.com/chordflower/todoman/internal/utils"
+	"github.com/gofrs/uuid"
+)
+
+// ChecklistItem represents a lightweight step of a todo, that can only be checked or unchecked
+type ChecklistItem struct {
+	ID      uuid.UUID `json:"id"`      // An unique ID for the item
+	Text    string    `json:"text"`    // The text of the item
+	Checked bool      `json:"checked"` // True if this item was done
+}
+
+// NewChecklistItem creates a new unchecked item with the given text
+func NewChecklistItem(text string) *ChecklistItem {
+	id, _ := uuid.NewV1()
+	return &ChecklistItem{
+		ID:      id,
+		Text:    text,
+		Checked: false,
+	}
+}
+
+// String returns a string representation of this item
+func (c *ChecklistItem) String() string {
+	mark := " "
+	if c.Checked {
+		mark = "x"
+	}
+	return fmt.Sprintf("[%s] %s", mark, c.Text)
+}
+
+// Validate checks if this item is valid
+func (c *ChecklistItem) Validate() error {
+	val := utils.NewValidator()
+	val.IsNotEmpty(c.Text, "The checklist item text must not be empty")
+	return val.AllValid()
+}
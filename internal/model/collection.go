@@ -0,0 +1,182 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/gofrs/uuid"
+)
+
+// Identifiable is implemented by the models that have an unique id
+type Identifiable interface {
+	Identity() uuid.UUID
+}
+
+// Collection is an ordered collection of models, which finds them by their id in constant time and is kept in json
+// as an array of the models in their order
+type Collection[T Identifiable] struct {
+	items     []T
+	positions map[uuid.UUID]int
+}
+
+// NewCollection creates a new collection with the given models, ignoring the repeated ones
+func NewCollection[T Identifiable](items ...T) *Collection[T] {
+	c := &Collection[T]{
+		items:     make([]T, 0, len(items)),
+		positions: make(map[uuid.UUID]int, len(items)),
+	}
+	for _, item := range items {
+		c.Add(item)
+	}
+	return c
+}
+
+// Add adds the given model at the end of this collection, returning false if there is already a model with its id
+func (c *Collection[T]) Add(item T) bool {
+	if c.Has(item.Identity()) {
+		return false
+	}
+	c.positions[item.Identity()] = len(c.items)
+	c.items = append(c.items, item)
+	return true
+}
+
+// Insert adds the given model in the given position of this collection, moving it there if it already belongs to
+// this collection
+func (c *Collection[T]) Insert(position int, item T) {
+	c.Remove(item.Identity())
+	if position < 0 {
+		position = 0
+	}
+	if position > len(c.items) {
+		position = len(c.items)
+	}
+	var zero T
+	c.items = append(c.items, zero)
+	copy(c.items[position+1:], c.items[position:])
+	c.items[position] = item
+	c.reindex(position)
+}
+
+// Remove removes the model with the given id, returning false if there is none
+func (c *Collection[T]) Remove(id uuid.UUID) bool {
+	position, ok := c.positions[id]
+	if !ok {
+		return false
+	}
+	c.items = append(c.items[:position], c.items[position+1:]...)
+	delete(c.positions, id)
+	c.reindex(position)
+	return true
+}
+
+// reindex updates the positions of the models starting at the given one
+func (c *Collection[T]) reindex(from int) {
+	for i := from; i < len(c.items); i++ {
+		c.positions[c.items[i].Identity()] = i
+	}
+}
+
+// Has checks if this collection has a model with the given id
+func (c *Collection[T]) Has(id uuid.UUID) bool {
+	_, ok := c.positions[id]
+	return ok
+}
+
+// Get returns the model with the given id, or false if there is none
+func (c *Collection[T]) Get(id uuid.UUID) (T, bool) {
+	position, ok := c.positions[id]
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	return c.items[position], true
+}
+
+// At returns the model in the given position, which must be between zero and the length of this collection
+func (c *Collection[T]) At(position int) T {
+	return c.items[position]
+}
+
+// Position returns the position of the model with the given id, or -1 if there is none
+func (c *Collection[T]) Position(id uuid.UUID) int {
+	if position, ok := c.positions[id]; ok {
+		return position
+	}
+	return -1
+}
+
+// Len returns the number of models of this collection
+func (c *Collection[T]) Len() int {
+	return len(c.items)
+}
+
+// Values returns the models of this collection in their order
+func (c *Collection[T]) Values() []T {
+	return append(make([]T, 0, len(c.items)), c.items...)
+}
+
+// Each calls the given function with each model of this collection and its position, in their order
+func (c *Collection[T]) Each(f func(index int, item T)) {
+	for i, item := range c.items {
+		f(i, item)
+	}
+}
+
+// Select returns the models of this collection that satisfy the given function, in their order
+func (c *Collection[T]) Select(f func(item T) bool) []T {
+	ret := make([]T, 0)
+	for _, item := range c.items {
+		if f(item) {
+			ret = append(ret, item)
+		}
+	}
+	return ret
+}
+
+// String returns the ids of the models of this collection
+func (c *Collection[T]) String() string {
+	ids := make([]string, 0, len(c.items))
+	for _, item := range c.items {
+		ids = append(ids, item.Identity().String())
+	}
+	return strings.Join(ids, ", ")
+}
+
+// MarshalJSON writes this collection as an array of its models
+func (c *Collection[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.items)
+}
+
+// UnmarshalJSON reads this collection from an array of models
+func (c *Collection[T]) UnmarshalJSON(data []byte) error {
+	items := make([]T, 0)
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	*c = *NewCollection[T]()
+	for _, item := range items {
+		if any(item) == nil {
+			continue
+		}
+		if !c.Add(item) {
+			return errors.Errorf("repeated id %s", item.Identity())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	date "github.com/bykof/gostradamus"
+)
+
+// dateLayouts are the layouts accepted when parsing a date given by the user
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// DateTime wraps a gostradamus date time, so that it can be converted from and to json
+type DateTime struct {
+	date.DateTime
+}
+
+// Now returns the current date time
+func Now() DateTime {
+	return DateTime{date.Now()}
+}
+
+// IsZero checks if this date time is not defined
+func (d DateTime) IsZero() bool {
+	return d.Time().IsZero()
+}
+
+// MarshalJSON converts this date time into a json string, an undefined date becomes an empty string
+func (d DateTime) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte(`""`), nil
+	}
+	return json.Marshal(d.Time().Format(time.RFC3339Nano))
+}
+
+// UnmarshalJSON reads this date time from a json string
+func (d *DateTime) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	if value == "" {
+		*d = DateTime{}
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return err
+	}
+	*d = DateTime{date.DateTimeFromTime(parsed)}
+	return nil
+}
+
+// ParseDateTime parses a date given by the user, either as an ISO 8601 date (with an optional time), today or
+// tomorrow, dates without a time are placed at the end of the day
+func ParseDateTime(value string) (DateTime, error) {
+	now := date.Now()
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "today":
+		return DateTime{now.CeilDay()}, nil
+	case "tomorrow":
+		return DateTime{now.ShiftDays(1).CeilDay()}, nil
+	}
+	for _, layout := range dateLayouts {
+		parsed, err := time.ParseInLocation(layout, value, time.Local)
+		if err != nil {
+			continue
+		}
+		result := date.DateTimeFromTime(parsed)
+		if layout == "2006-01-02" {
+			result = result.CeilDay()
+		}
+		return DateTime{result}, nil
+	}
+	return DateTime{}, errors.Errorf("invalid date %q, use YYYY-MM-DD or YYYY-MM-DD HH:mm", value)
+}
+
+// ParseDuration parses a duration, also accepting a number of days like 3d
+func ParseDuration(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err == nil {
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.Errorf("invalid duration %q, use something like 3d or 12h", value)
+	}
+	return duration, nil
+}
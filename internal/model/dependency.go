@@ -0,0 +1,160 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	// ErrDependencyCycle is returned when a dependency would make a todo (indirectly) depend on itself
+	ErrDependencyCycle = errors.Sentinel("the dependency would create a cycle")
+	// ErrUnknownTodo is returned when a dependency refers to a todo that does not exist
+	ErrUnknownTodo = errors.Sentinel("the todo does not exist")
+	// ErrTodoBlocked is returned when a todo is started while some of its dependencies are unfinished
+	ErrTodoBlocked = errors.Sentinel("the todo is blocked by unfinished todos")
+)
+
+// DependencyGraph represents the dependencies between a set of todos, possibly from different boards
+type DependencyGraph struct {
+	todos map[uuid.UUID]*Todo
+}
+
+// DependencyNode represents a todo and the todos it depends on, as a tree
+type DependencyNode struct {
+	Todo      *Todo             // The todo of this node
+	DependsOn []*DependencyNode // The todos this todo depends on
+	Repeated  bool              // True if the todo was already shown elsewhere in the tree
+}
+
+// NewDependencyGraph creates a new dependency graph with the given todos
+func NewDependencyGraph(todos ...*Todo) *DependencyGraph {
+	g := &DependencyGraph{
+		todos: make(map[uuid.UUID]*Todo, len(todos)),
+	}
+	for _, t := range todos {
+		g.todos[t.ID] = t
+	}
+	return g
+}
+
+// Get returns the todo with the given id, or nil if it is not in this graph
+func (g *DependencyGraph) Get(id uuid.UUID) *Todo {
+	return g.todos[id]
+}
+
+// Depend makes the todo with the given id depend on the other todo, failing if that would create a cycle
+func (g *DependencyGraph) Depend(id, on uuid.UUID) error {
+	todo, other := g.todos[id], g.todos[on]
+	if todo == nil || other == nil {
+		return ErrUnknownTodo
+	}
+	if id == on || g.reaches(on, id, make(map[uuid.UUID]bool)) {
+		return errors.Wrapf(ErrDependencyCycle, "%s already depends on %s", other.Name, todo.Name)
+	}
+	todo.AddDependency(on)
+	return nil
+}
+
+// reaches checks if the todo from can reach the todo to by following its dependencies
+func (g *DependencyGraph) reaches(from, to uuid.UUID, visited map[uuid.UUID]bool) bool {
+	if from == to {
+		return true
+	}
+	if visited[from] {
+		return false
+	}
+	visited[from] = true
+	todo := g.todos[from]
+	if todo == nil {
+		return false
+	}
+	for _, dep := range todo.DependsOn {
+		if g.reaches(dep, to, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// Blockers returns the todos that the todo with the given id depends on
+func (g *DependencyGraph) Blockers(id uuid.UUID) (ret []*Todo) {
+	ret = make([]*Todo, 0)
+	todo := g.todos[id]
+	if todo == nil {
+		return
+	}
+	for _, dep := range todo.DependsOn {
+		if other := g.todos[dep]; other != nil {
+			ret = append(ret, other)
+		}
+	}
+	return
+}
+
+// Blocks returns the todos that depend on the todo with the given id
+func (g *DependencyGraph) Blocks(id uuid.UUID) (ret []*Todo) {
+	ret = make([]*Todo, 0)
+	for _, t := range g.todos {
+		if t.HasDependency(id) {
+			ret = append(ret, t)
+		}
+	}
+	return
+}
+
+// CanStart checks if the todo with the given id has all of its dependencies finished
+func (g *DependencyGraph) CanStart(id uuid.UUID) error {
+	names := make([]string, 0)
+	for _, t := range g.Blockers(id) {
+		if !t.IsFinished() {
+			names = append(names, t.Name)
+		}
+	}
+	if len(names) > 0 {
+		return errors.Wrapf(ErrTodoBlocked, "waiting for %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// Tree returns the dependency tree of the todo with the given id, or nil if it is not in this graph
+func (g *DependencyGraph) Tree(id uuid.UUID) *DependencyNode {
+	return g.tree(id, make(map[uuid.UUID]bool))
+}
+
+func (g *DependencyGraph) tree(id uuid.UUID, visited map[uuid.UUID]bool) *DependencyNode {
+	todo := g.todos[id]
+	if todo == nil {
+		return nil
+	}
+	node := &DependencyNode{
+		Todo:      todo,
+		DependsOn: make([]*DependencyNode, 0),
+		Repeated:  visited[id],
+	}
+	if node.Repeated {
+		return node
+	}
+	visited[id] = true
+	for _, dep := range todo.DependsOn {
+		if child := g.tree(dep, visited); child != nil {
+			node.DependsOn = append(node.DependsOn, child)
+		}
+	}
+	return node
+}
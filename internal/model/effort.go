@@ -26,7 +26,7 @@ import (
 // Effort represents the effort a agile todo takes to finish
 type Effort struct {
 	ID          uuid.UUID     `json:"id"`          // An unique ID for the effort
-	Date        date.DateTime `json:"date"`        // The date of the effort
+	Date        DateTime      `json:"date"`        // The date of the effort
 	Duration    time.Duration `json:"duration"`    // The work duration
 	Description string        `json:"description"` // The work description
 }
@@ -36,16 +36,21 @@ func NewEffort(date date.DateTime, duration time.Duration) *Effort {
 	id, _ := uuid.NewV1()
 	return &Effort{
 		ID:          id,
-		Date:        date,
+		Date:        DateTime{date},
 		Duration:    duration,
 		Description: "",
 	}
 }
 
+// Identity returns the id of this effort
+func (e *Effort) Identity() uuid.UUID {
+	return e.ID
+}
+
 // String returns a string representation of this effort object
 func (e *Effort) String() string {
 	return fmt.Sprintf(`{
-    id: %d,
+    id: %s,
     date: %s,
     duration: %s
     description: %s
@@ -55,7 +60,7 @@ func (e *Effort) String() string {
 // Validate checks if this effort is valid
 func (e *Effort) Validate() error {
 	val := utils.NewValidator()
-	val.IsDateDefined(e.Date, "The effort date is not defined")
+	val.IsDateDefined(e.Date.DateTime, "The effort date is not defined")
 	val.IsPositive(e.Duration.Nanoseconds(), "The duration must not be zero")
 	return val.AllValid()
 }
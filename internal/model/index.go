@@ -1,10 +1,15 @@
 package model
 
 import (
-	dll "github.com/emirpasic/gods/lists/doublylinkedlist"
+	"encoding/json"
+	"strings"
+
 	"github.com/gofrs/uuid"
 )
 
+// MinShortID is the minimum length of the short ids and of the id prefixes that reference an item
+const MinShortID = 4
+
 // Item represents an index item
 type Item struct {
 	ID   uuid.UUID `json:"id"`
@@ -19,53 +24,148 @@ func NewItem(id uuid.UUID, name string) *Item {
 	}
 }
 
+// Identity returns the id of the model of this item
+func (i *Item) Identity() uuid.UUID {
+	return i.ID
+}
+
 // Index represents an item index
 type Index struct {
-	Items *dll.List `json:"items"`
+	Items *Collection[*Item] `json:"items"`
 }
 
 // NewIndex creates a new index
 func NewIndex() *Index {
 	return &Index{
-		Items: dll.New(),
+		Items: NewCollection[*Item](),
 	}
 }
 
 // AddItem adds the given item to this index
 func (i *Index) AddItem(item *Item) {
-	_, found := i.Items.Find(func(index int, value any) bool {
-		t, err := value.(*Item)
-		if !err && t.ID == item.ID {
-			return true
-		}
-		return false
-	})
-	if found == nil {
-		i.Items.Add(item)
-	}
+	i.Items.Add(item)
 }
 
 // RemoveItem removes the item with the given id from this index
 func (i *Index) RemoveItem(id uuid.UUID) {
-	position, _ := i.Items.Find(func(index int, value any) bool {
-		t, err := value.(*Item)
-		if !err && t.ID == id {
-			return true
+	i.Items.Remove(id)
+}
+
+// HasItem checks if the item with the given id belongs to this index
+func (i *Index) HasItem(id uuid.UUID) bool {
+	return i.Items.Has(id)
+}
+
+// GetItem returns the item with the given id, or nil if it does not belong to this index
+func (i *Index) GetItem(id uuid.UUID) *Item {
+	item, _ := i.Items.Get(id)
+	return item
+}
+
+// FindByName returns all of the items with the given name
+func (i *Index) FindByName(name string) []*Item {
+	return i.Items.Select(func(item *Item) bool {
+		return item.Name == name
+	})
+}
+
+// UnmarshalJSON reads this index from json, restoring its items
+func (i *Index) UnmarshalJSON(data []byte) error {
+	type index Index
+	if err := json.Unmarshal(data, (*index)(i)); err != nil {
+		return err
+	}
+	if i.Items == nil {
+		i.Items = NewCollection[*Item]()
+	}
+	return nil
+}
+
+// ShortID returns the shortest prefix of the id of the given item, with at least MinShortID characters, that no
+// other item of this index shares
+func (i *Index) ShortID(id uuid.UUID) string {
+	full := id.String()
+	length := MinShortID
+	i.Items.Each(func(index int, t *Item) {
+		if t.ID == id {
+			return
+		}
+		other := t.ID.String()
+		common := 0
+		for common < len(full) && full[common] == other[common] {
+			common++
+		}
+		if common+1 > length {
+			length = common + 1
 		}
-		return false
 	})
-	if position != -1 {
-		i.Items.Remove(position)
+	if length < len(full) && full[length-1] == '-' {
+		length++
 	}
+	if length > len(full) {
+		length = len(full)
+	}
+	return full[:length]
 }
 
-// HasItem checks if the item with the given id belongs to this index
-func (i *Index) HasItem(id uuid.UUID) bool {
-	return i.Items.Any(func(index int, value any) bool {
-		t, err := value.(*Item)
-		if !err && t.ID == id {
-			return true
+// FindByPrefix returns all of the items whose id starts with the given prefix, which must have at least
+// MinShortID characters
+func (i *Index) FindByPrefix(prefix string) []*Item {
+	prefix = strings.ToLower(prefix)
+	if len(prefix) < MinShortID {
+		return make([]*Item, 0)
+	}
+	return i.Items.Select(func(t *Item) bool {
+		return strings.HasPrefix(t.ID.String(), prefix)
+	})
+}
+
+// Match returns the items referenced by the given text, which is either an id, a name, an id prefix or a part of
+// a name. The first of these that matches any item wins, so that an exact name is never ambiguous with the
+// names that contain it. Names are compared ignoring the case before looking for the ones that contain the
+// text, and then the ones that contain its letters in order.
+func (i *Index) Match(ref string) []*Item {
+	if id, err := uuid.FromString(ref); err == nil {
+		if item := i.GetItem(id); item != nil {
+			return []*Item{item}
+		}
+	}
+	if items := i.FindByName(ref); len(items) > 0 {
+		return items
+	}
+	if items := i.FindByPrefix(ref); len(items) > 0 {
+		return items
+	}
+	lower := strings.ToLower(ref)
+	matchers := []func(name string) bool{
+		func(name string) bool { return name == lower },
+		func(name string) bool { return strings.Contains(name, lower) },
+		func(name string) bool { return isSubsequence(lower, name) },
+	}
+	for _, matches := range matchers {
+		items := i.Items.Select(func(t *Item) bool {
+			return matches(strings.ToLower(t.Name))
+		})
+		if len(items) > 0 {
+			return items
 		}
+	}
+	return make([]*Item, 0)
+}
+
+// isSubsequence checks if the letters of the given text appear in the given name in the same order
+func isSubsequence(text, name string) bool {
+	if text == "" {
 		return false
-	})
+	}
+	rest := []rune(text)
+	for _, r := range name {
+		if r == rest[0] {
+			rest = rest[1:]
+			if len(rest) == 0 {
+				return true
+			}
+		}
+	}
+	return false
 }
@@ -0,0 +1,74 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gofrs/uuid"
+)
+
+// newMatchIndex returns an index whose names and ids make every kind of reference match more than one item
+func newMatchIndex() *Index {
+	index := NewIndex()
+	for _, item := range [][2]string{
+		{"aaaa0000-0000-4000-8000-000000000001", "Write docs"},
+		{"aaaa1111-0000-4000-8000-000000000002", "write"},
+		{"bbbb0000-0000-4000-8000-000000000003", "Review"},
+		{"cccc0000-0000-4000-8000-000000000004", "Release notes"},
+		{"dddd0000-0000-4000-8000-000000000005", "aaaa"},
+		{"eeee0000-0000-4000-8000-000000000006", "bbbb0000-0000-4000-8000-000000000003"},
+	} {
+		index.AddItem(NewItem(uuid.Must(uuid.FromString(item[0])), item[1]))
+	}
+	return index
+}
+
+func TestIndexMatch(t *testing.T) {
+	tests := []struct {
+		name string
+		ref  string
+		want []string // The names of the matched items, in the order of the index
+	}{
+		{"full id before exact name", "bbbb0000-0000-4000-8000-000000000003", []string{"Review"}},
+		{"exact name before id prefix", "aaaa", []string{"aaaa"}},
+		{"exact name before case-insensitive name", "write", []string{"write"}},
+		{"id prefix", "aaaa1", []string{"write"}},
+		{"id prefix of many", "aaaa0", []string{"Write docs"}},
+		{"id prefix before name part", "cccc", []string{"Release notes"}},
+		{"too short id prefix", "ccc", []string{}},
+		{"case-insensitive exact name before name part", "WRITE", []string{"write"}},
+		{"case-insensitive exact name", "review", []string{"Review"}},
+		{"name part", "NOTES", []string{"Release notes"}},
+		{"ambiguous name part", "rit", []string{"Write docs", "write"}},
+		{"letters in order", "rlsnts", []string{"Release notes"}},
+		{"ambiguous letters in order", "ee", []string{"Review", "Release notes"}},
+		{"nothing", "xyz", []string{}},
+	}
+	index := newMatchIndex()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, item := range index.Match(test.ref) {
+				got = append(got, item.Name)
+			}
+			if strings.Join(got, "|") != strings.Join(test.want, "|") {
+				t.Errorf("%q matches %q, expected %q", test.ref, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+
+	date "github.com/bykof/gostradamus"
+	"github.com/chordflower/todoman/internal/utils"
+)
+
+// Milestone represents a goal of the repository, with an optional date by which it should be reached
+type Milestone struct {
+	baseModel
+	Name        string   `json:"name"`        // The name of the milestone, unique in the repository
+	Description string   `json:"description"` // The description of the milestone
+	DueDate     DateTime `json:"due_date"`    // The optional date by which the milestone should be reached
+}
+
+// NewMilestone creates a new milestone with the given name
+func NewMilestone(name string) *Milestone {
+	return &Milestone{
+		baseModel: *newBaseModel(),
+		Name:      name,
+	}
+}
+
+// Validate checks if this milestone is valid
+func (m *Milestone) Validate() error {
+	val := utils.NewValidator()
+	val.IsNotEmpty(m.Name, "The milestone name must not be empty")
+	return val.AllValid()
+}
+
+// String converts a milestone to string format
+func (m *Milestone) String() string {
+	due := ""
+	if !m.DueDate.IsZero() {
+		due = m.DueDate.Format(date.Iso8601TZ)
+	}
+	return fmt.Sprintf(`{
+    id: "%s",
+    creation_date: "%s",
+    name: "%s",
+    description: "%s",
+    due_date: "%s"
+  }`, m.ID, m.CreationDate.Format(date.Iso8601TZ), m.Name, m.Description, due)
+}
@@ -41,7 +41,7 @@ func NewNote(name, author string) *Note {
 // String returns a string representation of this note
 func (n *Note) String() string {
 	return fmt.Sprintf(`{
-    id: %d,
+    id: %s,
     creation_date: %s,
     name: %s,
     description: %s,
@@ -0,0 +1,49 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import "strings"
+
+// rankDigits are the digits of the ranks, in their order
+const rankDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// RankBetween returns a rank that sorts after the given previous rank and before the given next rank, an empty
+// previous rank is before every rank and an empty next rank after every rank. The ranks are compared as strings,
+// so that moving a todo only changes its own rank. The returned ranks never end in the first digit, which makes
+// sure that there is always a rank between any two of them.
+func RankBetween(prev, next string) string {
+	base := len(rankDigits)
+	rank := make([]byte, 0, len(prev)+1)
+	for i := 0; ; i++ {
+		low := 0
+		if i < len(prev) {
+			low = strings.IndexByte(rankDigits, prev[i])
+		}
+		high := base
+		if i < len(next) {
+			high = strings.IndexByte(rankDigits, next[i])
+		}
+		if low == high {
+			rank = append(rank, rankDigits[low])
+			continue
+		}
+		if middle := (low + high) / 2; middle > low {
+			return string(append(rank, rankDigits[middle]))
+		}
+		// The rank is already before next, so every following digit can go up to the last one
+		rank = append(rank, rankDigits[low])
+		next = ""
+	}
+}
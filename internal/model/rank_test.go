@@ -0,0 +1,97 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+// checkRank fails the test when the given rank does not sort between the given ranks or ends in the first digit
+func checkRank(t *testing.T, prev, next, rank string) {
+	t.Helper()
+	if rank <= prev || next != "" && rank >= next {
+		t.Errorf("rank %q is not between %q and %q", rank, prev, next)
+	}
+	if strings.HasSuffix(rank, rankDigits[:1]) {
+		t.Errorf("rank %q ends in the first digit", rank)
+	}
+}
+
+func TestRankBetween(t *testing.T) {
+	tests := []struct {
+		name string
+		prev string
+		next string
+		rank string
+	}{
+		{"first rank", "", "", "i"},
+		{"after the last", "i", "", "r"},
+		{"before the first", "", "i", "9"},
+		{"between", "a", "k", "f"},
+		{"adjacent digits", "a", "b", "ai"},
+		{"after the last digit", "z", "", "zi"},
+		{"before the second digit", "", "1", "0i"},
+		{"next extends prev", "a", "a1", "a0i"},
+		{"prev is longer", "az", "b", "azi"},
+		{"next is longer", "a", "ab", "a5"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rank := RankBetween(test.prev, test.next)
+			if rank != test.rank {
+				t.Errorf("got %q, expected %q", rank, test.rank)
+			}
+			checkRank(t, test.prev, test.next, rank)
+		})
+	}
+}
+
+func TestRankBetweenRepeated(t *testing.T) {
+	tests := []struct {
+		name   string
+		insert func(ranks []string) (string, string)
+	}{
+		{"at the start", func(ranks []string) (string, string) { return "", ranks[0] }},
+		{"at the end", func(ranks []string) (string, string) { return ranks[len(ranks)-1], "" }},
+		{"after the first", func(ranks []string) (string, string) { return ranks[0], ranks[1] }},
+		{"before the last", func(ranks []string) (string, string) {
+			return ranks[len(ranks)-2], ranks[len(ranks)-1]
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ranks := []string{RankBetween("", "")}
+			ranks = append(ranks, RankBetween(ranks[0], ""))
+			for i := 0; i < 200; i++ {
+				prev, next := test.insert(ranks)
+				rank := RankBetween(prev, next)
+				checkRank(t, prev, next, rank)
+				if t.Failed() {
+					return
+				}
+				// Keep the ranks sorted, which is the order of the todos
+				at := len(ranks)
+				for j, r := range ranks {
+					if rank < r {
+						at = j
+						break
+					}
+				}
+				ranks = append(ranks[:at], append([]string{rank}, ranks[at:]...)...)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	date "github.com/bykof/gostradamus"
+)
+
+// RecurrenceFrequency represents how often a recurring todo repeats
+type RecurrenceFrequency string
+
+const (
+	// FREQUENCY_DAILY repeats the todo every day
+	FREQUENCY_DAILY RecurrenceFrequency = "DAILY"
+	// FREQUENCY_WEEKLY repeats the todo every week
+	FREQUENCY_WEEKLY RecurrenceFrequency = "WEEKLY"
+	// FREQUENCY_MONTHLY repeats the todo every month
+	FREQUENCY_MONTHLY RecurrenceFrequency = "MONTHLY"
+	// FREQUENCY_YEARLY repeats the todo every year
+	FREQUENCY_YEARLY RecurrenceFrequency = "YEARLY"
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// Recurrence represents a schedule for repeating a todo, using a subset of the RFC 5545 RRULE, namely the
+// FREQ, INTERVAL, BYDAY (only for weekly rules) and UNTIL parts
+type Recurrence struct {
+	Frequency RecurrenceFrequency // How often the todo repeats
+	Interval  int                 // The number of periods between occurrences
+	Weekdays  []time.Weekday      // The days of the week of a weekly rule, empty to use the day of the todo
+	Until     DateTime            // The optional last date of an occurrence
+}
+
+// ParseRecurrence parses either an RRULE (like FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR) or one of daily, weekly,
+// monthly or yearly
+func ParseRecurrence(value string) (*Recurrence, error) {
+	r := &Recurrence{
+		Interval: 1,
+		Weekdays: make([]time.Weekday, 0),
+	}
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	switch strings.ToUpper(value) {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+		r.Frequency = RecurrenceFrequency(strings.ToUpper(value))
+		return r, nil
+	}
+	for _, part := range strings.Split(value, ";") {
+		key, val, found := strings.Cut(part, "=")
+		if !found {
+			return nil, errors.Errorf("invalid recurrence rule part %q", part)
+		}
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Frequency = RecurrenceFrequency(strings.ToUpper(val))
+		case "INTERVAL":
+			interval, err := strconv.Atoi(val)
+			if err != nil {
+				return nil, errors.Errorf("invalid recurrence interval %q", val)
+			}
+			r.Interval = interval
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				weekday, ok := weekdayNames[strings.ToUpper(day)]
+				if !ok {
+					return nil, errors.Errorf("invalid recurrence weekday %q", day)
+				}
+				r.Weekdays = append(r.Weekdays, weekday)
+			}
+		case "UNTIL":
+			until, err := parseRRuleDate(val)
+			if err != nil {
+				return nil, err
+			}
+			r.Until = until
+		default:
+			return nil, errors.Errorf("unsupported recurrence rule part %q", key)
+		}
+	}
+	return r, r.Validate()
+}
+
+// parseRRuleDate parses the date of an UNTIL part, in either the date or the date time format of RFC 5545
+func parseRRuleDate(value string) (DateTime, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			if layout == "20060102T150405Z" {
+				parsed, _ = time.Parse(layout, value)
+			}
+			return DateTime{date.DateTimeFromTime(parsed)}, nil
+		}
+	}
+	return DateTime{}, errors.Errorf("invalid recurrence until date %q", value)
+}
+
+// Validate checks if this recurrence is valid
+func (r *Recurrence) Validate() error {
+	switch r.Frequency {
+	case FREQUENCY_DAILY, FREQUENCY_WEEKLY, FREQUENCY_MONTHLY, FREQUENCY_YEARLY:
+	default:
+		return errors.Errorf("unsupported recurrence frequency %q", r.Frequency)
+	}
+	if r.Interval < 1 {
+		return errors.New("the recurrence interval must be positive")
+	}
+	if len(r.Weekdays) > 0 && r.Frequency != FREQUENCY_WEEKLY {
+		return errors.New("the recurrence weekdays can only be used with weekly rules")
+	}
+	return nil
+}
+
+// String returns this recurrence as an RRULE
+func (r *Recurrence) String() string {
+	parts := []string{"FREQ=" + string(r.Frequency)}
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+	if len(r.Weekdays) > 0 {
+		days := make([]string, 0, len(r.Weekdays))
+		for _, weekday := range r.Weekdays {
+			for name, day := range weekdayNames {
+				if day == weekday {
+					days = append(days, name)
+				}
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.Time().UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence of the series that starts at the given date, which is after the other
+// given date, and false if there are no more occurrences
+func (r *Recurrence) Next(start, after time.Time) (time.Time, bool) {
+	next := start
+	for n := 1; !next.After(after) || n == 1; n++ {
+		switch r.Frequency {
+		case FREQUENCY_DAILY:
+			next = start.AddDate(0, 0, n*r.Interval)
+		case FREQUENCY_WEEKLY:
+			next = r.nextWeekly(next)
+		case FREQUENCY_MONTHLY:
+			next = addMonths(start, n*r.Interval)
+		case FREQUENCY_YEARLY:
+			next = addMonths(start, 12*n*r.Interval)
+		default:
+			return time.Time{}, false
+		}
+		if !r.Until.IsZero() && next.After(r.Until.Time()) {
+			return time.Time{}, false
+		}
+	}
+	return next, true
+}
+
+// nextWeekly returns the next occurrence of a weekly rule, the weeks start at monday
+func (r *Recurrence) nextWeekly(after time.Time) time.Time {
+	if len(r.Weekdays) == 0 {
+		return after.AddDate(0, 0, 7*r.Interval)
+	}
+	// First look for a later day in the same week
+	offset := (int(after.Weekday()) + 6) % 7
+	for day := offset + 1; day < 7; day++ {
+		if r.hasWeekday(time.Weekday((day + 1) % 7)) {
+			return after.AddDate(0, 0, day-offset)
+		}
+	}
+	// Otherwise use the first day of the next week of the rule
+	weekStart := after.AddDate(0, 0, -offset+7*r.Interval)
+	for day := 0; day < 7; day++ {
+		if r.hasWeekday(time.Weekday((day + 1) % 7)) {
+			return weekStart.AddDate(0, 0, day)
+		}
+	}
+	return weekStart
+}
+
+func (r *Recurrence) hasWeekday(weekday time.Weekday) bool {
+	for _, day := range r.Weekdays {
+		if day == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// addMonths adds the given months to the given date, using the last day of the month when the day does not exist
+func addMonths(value time.Time, months int) time.Time {
+	first := time.Date(value.Year(), value.Month(), 1, value.Hour(), value.Minute(), value.Second(), value.Nanosecond(), value.Location())
+	first = first.AddDate(0, months, 0)
+	last := first.AddDate(0, 1, -1).Day()
+	day := value.Day()
+	if day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// MarshalJSON converts this recurrence into a json string with its RRULE
+func (r *Recurrence) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON reads this recurrence from a json string with an RRULE
+func (r *Recurrence) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	parsed, err := ParseRecurrence(value)
+	if err != nil {
+		return err
+	}
+	*r = *parsed
+	return nil
+}
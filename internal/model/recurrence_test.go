@@ -0,0 +1,138 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	tests := []struct {
+		value     string
+		frequency RecurrenceFrequency
+		interval  int
+		weekdays  []time.Weekday
+		until     time.Time
+		fails     bool
+	}{
+		{value: "daily", frequency: FREQUENCY_DAILY, interval: 1},
+		{value: "Monthly", frequency: FREQUENCY_MONTHLY, interval: 1},
+		{value: "FREQ=YEARLY", frequency: FREQUENCY_YEARLY, interval: 1},
+		{value: "RRULE:FREQ=DAILY;INTERVAL=3", frequency: FREQUENCY_DAILY, interval: 3},
+		{value: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", frequency: FREQUENCY_WEEKLY, interval: 2,
+			weekdays: []time.Weekday{time.Monday, time.Friday}},
+		{value: "freq=weekly;byday=su", frequency: FREQUENCY_WEEKLY, interval: 1,
+			weekdays: []time.Weekday{time.Sunday}},
+		{value: "FREQ=MONTHLY;UNTIL=20221231T235959Z", frequency: FREQUENCY_MONTHLY, interval: 1,
+			until: time.Date(2022, time.December, 31, 23, 59, 59, 0, time.UTC)},
+		{value: "FREQ=HOURLY", fails: true},
+		{value: "FREQ=DAILY;INTERVAL=0", fails: true},
+		{value: "FREQ=DAILY;INTERVAL=two", fails: true},
+		{value: "FREQ=DAILY;BYDAY=MO", fails: true},
+		{value: "FREQ=WEEKLY;BYDAY=XX", fails: true},
+		{value: "FREQ=DAILY;UNTIL=tomorrow", fails: true},
+		{value: "FREQ=DAILY;COUNT=3", fails: true},
+		{value: "FREQ", fails: true},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			r, err := ParseRecurrence(test.value)
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if r.Frequency != test.frequency || r.Interval != test.interval {
+				t.Errorf("got frequency %s every %d, expected %s every %d", r.Frequency, r.Interval, test.frequency,
+					test.interval)
+			}
+			weekdays := test.weekdays
+			if weekdays == nil {
+				weekdays = []time.Weekday{}
+			}
+			if !reflect.DeepEqual(r.Weekdays, weekdays) {
+				t.Errorf("got weekdays %v, expected %v", r.Weekdays, weekdays)
+			}
+			if test.until.IsZero() != r.Until.IsZero() || !test.until.IsZero() && !r.Until.Time().Equal(test.until) {
+				t.Errorf("got until %s, expected %s", r.Until.Time(), test.until)
+			}
+		})
+	}
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	day := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 9, 30, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		after time.Time
+		next  time.Time // The zero time when there are no more occurrences
+	}{
+		{"daily", "daily", day(2022, 10, 3), day(2022, 10, 3), day(2022, 10, 4)},
+		{"daily interval", "FREQ=DAILY;INTERVAL=3", day(2022, 10, 3), day(2022, 10, 7), day(2022, 10, 9)},
+		{"daily before start", "daily", day(2022, 10, 3), day(2022, 10, 1), day(2022, 10, 4)},
+		{"weekly", "weekly", day(2022, 10, 3), day(2022, 10, 3), day(2022, 10, 10)},
+		{"weekly same week", "FREQ=WEEKLY;BYDAY=MO,FR", day(2022, 10, 3), day(2022, 10, 3), day(2022, 10, 7)},
+		{"weekly next week", "FREQ=WEEKLY;BYDAY=MO,FR", day(2022, 10, 3), day(2022, 10, 7), day(2022, 10, 10)},
+		{"weekly interval", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", day(2022, 10, 3), day(2022, 10, 7),
+			day(2022, 10, 17)},
+		{"weekly sunday ends the week", "FREQ=WEEKLY;BYDAY=SU", day(2022, 10, 3), day(2022, 10, 3),
+			day(2022, 10, 9)},
+		{"weekly from sunday", "FREQ=WEEKLY;BYDAY=MO,SU", day(2022, 10, 9), day(2022, 10, 9), day(2022, 10, 10)},
+		{"monthly", "monthly", day(2022, 10, 15), day(2022, 10, 15), day(2022, 11, 15)},
+		{"monthly clamps the day", "monthly", day(2022, 1, 31), day(2022, 1, 31), day(2022, 2, 28)},
+		{"monthly keeps the day", "monthly", day(2022, 1, 31), day(2022, 2, 28), day(2022, 3, 31)},
+		{"monthly leap year", "monthly", day(2024, 1, 31), day(2024, 1, 31), day(2024, 2, 29)},
+		{"monthly to a shorter month", "FREQ=MONTHLY;INTERVAL=2", day(2022, 8, 31), day(2022, 8, 31),
+			day(2022, 10, 31)},
+		{"monthly interval clamps", "FREQ=MONTHLY;INTERVAL=3", day(2022, 8, 31), day(2022, 8, 31),
+			day(2022, 11, 30)},
+		{"yearly", "yearly", day(2022, 3, 1), day(2022, 3, 1), day(2023, 3, 1)},
+		{"yearly from a leap day", "yearly", day(2024, 2, 29), day(2024, 2, 29), day(2025, 2, 28)},
+		{"until", "FREQ=DAILY;UNTIL=20221005T235959Z", day(2022, 10, 3), day(2022, 10, 4), day(2022, 10, 5)},
+		{"after until", "FREQ=DAILY;UNTIL=20221005T235959Z", day(2022, 10, 3), day(2022, 10, 5), time.Time{}},
+		{"weekly after until", "FREQ=WEEKLY;BYDAY=MO,FR;UNTIL=20221009T000000Z", day(2022, 10, 3),
+			day(2022, 10, 7), time.Time{}},
+		{"monthly after until", "FREQ=MONTHLY;UNTIL=20220227T000000Z", day(2022, 1, 31), day(2022, 1, 31),
+			time.Time{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := ParseRecurrence(test.rule)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			next, ok := r.Next(test.start, test.after)
+			if test.next.IsZero() {
+				if ok {
+					t.Errorf("expected no more occurrences, got %s", next)
+				}
+				return
+			}
+			if !ok || !next.Equal(test.next) {
+				t.Errorf("got %s (%t), expected %s", next, ok, test.next)
+			}
+		})
+	}
+}
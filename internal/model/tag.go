@@ -0,0 +1,70 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	date "github.com/bykof/gostradamus"
+	"github.com/chordflower/todoman/internal/utils"
+)
+
+// Tag represents a label that can be attached to todos of any board
+type Tag struct {
+	baseModel
+	Name   string     `json:"name"`   // The name of the tag, unique in the repository
+	Colour color.RGBA `json:"colour"` // The colour used when showing the tag
+}
+
+// NewTag creates a new tag with the given values
+func NewTag(name string, colour color.RGBA) *Tag {
+	return &Tag{
+		baseModel: *newBaseModel(),
+		Name:      name,
+		Colour:    colour,
+	}
+}
+
+// NewTag2 creates a new tag with the given values, where the colour is in the (r,g,b,a) format
+func NewTag2(name string, colour string) *Tag {
+	tag := NewTag(name, color.RGBA{})
+	fmt.Sscanf(colour, "(%d,%d,%d,%d)", &tag.Colour.R, &tag.Colour.G, &tag.Colour.B, &tag.Colour.A)
+	return tag
+}
+
+// ColourToString returns a string representation of the current tag colour
+func (t *Tag) ColourToString() string {
+	return fmt.Sprintf("(%d,%d,%d,%d)", t.Colour.R, t.Colour.G, t.Colour.B, t.Colour.A)
+}
+
+// Validate checks if this tag is valid
+func (t *Tag) Validate() error {
+	val := utils.NewValidator()
+	val.IsNotEmpty(t.Name, "The tag name must not be empty")
+	val.Check(!strings.ContainsAny(t.Name, " \t\n,"), "The tag name must not contain spaces or commas")
+	return val.AllValid()
+}
+
+// String converts a tag to string format
+func (t *Tag) String() string {
+	return fmt.Sprintf(`{
+    id: "%s",
+    creation_date: "%s",
+    name: "%s",
+    colour: (%d,%d,%d,%d)
+  }`, t.ID, t.CreationDate.Format(date.Iso8601TZ), t.Name, t.Colour.R, t.Colour.G, t.Colour.B, t.Colour.A)
+}
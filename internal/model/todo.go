@@ -15,25 +15,37 @@
 package model
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
+	"emperror.dev/errors"
 	date "github.com/bykof/gostradamus"
 	"github.com/chordflower/todoman/internal/utils"
-	dll "github.com/emirpasic/gods/lists/doublylinkedlist"
 	"github.com/gofrs/uuid"
 )
 
 // Todo is the model for a todo/task
 type Todo struct {
 	baseModel
-	Name         string        `json:"name"`          // The name of the todo
-	Description  string        `json:"description"`   // A description for the todo
-	Status       TodoStatus    `json:"status"`        // The status of the todo
-	CompleteDate date.DateTime `json:"complete_date"` // An optional completion date of the todo
-	StartDate    date.DateTime `json:"start_date"`    // An optional start date of the todo
-	Priority     TodoPriority  `json:"priority"`      // The priority of the todo
-	Notes        *dll.List     `json:"notes"`         // The notes that this todo contains
+	Name         string             `json:"name"`                 // The name of the todo
+	Description  string             `json:"description"`          // A description for the todo
+	Status       TodoStatus         `json:"status"`               // The status of the todo
+	CompleteDate DateTime           `json:"complete_date"`        // An optional completion date of the todo
+	StartDate    DateTime           `json:"start_date"`           // An optional start date of the todo
+	DueDate      DateTime           `json:"due_date"`             // An optional deadline of the todo
+	Priority     TodoPriority       `json:"priority"`             // The priority of the todo
+	Notes        *Collection[*Note] `json:"notes"`                // The notes that this todo contains
+	DependsOn    []uuid.UUID        `json:"depends_on"`           // The ids of the todos that must be finished before this one starts
+	Parent       uuid.UUID          `json:"parent"`               // The id of the todo this one is a subtask of, if any
+	Checklist    []*ChecklistItem   `json:"checklist"`            // The checklist items of this todo
+	Tags         []string           `json:"tags"`                 // The names of the tags attached to this todo
+	Assignees    []string           `json:"assignees"`            // The names of the users assigned to this todo
+	Recurrence   *Recurrence        `json:"recurrence,omitempty"` // The optional schedule for repeating this todo
+	Rank         string             `json:"rank,omitempty"`       // The position of this todo in its board
+	// The todo.txt priority letter this todo was imported with, when it is not the one exported for its priority
+	PriorityLetter string `json:"priority_letter,omitempty"`
 }
 
 // NewTodo creates a new todo with the given name
@@ -44,73 +56,312 @@ func NewTodo(name string) *Todo {
 		Description: "",
 		Status:      STATUS_NEW,
 		Priority:    PRIORITY_NORMAL,
-		Notes:       dll.New(),
+		Notes:       NewCollection[*Note](),
+		DependsOn:   make([]uuid.UUID, 0),
+		Checklist:   make([]*ChecklistItem, 0),
+		Tags:        make([]string, 0),
+		Assignees:   make([]string, 0),
 	}
 }
 
+// NewSubtask creates a new todo with the given name, as a subtask of the given todo
+func NewSubtask(name string, parent *Todo) *Todo {
+	t := NewTodo(name)
+	t.Parent = parent.ID
+	return t
+}
+
+// Base returns this todo, so that it can be used as a task
+func (t *Todo) Base() *Todo {
+	return t
+}
+
 // AddNote adds the given note to this todo
 func (t *Todo) AddNote(n *Note) {
-	_, found := t.Notes.Find(func(index int, value any) bool {
-		t, err := value.(*Note)
-		if !err && t.ID == n.ID {
+	t.Notes.Add(n)
+}
+
+// RemoveNote removes the note with the given id from this todo
+func (t *Todo) RemoveNote(id uuid.UUID) {
+	t.Notes.Remove(id)
+}
+
+// HasNote checks if the note with the given id belongs to this todo
+func (t *Todo) HasNote(id uuid.UUID) bool {
+	return t.Notes.Has(id)
+}
+
+// AddDependency makes this todo depend on the todo with the given id
+func (t *Todo) AddDependency(id uuid.UUID) {
+	if !t.HasDependency(id) {
+		t.DependsOn = append(t.DependsOn, id)
+	}
+}
+
+// RemoveDependency removes the dependency on the todo with the given id
+func (t *Todo) RemoveDependency(id uuid.UUID) {
+	for i, dep := range t.DependsOn {
+		if dep == id {
+			t.DependsOn = append(t.DependsOn[:i], t.DependsOn[i+1:]...)
+			return
+		}
+	}
+}
+
+// HasDependency checks if this todo depends on the todo with the given id
+func (t *Todo) HasDependency(id uuid.UUID) bool {
+	for _, dep := range t.DependsOn {
+		if dep == id {
 			return true
 		}
-		return false
-	})
-	if found == nil {
-		t.Notes.Add(n)
 	}
+	return false
 }
 
-// RemoveNote removes the note with the given id from this todo
-func (t *Todo) RemoveNote(id uuid.UUID) {
-	position, _ := t.Notes.Find(func(index int, value any) bool {
-		t, err := value.(*Note)
-		if !err && t.ID == id {
+// AddTag attaches the tag with the given name to this todo
+func (t *Todo) AddTag(name string) {
+	if !t.HasTag(name) {
+		t.Tags = append(t.Tags, name)
+	}
+}
+
+// RemoveTag detaches the tag with the given name from this todo
+func (t *Todo) RemoveTag(name string) {
+	for i, tag := range t.Tags {
+		if tag == name {
+			t.Tags = append(t.Tags[:i], t.Tags[i+1:]...)
+			return
+		}
+	}
+}
+
+// HasTag checks if the tag with the given name is attached to this todo
+func (t *Todo) HasTag(name string) bool {
+	for _, tag := range t.Tags {
+		if tag == name {
 			return true
 		}
-		return false
-	})
-	if position != -1 {
-		t.Notes.Remove(position)
 	}
+	return false
 }
 
-// HasNote checks if the note with the given id belongs to this todo
-func (t *Todo) HasNote(id uuid.UUID) bool {
-	return t.Notes.Any(func(index int, value any) bool {
-		t, err := value.(*Note)
-		if !err && t.ID == id {
+// RenameTag renames the given tag of this todo, returning if the todo had the tag
+func (t *Todo) RenameTag(old, new string) bool {
+	for i, tag := range t.Tags {
+		if tag == old {
+			if t.HasTag(new) {
+				t.Tags = append(t.Tags[:i], t.Tags[i+1:]...)
+			} else {
+				t.Tags[i] = new
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Assign assigns the user with the given name to this todo
+func (t *Todo) Assign(name string) {
+	if !t.IsAssigned(name) {
+		t.Assignees = append(t.Assignees, name)
+	}
+}
+
+// Unassign removes the user with the given name from the assignees of this todo
+func (t *Todo) Unassign(name string) {
+	for i, assignee := range t.Assignees {
+		if assignee == name {
+			t.Assignees = append(t.Assignees[:i], t.Assignees[i+1:]...)
+			return
+		}
+	}
+}
+
+// IsAssigned checks if the user with the given name is assigned to this todo
+func (t *Todo) IsAssigned(name string) bool {
+	for _, assignee := range t.Assignees {
+		if assignee == name {
 			return true
 		}
+	}
+	return false
+}
+
+// IsSubtask checks if this todo is a subtask of another todo
+func (t *Todo) IsSubtask() bool {
+	return t.Parent != uuid.Nil
+}
+
+// AddChecklistItem adds a new checklist item with the given text to this todo
+func (t *Todo) AddChecklistItem(text string) *ChecklistItem {
+	item := NewChecklistItem(text)
+	t.Checklist = append(t.Checklist, item)
+	return item
+}
+
+// RemoveChecklistItem removes the checklist item at the given position (starting at 1)
+func (t *Todo) RemoveChecklistItem(position int) error {
+	if position < 1 || position > len(t.Checklist) {
+		return errors.Errorf("there is no checklist item %d", position)
+	}
+	t.Checklist = append(t.Checklist[:position-1], t.Checklist[position:]...)
+	return nil
+}
+
+// CheckItem checks or unchecks the checklist item at the given position (starting at 1)
+func (t *Todo) CheckItem(position int, checked bool) error {
+	if position < 1 || position > len(t.Checklist) {
+		return errors.Errorf("there is no checklist item %d", position)
+	}
+	t.Checklist[position-1].Checked = checked
+	return nil
+}
+
+// Progress returns how many of the subtasks and checklist items of this todo are done, and how many there are
+func (t *Todo) Progress(subtasks []*Todo) (done, total int) {
+	for _, sub := range subtasks {
+		total++
+		if sub.IsFinished() {
+			done++
+		}
+	}
+	for _, item := range t.Checklist {
+		total++
+		if item.Checked {
+			done++
+		}
+	}
+	return
+}
+
+// SetStatus changes the status of this todo, filling in the start and completion dates
+func (t *Todo) SetStatus(status TodoStatus) {
+	t.Status = status
+	if status == STATUS_STARTED && t.StartDate.IsZero() {
+		t.StartDate = Now()
+	}
+	if t.IsFinished() && t.CompleteDate.IsZero() {
+		t.CompleteDate = Now()
+	} else if !t.IsFinished() {
+		t.CompleteDate = DateTime{}
+	}
+}
+
+// NextOccurrence creates the next occurrence of this recurring todo, after it was done at the given date, the
+// new todo starts at the next date of the schedule that is not before the given date, and keeps the same distance
+// between its start and due dates. It returns false if this todo does not repeat or the schedule has ended.
+func (t *Todo) NextOccurrence(now time.Time) (*Todo, bool) {
+	if t.Recurrence == nil {
+		return nil, false
+	}
+	anchor := t.StartDate
+	if anchor.IsZero() {
+		anchor = t.DueDate
+	}
+	if anchor.IsZero() {
+		anchor = t.CreationDate
+	}
+	after := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Add(-time.Nanosecond)
+	if anchor.Time().After(after) {
+		after = anchor.Time()
+	}
+	next, ok := t.Recurrence.Next(anchor.Time(), after)
+	if !ok {
+		return nil, false
+	}
+
+	n := NewTodo(t.Name)
+	n.Description = t.Description
+	n.Priority = t.Priority
+	n.Tags = append(n.Tags, t.Tags...)
+	n.Assignees = append(n.Assignees, t.Assignees...)
+	for _, item := range t.Checklist {
+		n.AddChecklistItem(item.Text)
+	}
+	n.Recurrence = t.Recurrence
+	n.StartDate = DateTime{date.DateTimeFromTime(next)}
+	if !t.DueDate.IsZero() {
+		n.DueDate = DateTime{date.DateTimeFromTime(next.Add(t.DueDate.Time().Sub(anchor.Time())))}
+	}
+	return n, true
+}
+
+// IsOverdue checks if this todo has a due date before the given date and is not finished yet
+func (t *Todo) IsOverdue(now time.Time) bool {
+	return !t.DueDate.IsZero() && !t.IsFinished() && t.DueDate.Time().Before(now)
+}
+
+// IsDueWithin checks if this todo is not finished and is due between the given date and the given duration after it
+func (t *Todo) IsDueWithin(now time.Time, within time.Duration) bool {
+	if t.DueDate.IsZero() || t.IsFinished() {
 		return false
-	})
+	}
+	due := t.DueDate.Time()
+	return !due.Before(now) && !due.After(now.Add(within))
+}
+
+// IsFinished checks if this todo is either finished or done
+func (t *Todo) IsFinished() bool {
+	return t.Status == STATUS_FINISHED || t.Status == STATUS_DONE
 }
 
 // String returns a string representation of the todo
 func (t *Todo) String() string {
 	return fmt.Sprintf(`{
-      id: %d,
+      id: %s,
       creation_date: "%s",
       name: "%s",
       description: "%s",
       status: %d,
       complete_date: "%s",
       start_date: "%s",
+      due_date: "%s",
       priority: %d,
       notes: (%s)
   }`, t.ID, t.CreationDate.Format(date.Iso8601TZ),
 		t.Name, t.Description, t.Status, t.CompleteDate.Format(date.Iso8601TZ),
-		t.StartDate.Format(date.Iso8601TZ), t.Priority, t.Notes)
+		t.StartDate.Format(date.Iso8601TZ), t.DueDate.Format(date.Iso8601TZ), t.Priority, t.Notes)
 }
 
 // Validate checks if this task is valid
 func (t *Todo) Validate() error {
 	val := utils.NewValidator()
 	val.IsNotEmpty(t.Name, "The name must not be empty")
+	val.Check(!t.HasDependency(t.ID), "The todo must not depend on itself")
+	val.Check(t.Parent != t.ID, "The todo must not be a subtask of itself")
+	if t.Recurrence != nil {
+		val.Check(t.Recurrence.Validate() == nil, "The recurrence rule is not valid")
+	}
+	for _, item := range t.Checklist {
+		val.IsNotEmpty(item.Text, "The checklist item text must not be empty")
+	}
 	return val.AllValid()
 }
 
+// UnmarshalJSON reads this todo from json, restoring its notes
+func (t *Todo) UnmarshalJSON(data []byte) error {
+	type todo Todo
+	if err := json.Unmarshal(data, (*todo)(t)); err != nil {
+		return err
+	}
+	if t.Notes == nil {
+		t.Notes = NewCollection[*Note]()
+	}
+	if t.DependsOn == nil {
+		t.DependsOn = make([]uuid.UUID, 0)
+	}
+	if t.Checklist == nil {
+		t.Checklist = make([]*ChecklistItem, 0)
+	}
+	if t.Tags == nil {
+		t.Tags = make([]string, 0)
+	}
+	if t.Assignees == nil {
+		t.Assignees = make([]string, 0)
+	}
+	return nil
+}
+
 // TodoStatus represents the status of a todo
 type TodoStatus uint8
 
@@ -147,12 +398,113 @@ const (
 	PRIORITY_HIGHEST
 )
 
+var (
+	statusNames = map[TodoStatus]string{
+		STATUS_NEW:      "new",
+		STATUS_STARTED:  "started",
+		STATUS_PAUSED:   "paused",
+		STATUS_FINISHED: "finished",
+		STATUS_DONE:     "done",
+	}
+	priorityNames = map[TodoPriority]string{
+		PRIORITY_LOWEST:  "lowest",
+		PRIORITY_LOWER:   "lower",
+		PRIORITY_LOW:     "low",
+		PRIORITY_NORMAL:  "normal",
+		PRIORITY_HIGH:    "high",
+		PRIORITY_HIGHER:  "higher",
+		PRIORITY_HIGHEST: "highest",
+	}
+)
+
+// Name returns the name of this status
+func (s TodoStatus) Name() string {
+	return statusNames[s]
+}
+
+// TodoStatusNames returns the names of every status, from new to done
+func TodoStatusNames() []string {
+	names := make([]string, 0, len(statusNames))
+	for status := STATUS_NEW; status <= STATUS_DONE; status++ {
+		names = append(names, status.Name())
+	}
+	return names
+}
+
+// ParseTodoStatus returns the status with the given name
+func ParseTodoStatus(name string) (TodoStatus, error) {
+	for status, n := range statusNames {
+		if n == strings.ToLower(name) {
+			return status, nil
+		}
+	}
+	return STATUS_NEW, errors.Errorf("unknown status %q", name)
+}
+
+// Name returns the name of this priority
+func (p TodoPriority) Name() string {
+	return priorityNames[p]
+}
+
+// TodoPriorityNames returns the names of every priority, from the highest to the lowest
+func TodoPriorityNames() []string {
+	names := make([]string, 0, len(priorityNames))
+	for priority := PRIORITY_HIGHEST; priority >= PRIORITY_LOWEST; priority-- {
+		names = append(names, priority.Name())
+	}
+	return names
+}
+
+// ParseTodoPriority returns the priority with the given name
+func ParseTodoPriority(name string) (TodoPriority, error) {
+	for priority, n := range priorityNames {
+		if n == strings.ToLower(name) {
+			return priority, nil
+		}
+	}
+	return PRIORITY_NORMAL, errors.Errorf("unknown priority %q", name)
+}
+
+// CopyTask returns a deep copy of the given task with new ids for it, its notes and its efforts, and without a rank,
+// keeping everything else including the dates of the task and of its notes and efforts
+func CopyTask(task Task) (Task, error) {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to copy the todo")
+	}
+	var copied Task = &Todo{}
+	if _, agile := task.(*AgileTodo); agile {
+		copied = &AgileTodo{}
+	}
+	if err := json.Unmarshal(data, copied); err != nil {
+		return nil, errors.Wrap(err, "unable to copy the todo")
+	}
+	t := copied.Base()
+	t.ID = uuid.Must(uuid.NewV1())
+	t.Rank = ""
+	notes := NewCollection[*Note]()
+	t.Notes.Each(func(index int, note *Note) {
+		note.ID = uuid.Must(uuid.NewV1())
+		notes.Add(note)
+	})
+	t.Notes = notes
+	if ag, ok := copied.(*AgileTodo); ok {
+		efforts := NewCollection[*Effort]()
+		ag.Effort.Each(func(index int, effort *Effort) {
+			effort.ID = uuid.Must(uuid.NewV1())
+			efforts.Add(effort)
+		})
+		ag.Effort = efforts
+	}
+	return copied, nil
+}
+
 // AgileTodo represents a todo with some agile related fields
 type AgileTodo struct {
 	Todo
-	Points            uint8         `json:"points"`             // The estimation points of this agile todo
-	EstimatedDuration time.Duration `json:"estimated_duration"` // The estimated duration of this agile todo
-	Effort            *dll.List     `json:"efforts"`            // The actual effort in duration of this agile todo
+	Points            uint8                `json:"points"`             // The estimation points of this agile todo
+	EstimatedDuration time.Duration        `json:"estimated_duration"` // The estimated duration of this agile todo
+	Effort            *Collection[*Effort] `json:"efforts"`            // The actual effort in duration of this agile todo
 }
 
 // NewAgileTodo creates a new agile todo
@@ -160,118 +512,121 @@ func NewAgileTodo(name string) *AgileTodo {
 	return &AgileTodo{
 		Todo:   *NewTodo(name),
 		Points: 0,
-		Effort: dll.New(),
+		Effort: NewCollection[*Effort](),
 	}
 }
 
 // AddEffort adds the given effort to this agile todo, if the sum of all efforts for a day are not more than 24h.
 func (ag *AgileTodo) AddEffort(eff *Effort) bool {
-	_, found := ag.Effort.Find(func(index int, value any) bool {
-		t, err := value.(*Effort)
-		if !err && t.ID == eff.ID {
-			return true
-		}
+	if ag.HasEffort(eff.ID) {
 		return false
-	})
-	if found == nil {
-		ef := found.(*Effort)
-
-		// All efforts for the same year, month and day added together must not be more than 24 hours...
-		t := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
-		ag.Effort.Select(findEffortAtSameTime(ef)).Map(func(index int, value any) any {
-			return value.(*Effort).Duration
-		}).Each(func(index int, value any) {
-			t.Add(value.(time.Duration))
-		})
-		t.Add(eff.Duration)
+	}
 
-		// If they aren't than we add the effort to the list
-		if !t.After(time.Date(2022, time.January, 2, 0, 0, 0, 0, time.UTC)) {
-			ag.Effort.Add(eff)
-			return true
-		} else { // Else we return false and do jack
-			return false
-		}
-	} else {
+	// All efforts for the same year, month and day added together must not be more than 24 hours...
+	total := eff.Duration
+	for _, other := range ag.Effort.Select(findEffortAtSameTime(eff)) {
+		total += other.Duration
+	}
+
+	// If they aren't than we add the effort to the list
+	if total > 24*time.Hour {
 		return false
 	}
+	ag.Effort.Add(eff)
+	return true
 }
 
 // RemoveEffort removes the effort with the given id
 func (ag *AgileTodo) RemoveEffort(id uuid.UUID) {
-	position, _ := ag.Effort.Find(func(index int, value any) bool {
-		t, err := value.(*Effort)
-		if !err && t.ID == id {
-			return true
-		}
-		return false
-	})
-	if position != -1 {
-		ag.Effort.Remove(position)
-	}
+	ag.Effort.Remove(id)
 }
 
 // HasEffort checks if the effort with the given id is in this agile todo
 func (ag *AgileTodo) HasEffort(id uuid.UUID) bool {
-	return ag.Effort.Any(func(index int, value any) bool {
-		t, err := value.(*Effort)
-		if !err && t.ID == id {
-			return true
-		}
-		return false
-	})
+	return ag.Effort.Has(id)
 }
 
 // GetEffortsFor returns all of the efforts for the given year/month/day
 func (ag *AgileTodo) GetEffortsFor(date date.DateTime) (ret []*Effort) {
 	dateOnly := date.Copy().CeilDay()
-	ret = make([]*Effort, 0)
-	ag.Effort.Select(func(index int, value any) bool {
-		eff, err := value.(*Effort)
-		if !err && eff.Date.Copy().CeilDay().Time().Equal(dateOnly.Time()) {
-			return true
-		}
-		return false
-	}).Each(func(index int, value any) {
-		ret = append(ret, value.(*Effort))
+	return ag.Effort.Select(func(eff *Effort) bool {
+		return eff.Date.Copy().CeilDay().Time().Equal(dateOnly.Time())
 	})
-	return
 }
 
-func findEffortAtSameTime(ef *Effort) func(index int, value any) bool {
-	return func(index int, value any) bool {
-		t, err := value.(*Effort)
-		tmp := t.Date.Copy().CeilDay().Time()
-		tmp2 := ef.Date.Copy().CeilDay().Time()
-		if !err && tmp.Equal(tmp2) {
-			return true
-		}
-		return false
+func findEffortAtSameTime(ef *Effort) func(eff *Effort) bool {
+	return func(eff *Effort) bool {
+		return eff.Date.Copy().CeilDay().Time().Equal(ef.Date.Copy().CeilDay().Time())
+	}
+}
+
+// NextOccurrence creates the next occurrence of this recurring agile todo, keeping its estimations but not its efforts
+func (ag *AgileTodo) NextOccurrence(now time.Time) (*AgileTodo, bool) {
+	next, ok := ag.Todo.NextOccurrence(now)
+	if !ok {
+		return nil, false
 	}
+	return &AgileTodo{
+		Todo:              *next,
+		Points:            ag.Points,
+		EstimatedDuration: ag.EstimatedDuration,
+		Effort:            NewCollection[*Effort](),
+	}, true
 }
 
 // String returns a string representation of this agile todo
 func (ag *AgileTodo) String() string {
 	return fmt.Sprintf(`{
-      id: %d,
+      id: %s,
       creation_date: "%s",
       name: "%s",
       description: "%s",
       status: %d,
       complete_date: "%s",
       start_date: "%s",
+      due_date: "%s",
       priority: %d,
       notes: (%s),
       points: %d,
       effort: (%s)
   }`, ag.ID, ag.CreationDate.Format(date.Iso8601TZ),
 		ag.Name, ag.Description, ag.Status, ag.CompleteDate.Format(date.Iso8601TZ),
-		ag.StartDate.Format(date.Iso8601TZ), ag.Priority, ag.Notes, ag.Points, ag.Effort)
+		ag.StartDate.Format(date.Iso8601TZ), ag.DueDate.Format(date.Iso8601TZ), ag.Priority, ag.Notes, ag.Points, ag.Effort)
 }
 
 // Validate checks if this agile todo is valid
 func (ag *AgileTodo) Validate() error {
 	val := utils.NewValidator()
 	val.IsNotEmpty(ag.Name, "The name must not be empty")
+	val.Check(!ag.HasDependency(ag.ID), "The todo must not depend on itself")
+	val.Check(ag.Parent != ag.ID, "The todo must not be a subtask of itself")
+	if ag.Recurrence != nil {
+		val.Check(ag.Recurrence.Validate() == nil, "The recurrence rule is not valid")
+	}
+	for _, item := range ag.Checklist {
+		val.IsNotEmpty(item.Text, "The checklist item text must not be empty")
+	}
 	return val.AllValid()
 }
+
+// UnmarshalJSON reads this agile todo from json, restoring its notes and efforts
+func (ag *AgileTodo) UnmarshalJSON(data []byte) error {
+	if err := ag.Todo.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	aux := struct {
+		Points            uint8                `json:"points"`
+		EstimatedDuration time.Duration        `json:"estimated_duration"`
+		Effort            *Collection[*Effort] `json:"efforts"`
+	}{}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	ag.Points = aux.Points
+	ag.EstimatedDuration = aux.EstimatedDuration
+	ag.Effort = aux.Effort
+	if ag.Effort == nil {
+		ag.Effort = NewCollection[*Effort]()
+	}
+	return nil
+}
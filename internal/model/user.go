@@ -0,0 +1,71 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	date "github.com/bykof/gostradamus"
+	"github.com/chordflower/todoman/internal/utils"
+)
+
+// User represents a member of a repository, who can be assigned to todos of any board
+type User struct {
+	baseModel
+	Name        string `json:"name"`         // The login name of the user, unique in the repository
+	DisplayName string `json:"display_name"` // The name shown for the user
+	Email       string `json:"email"`        // The optional email address of the user
+}
+
+// NewUser creates a new user with the given values
+func NewUser(name, displayName, email string) *User {
+	return &User{
+		baseModel:   *newBaseModel(),
+		Name:        name,
+		DisplayName: displayName,
+		Email:       email,
+	}
+}
+
+// Label returns the display name of this user, or its name when it has none
+func (u *User) Label() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	return u.Name
+}
+
+// Validate checks if this user is valid
+func (u *User) Validate() error {
+	val := utils.NewValidator()
+	val.IsNotEmpty(u.Name, "The user name must not be empty")
+	val.Check(!strings.ContainsAny(u.Name, " \t\n,"), "The user name must not contain spaces or commas")
+	if u.Email != "" {
+		val.IsEmail(u.Email, "The email must be a valid email address")
+	}
+	return val.AllValid()
+}
+
+// String converts a user to string format
+func (u *User) String() string {
+	return fmt.Sprintf(`{
+    id: "%s",
+    creation_date: "%s",
+    name: "%s",
+    display_name: "%s",
+    email: "%s"
+  }`, u.ID, u.CreationDate.Format(date.Iso8601TZ), u.Name, u.DisplayName, u.Email)
+}
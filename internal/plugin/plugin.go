@@ -0,0 +1,117 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package plugin runs the todoman-<name> executables of the PATH as if they were todoman commands
+package plugin
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/config"
+)
+
+// Prefix is the prefix of the names of the plugin executables
+const Prefix = "todoman-"
+
+// Plugin is an executable that adds a command
+type Plugin struct {
+	Name string // The name of the command
+	Path string // The path of the executable
+}
+
+// Find returns the plugin of the command with the given name
+func Find(name string) (*Plugin, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, errors.Errorf("invalid plugin name %q", name)
+	}
+	path, err := exec.LookPath(Prefix + name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unknown plugin %s", name)
+	}
+	return &Plugin{Name: name, Path: path}, nil
+}
+
+// List returns the plugins of the PATH ordered by name, when two folders have a plugin with the same name only the
+// one of the first folder is used, as the shell does
+func List() []*Plugin {
+	plugins := make([]*Plugin, 0)
+	seen := make(map[string]bool)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+			if !strings.HasPrefix(name, Prefix) || len(name) == len(Prefix) || seen[name] {
+				continue
+			}
+			plugin, err := Find(strings.TrimPrefix(name, Prefix))
+			if err != nil {
+				continue
+			}
+			seen[name] = true
+			plugins = append(plugins, plugin)
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool {
+		return plugins[i].Name < plugins[j].Name
+	})
+	return plugins
+}
+
+// Environment returns the variables given to the plugins besides the ones of todoman: the repository, the
+// configuration directory and the todoman executable, which the plugins can run to read and write the models
+// with export json and import json
+func Environment(version string) ([]string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find the todoman executable")
+	}
+	return []string{
+		config.RepositoryEnv + "=" + cfg.Repository,
+		config.ConfigEnv + "=" + config.Dir(),
+		"TODOMAN_BIN=" + executable,
+		"TODOMAN_VERSION=" + version,
+	}, nil
+}
+
+// Run runs the given plugin with the given arguments, sharing the standard streams of todoman, and returns its
+// exit code
+func (p *Plugin) Run(version string, args []string) (int, error) {
+	env, err := Environment(version)
+	if err != nil {
+		return 1, err
+	}
+	command := exec.Command(p.Path, args...)
+	command.Stdin, command.Stdout, command.Stderr = os.Stdin, os.Stdout, os.Stderr
+	command.Env = append(append(os.Environ(), env...), "TODOMAN_PLUGIN="+p.Name)
+	if err := command.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		return 1, errors.Wrapf(err, "unable to run the plugin %s", p.Name)
+	}
+	return 0, nil
+}
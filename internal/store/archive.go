@@ -0,0 +1,121 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/gofrs/uuid"
+)
+
+// ArchiveDir is the directory of the repository that keeps the archived boards and todos, with the same layout as
+// the repository
+const ArchiveDir = "archive"
+
+func (s *jsonStore) Archive() (Store, error) {
+	return s.area(ArchiveDir)
+}
+
+func (s *jsonStore) ArchiveBoard(id uuid.UUID) error {
+	return s.transact(func(tx *transaction) error {
+		return s.stashBoard(tx, ArchiveDir, id)
+	})
+}
+
+func (s *jsonStore) ArchiveTodo(id uuid.UUID) error {
+	return s.transact(func(tx *transaction) error {
+		return s.stashTodo(tx, ArchiveDir, id)
+	})
+}
+
+func (s *jsonStore) RestoreBoard(id uuid.UUID) error {
+	return s.transact(func(tx *transaction) error {
+		return s.unstashBoard(tx, ArchiveDir, id)
+	})
+}
+
+func (s *jsonStore) RestoreTodo(id uuid.UUID) error {
+	return s.transact(func(tx *transaction) error {
+		return s.unstashTodo(tx, ArchiveDir, id)
+	})
+}
+
+// ArchiveTodoTree moves the todo with the given id and all of its subtasks to the archive, the subtasks of a todo
+// can only be archived together with it
+func ArchiveTodoTree(s Store, id uuid.UUID) error {
+	task, err := s.Todo(id)
+	if err != nil {
+		return err
+	}
+	if parent, err := s.Todo(task.Base().Parent); err == nil {
+		return errors.Errorf("todo %s is a subtask of %s, archive %s instead", task.Base().Name, parent.Base().Name,
+			parent.Base().Name)
+	}
+	return archiveTree(s, task)
+}
+
+func archiveTree(s Store, task model.Task) error {
+	for _, sub := range Subtasks(s, task.Base().ID) {
+		if err := archiveTree(s, sub); err != nil {
+			return err
+		}
+	}
+	return s.ArchiveTodo(task.Base().ID)
+}
+
+// RestoreTodoTree moves the archived todo with the given id and all of its archived subtasks out of the archive
+func RestoreTodoTree(s Store, id uuid.UUID) error {
+	archive, err := s.Archive()
+	if err != nil {
+		return err
+	}
+	return unstashTree(archive, id, "restore", s.RestoreTodo)
+}
+
+// AutoArchive archives the done todos that were completed before the given time, together with their subtasks
+// when all of them are done too, returning how many todos were archived
+func AutoArchive(s Store, before time.Time) (int, error) {
+	count := 0
+	for _, task := range s.AllTodos() {
+		t := task.Base()
+		if _, err := s.Todo(t.Parent); err == nil || !s.TodoIndex().HasItem(t.ID) {
+			continue
+		}
+		if t.Status != model.STATUS_DONE || t.CompleteDate.IsZero() || !t.CompleteDate.Time().Before(before) {
+			continue
+		}
+		size, done := treeDone(s, task)
+		if !done {
+			continue
+		}
+		if err := archiveTree(s, task); err != nil {
+			return count, err
+		}
+		count += size
+	}
+	return count, nil
+}
+
+// treeDone returns the number of todos of the tree of the given todo, and if all of them are done
+func treeDone(s Store, task model.Task) (int, bool) {
+	size, done := 1, task.Base().Status == model.STATUS_DONE
+	for _, sub := range Subtasks(s, task.Base().ID) {
+		n, d := treeDone(s, sub)
+		size, done = size+n, done && d
+	}
+	return size, done
+}
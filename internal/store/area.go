@@ -0,0 +1,185 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/gofrs/uuid"
+)
+
+// The areas of a json store are the directories of the repository, like the archive, that keep boards and todos
+// apart from the others, with the same layout as the repository
+
+// areaDir returns the directory of the copy of the board with the given id inside the given area
+func (s *jsonStore) areaDir(area string, id uuid.UUID) string {
+	return filepath.Join(s.root, area, "boards", id.String())
+}
+
+// exists checks if the given path exists
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// area returns the store of the given area
+func (s *jsonStore) area(area string) (Store, error) {
+	return NewJSONStore(filepath.Join(s.root, area))
+}
+
+// saveAreaTodo updates the todo through the store of the given area, in a transaction of this store so that it is
+// committed together with the other changes of the current batch
+func (s *jsonStore) saveAreaTodo(area string, board uuid.UUID, task model.Task) error {
+	a, err := s.area(area)
+	if err != nil {
+		return err
+	}
+	stash := a.(*jsonStore)
+	tx := s.begin()
+	stash.pending = tx
+	err = stash.SaveTodo(board, task)
+	stash.pending = nil
+	if err != nil {
+		return err
+	}
+	return s.commit(tx)
+}
+
+// stashBoard adds the moving of the board with the given id and all of its todos to the given area to the given
+// transaction
+func (s *jsonStore) stashBoard(tx *transaction, area string, id uuid.UUID) error {
+	board, err := s.Board(id)
+	if err != nil {
+		return err
+	}
+	stashed := s.areaDir(area, id)
+	if !exists(stashed) {
+		// The whole board is moved at once
+		tx.rename(s.boardDir(id), stashed)
+	} else {
+		// Some todos of the board are already in the area, so the others join them one by one
+		for _, todo := range board.Todos.Values() {
+			tx.rename(s.todoFile(id, todo.ID), filepath.Join(stashed, "todos", todo.ID.String()+".json"))
+		}
+		if err := tx.write(filepath.Join(stashed, "board.json"), board); err != nil {
+			return err
+		}
+		tx.remove(s.boardDir(id))
+	}
+	s.forget(board)
+	return tx.write(filepath.Join(s.root, "index.json"), s.boardIndex)
+}
+
+// stashTodo adds the moving of the todo with the given id to the given area, into a copy of its board, to the given
+// transaction
+func (s *jsonStore) stashTodo(tx *transaction, area string, id uuid.UUID) error {
+	board, err := s.BoardOf(id)
+	if err != nil {
+		return err
+	}
+	stashed := s.areaDir(area, board.ID)
+	if err := tx.write(filepath.Join(stashed, "board.json"), board); err != nil {
+		return err
+	}
+	tx.rename(s.todoFile(board.ID, id), filepath.Join(stashed, "todos", id.String()+".json"))
+	board.RemoveTodo(id)
+	s.forgetTodo(id)
+	return tx.write(filepath.Join(s.boardDir(board.ID), "index.json"), s.boardTodoIndex(board))
+}
+
+// unstashBoard adds the moving of the board with the given id and all of its todos out of the given area to the
+// given transaction
+func (s *jsonStore) unstashBoard(tx *transaction, area string, id uuid.UUID) error {
+	stashed := s.areaDir(area, id)
+	if !exists(stashed) {
+		return errors.Wrapf(ErrNotFound, "board %s in the %s", id, area)
+	}
+	board, ok := s.boards[id]
+	if !ok {
+		// The whole board is moved back at once
+		if _, err := s.loadBoard(stashed); err != nil {
+			return err
+		}
+		tx.rename(stashed, s.boardDir(id))
+		return tx.write(filepath.Join(s.root, "index.json"), s.boardIndex)
+	}
+	// Only some todos of the board are in the area, so they go back one by one
+	entries, err := os.ReadDir(filepath.Join(stashed, "todos"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrapf(err, "unable to read the todos of board %s in the %s", board.Name, area)
+	}
+	for _, entry := range entries {
+		todo, err := uuid.FromString(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		if err := s.unstashTodoFile(tx, area, board, todo); err != nil {
+			return err
+		}
+	}
+	tx.remove(stashed)
+	return tx.write(filepath.Join(s.boardDir(id), "index.json"), s.boardTodoIndex(board))
+}
+
+// unstashTodo adds the moving of the todo with the given id out of the given area, back to its board, to the given
+// transaction
+func (s *jsonStore) unstashTodo(tx *transaction, area string, id uuid.UUID) error {
+	matches, err := filepath.Glob(filepath.Join(s.root, area, "boards", "*", "todos", id.String()+".json"))
+	if err != nil || len(matches) == 0 {
+		return errors.Wrapf(ErrNotFound, "todo %s in the %s", id, area)
+	}
+	stashed := filepath.Dir(filepath.Dir(matches[0]))
+	board, ok := s.boards[uuid.FromStringOrNil(filepath.Base(stashed))]
+	if !ok {
+		// The board is in the area too, so it comes back without its other todos
+		board = &model.Board{}
+		if err := readJSON(filepath.Join(stashed, "board.json"), board); err != nil {
+			return err
+		}
+		if err := s.saveBoard(tx, board); err != nil {
+			return err
+		}
+	}
+	if err := s.unstashTodoFile(tx, area, board, id); err != nil {
+		return err
+	}
+	// The copy of the board goes away with its last todo
+	if entries, err := os.ReadDir(filepath.Join(stashed, "todos")); err == nil && len(entries) == 1 {
+		tx.remove(stashed)
+	}
+	return tx.write(filepath.Join(s.boardDir(board.ID), "index.json"), s.boardTodoIndex(board))
+}
+
+// unstashTodoFile adds the moving of the todo with the given id from the given area back to the given board to the
+// given transaction, placing it in the position of its rank
+func (s *jsonStore) unstashTodoFile(tx *transaction, area string, board *model.Board, id uuid.UUID) error {
+	file := filepath.Join(s.areaDir(area, board.ID), "todos", id.String()+".json")
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return errors.Wrap(err, "unable to read todo")
+	}
+	task, err := DecodeTask(data)
+	if err != nil {
+		return errors.Wrapf(err, "unable to parse todo %s", id)
+	}
+	tx.rename(file, s.todoFile(board.ID, id))
+	s.attach(board, task)
+	s.place(board, task.Base())
+	return nil
+}
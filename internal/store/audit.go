@@ -0,0 +1,133 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/gofrs/uuid"
+)
+
+// AuditFile is the file of the repository that keeps who made every change to it, which is only ever appended to,
+// even when the changes are undone
+const AuditFile = "audit.jsonl"
+
+// AuditEntry records who changed a model, when and how
+type AuditEntry struct {
+	Time    time.Time         `json:"time"`              // When it changed
+	Actor   string            `json:"actor"`             // Who changed it
+	Line    string            `json:"line"`              // The command line of the command that changed it
+	Type    EventType         `json:"type"`              // What changed, like the event of the change
+	Board   uuid.UUID         `json:"board"`             // The board of the changed model before the change, if any
+	Area    string            `json:"area,omitempty"`    // The area of the changed todo, like the archive, if any
+	Model   uuid.UUID         `json:"model"`             // The id of the changed model
+	Name    string            `json:"name"`              // The name of the changed model
+	Changes map[string]Change `json:"changes,omitempty"` // The json values of the fields that changed
+}
+
+// newAuditEntry creates the audit entry of the given revision, made by the given actor
+func newAuditEntry(r *Revision, actor string) *AuditEntry {
+	entry := &AuditEntry{
+		Time:    r.Time,
+		Actor:   actor,
+		Line:    r.Line,
+		Type:    r.Type,
+		Board:   r.Board,
+		Area:    r.Area,
+		Model:   r.Model,
+		Changes: diff(r.Before, r.After),
+	}
+	for _, state := range []json.RawMessage{r.After, r.Before} {
+		model := struct {
+			Name string `json:"name"`
+		}{}
+		if json.Unmarshal(state, &model) == nil && model.Name != "" {
+			entry.Name = model.Name
+			break
+		}
+	}
+	return entry
+}
+
+// diff returns the changes of the fields of the json objects of a model before and after a change, there are none
+// when the model was created or removed
+func diff(before, after json.RawMessage) map[string]Change {
+	if len(before) == 0 || len(after) == 0 {
+		return nil
+	}
+	fields := func(data json.RawMessage) map[string]json.RawMessage {
+		values := make(map[string]json.RawMessage)
+		json.Unmarshal(data, &values)
+		return values
+	}
+	old, new := fields(before), fields(after)
+	changes := make(map[string]Change)
+	for name, value := range old {
+		if !sameJSON(value, new[name]) {
+			changes[name] = Change{From: value, To: new[name]}
+		}
+	}
+	for name, value := range new {
+		if _, ok := old[name]; !ok {
+			changes[name] = Change{From: nil, To: value}
+		}
+	}
+	return changes
+}
+
+// sameJSON checks if the given json values are the same, ignoring their spacing
+func sameJSON(a, b json.RawMessage) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
+
+// appendLine appends the json encoding of the given value as a line of the log file at the given path, through the
+// given store so that it is written together with the changes of its current batch
+func appendLine(s Store, path string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return errors.Wrapf(err, "unable to encode a line of %s", path)
+	}
+	return s.writeLog(path, -1, append(data, '\n'))
+}
+
+// ReadAudit reads the entries of the audit file at the given path, from the oldest to the newest
+func ReadAudit(path string) ([]*AuditEntry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []*AuditEntry{}, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "unable to read the audit log")
+	}
+	entries := make([]*AuditEntry, 0)
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		entry := &AuditEntry{}
+		if err := json.Unmarshal(line, entry); err != nil {
+			return nil, errors.Wrap(err, "unable to parse the audit log")
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
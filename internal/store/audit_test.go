@@ -0,0 +1,82 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditArchivedTodo(t *testing.T) {
+	tests := []struct {
+		name    string
+		rewrite func(s Store) error
+		field   string // The field of the todo that changes
+		from    string // The json of the field before the change
+		to      string // The json of the field after the change
+	}{
+		{"rename tag", func(s Store) error { return RenameTag(s, "work", "job") }, "tags", `["work"]`, `["job"]`},
+		{"remove tag", func(s Store) error { return RemoveTag(s, "work") }, "tags", `["work"]`, `[]`},
+		{"remove user", func(s Store) error { return RemoveUser(s, "ann") }, "assignees", `["ann"]`, `[]`},
+	}
+	for _, backend := range []string{JSONBackend, SQLiteBackend} {
+		for _, test := range tests {
+			t.Run(backend+" "+test.name, func(t *testing.T) {
+				root := t.TempDir()
+				s := newBackendStore(t, root, backend)
+				defer s.Close()
+				fillAreas(t, s, "work", "ann")
+				path := filepath.Join(root, AuditFile)
+				if err := test.rewrite(NewHistoryStore(s, "", path, "rewrite", "bob")); err != nil {
+					t.Fatal(err)
+				}
+				entries, err := ReadAudit(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var found *AuditEntry
+				for _, entry := range entries {
+					if entry.Name == "archived" {
+						found = entry
+					}
+				}
+				if found == nil {
+					t.Fatalf("the audit has no entry of the archived todo: %v", entries)
+				}
+				if found.Area != ArchiveDir || found.Type != EVENT_TODO_UPDATED || found.Actor != "bob" ||
+					found.Line != "rewrite" {
+					t.Errorf("the entry of the archived todo is %+v", found)
+				}
+				change, ok := found.Changes[test.field]
+				if !ok {
+					t.Fatalf("the entry of the archived todo has no change of %s: %v", test.field, found.Changes)
+				}
+				from, err := json.Marshal(change.From)
+				if err != nil {
+					t.Fatal(err)
+				}
+				to, err := json.Marshal(change.To)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(from) != test.from || string(to) != test.to {
+					t.Errorf("%s changed from %s to %s, expected from %s to %s", test.field, from, to, test.from,
+						test.to)
+				}
+			})
+		}
+	}
+}
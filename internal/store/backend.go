@@ -0,0 +1,255 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	// JSONBackend keeps a repository in a json file per model
+	JSONBackend = "json"
+	// SQLiteBackend keeps a repository in a single sqlite database
+	SQLiteBackend = "sqlite"
+)
+
+// jsonFiles are the files and directories of a repository kept by the json backend
+var jsonFiles = []string{"boards", "index.json", "tags.json", "users.json", "milestones.json",
+	ArchiveDir, TrashDir, JournalFile}
+
+// Backend returns the backend of the repository at the given directory, which uses the json backend unless it has a
+// sqlite database
+func Backend(root string) string {
+	if exists(filepath.Join(root, SQLiteFile)) {
+		return SQLiteBackend
+	}
+	return JSONBackend
+}
+
+// Open takes the lock of the repository at the given directory, exclusive when the store changes it, and opens its
+// store with the backend of the repository
+func Open(root string, exclusive bool, timeout time.Duration) (Store, error) {
+	if Backend(root) == SQLiteBackend {
+		return OpenSQLiteStore(root, exclusive, timeout)
+	}
+	return OpenJSONStore(root, exclusive, timeout)
+}
+
+// NewStore opens the store of the repository at the given directory with the backend of the repository, without
+// locking it
+func NewStore(root string) (Store, error) {
+	if Backend(root) == SQLiteBackend {
+		return NewSQLiteStore(filepath.Join(root, SQLiteFile))
+	}
+	return NewJSONStore(root)
+}
+
+// remover is a store whose removal times can be set
+type remover interface {
+	// setRemoved sets the removal times of the boards and todos of the trash
+	setRemoved(removed map[uuid.UUID]time.Time) error
+}
+
+func (s *jsonStore) setRemoved(removed map[uuid.UUID]time.Time) error {
+	tx := s.begin()
+	if err := tx.write(s.removedFile(), removed); err != nil {
+		return err
+	}
+	return s.commit(tx)
+}
+
+func (s *sqliteStore) setRemoved(removed map[uuid.UUID]time.Time) error {
+	return s.update(func(tx *sql.Tx) error {
+		for id, when := range removed {
+			if err := markRemovedRow(tx, id, when); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Convert converts the repository at the given directory to the given backend, which must be held under an
+// exclusive lock. The repository switches to the new backend at once when it is complete, and the files of the old
+// one are removed afterwards.
+func Convert(root, backend string) error {
+	if backend != JSONBackend && backend != SQLiteBackend {
+		return errors.Errorf("unknown backend %q, use json or sqlite", backend)
+	}
+	if Backend(root) == backend {
+		return errors.Errorf("the repository already uses the %s backend", backend)
+	}
+	from, err := NewStore(root)
+	if err != nil {
+		return err
+	}
+	if backend == SQLiteBackend {
+		return errors.Combine(convertToSQLite(root, from), from.Close())
+	}
+	if err := errors.Combine(convertToJSON(root, from), from.Close()); err != nil {
+		return err
+	}
+	// The database can only be removed once it is closed
+	return errors.Wrap(os.Remove(filepath.Join(root, SQLiteFile)), "unable to remove the database")
+}
+
+// convertToSQLite copies the given store into a new sqlite database of the repository at the given directory, which
+// replaces the json files
+func convertToSQLite(root string, from Store) error {
+	path := filepath.Join(root, SQLiteFile+".converting")
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrapf(err, "unable to remove %s", path)
+	}
+	to, err := NewSQLiteStore(path)
+	if err != nil {
+		return err
+	}
+	if err := copyStore(from, to); err != nil {
+		to.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := to.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(path, filepath.Join(root, SQLiteFile)); err != nil {
+		return errors.Wrap(err, "unable to move the database into the repository")
+	}
+	for _, name := range jsonFiles {
+		if err := os.RemoveAll(filepath.Join(root, name)); err != nil {
+			return errors.Wrapf(err, "unable to remove %s", name)
+		}
+	}
+	return nil
+}
+
+// convertToJSON copies the given store into json files, which are moved into the repository at the given directory
+// next to its sqlite database
+func convertToJSON(root string, from Store) error {
+	dir := filepath.Join(root, "converting")
+	if err := os.RemoveAll(dir); err != nil {
+		return errors.Wrapf(err, "unable to remove %s", dir)
+	}
+	defer os.RemoveAll(dir)
+	to, err := NewJSONStore(dir)
+	if err != nil {
+		return err
+	}
+	if err := copyStore(from, to); err != nil {
+		return err
+	}
+	for _, name := range jsonFiles {
+		if !exists(filepath.Join(dir, name)) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(root, name)); err != nil {
+			return errors.Wrapf(err, "unable to remove %s", name)
+		}
+		if err := os.Rename(filepath.Join(dir, name), filepath.Join(root, name)); err != nil {
+			return errors.Wrapf(err, "unable to move %s into the repository", name)
+		}
+	}
+	return nil
+}
+
+// copyStore copies the tags, users, boards, todos, archive and trash of the first store into the second one
+func copyStore(from, to Store) error {
+	if err := copyModels(from, to); err != nil {
+		return err
+	}
+	fromArchive, err := from.Archive()
+	if err != nil {
+		return err
+	}
+	toArchive, err := to.Archive()
+	if err != nil {
+		return err
+	}
+	if err := copyModels(fromArchive, toArchive); err != nil {
+		return err
+	}
+	fromTrash, err := from.Trash()
+	if err != nil {
+		return err
+	}
+	toTrash, err := to.Trash()
+	if err != nil {
+		return err
+	}
+	if err := copyModels(fromTrash, toTrash); err != nil {
+		return err
+	}
+	removed, err := from.Removed()
+	if err != nil {
+		return err
+	}
+	return to.(remover).setRemoved(removed)
+}
+
+// copyModels copies the tags, users, milestones, boards and todos of the first store into the second one, keeping the
+// order of the todos of each board
+func copyModels(from, to Store) error {
+	for _, tag := range from.Tags() {
+		if err := to.SaveTag(tag); err != nil {
+			return err
+		}
+	}
+	for _, user := range from.Users() {
+		if err := to.SaveUser(user); err != nil {
+			return err
+		}
+	}
+	for _, milestone := range from.Milestones() {
+		if err := to.SaveMilestone(milestone); err != nil {
+			return err
+		}
+	}
+	for _, board := range from.Boards() {
+		copied := *board
+		copied.Todos = model.NewCollection[*model.Todo]()
+		if err := to.SaveBoard(&copied); err != nil {
+			return err
+		}
+		tasks, err := from.Todos(board.ID)
+		if err != nil {
+			return err
+		}
+		// The todos without a rank would not keep their order, so they are all ranked in their order
+		ranked := true
+		for _, task := range tasks {
+			ranked = ranked && task.Base().Rank != ""
+		}
+		if !ranked {
+			rank := ""
+			for _, task := range tasks {
+				rank = model.RankBetween(rank, "")
+				task.Base().Rank = rank
+			}
+		}
+		for _, task := range tasks {
+			if err := to.SaveTodo(board.ID, task); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	date "github.com/bykof/gostradamus"
+	"github.com/chordflower/todoman/internal/model"
+)
+
+// newBackendStore creates a new store of the repository at the given directory with the given backend
+func newBackendStore(t *testing.T, root, backend string) Store {
+	t.Helper()
+	var s Store
+	var err error
+	if backend == SQLiteBackend {
+		s, err = NewSQLiteStore(filepath.Join(root, SQLiteFile))
+	} else {
+		s, err = NewJSONStore(root)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// fillStore adds to the given store a board with a todo, a subtask and an agile todo with notes, efforts, tags and
+// assignees, an archived todo and a removed board
+func fillStore(t *testing.T, s Store) {
+	t.Helper()
+	when := date.DateTimeFromTime(time.Date(2022, time.October, 3, 9, 30, 0, 0, time.UTC))
+	check := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	check(s.SaveTag(model.NewTag2("work", "#ff0000")))
+	check(s.SaveUser(model.NewUser("ann", "Ann", "ann@example.com")))
+	milestone := model.NewMilestone("release")
+	milestone.DueDate = model.DateTime{DateTime: when}
+	check(s.SaveMilestone(milestone))
+	board := model.NewBoard2("main", "")
+	check(s.SaveBoard(board))
+	todo := model.NewTodo("write")
+	todo.AddTag("work")
+	todo.Assignees = []string{"ann"}
+	todo.AddNote(model.NewNote("first", "ann"))
+	todo.AddChecklistItem("draft")
+	check(s.SaveTodo(board.ID, todo))
+	check(s.SaveTodo(board.ID, model.NewSubtask("outline", todo)))
+	agile := model.NewAgileTodo("review")
+	agile.Points = 3
+	agile.AddEffort(model.NewEffort(when, time.Hour))
+	agile.AddDependency(todo.ID)
+	check(s.SaveTodo(board.ID, agile))
+	archived := model.NewTodo("old")
+	check(s.SaveTodo(board.ID, archived))
+	check(s.ArchiveTodo(archived.ID))
+	removed := model.NewBoard2("gone", "")
+	check(s.SaveBoard(removed))
+	check(s.SaveTodo(removed.ID, model.NewTodo("lost")))
+	check(s.RemoveBoard(removed.ID))
+}
+
+// storeContents returns the json of every model of the given store and of its areas, by a description of each one
+func storeContents(t *testing.T, s Store) map[string]string {
+	t.Helper()
+	contents := make(map[string]string)
+	add := func(key string, value any) {
+		data, err := json.Marshal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[key] = string(data)
+	}
+	addArea := func(area string, s Store) {
+		for _, board := range s.Boards() {
+			add(area+" board "+board.ID.String(), board)
+			tasks, err := s.Todos(board.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, task := range tasks {
+				add(area+" todo "+task.Base().ID.String(), task)
+			}
+		}
+	}
+	addArea("main", s)
+	for _, tag := range s.Tags() {
+		add("tag "+tag.Name, tag)
+	}
+	for _, user := range s.Users() {
+		add("user "+user.Name, user)
+	}
+	for _, milestone := range s.Milestones() {
+		add("milestone "+milestone.Name, milestone)
+	}
+	archive, err := s.Archive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	addArea(ArchiveDir, archive)
+	trash, err := s.Trash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trash.Close()
+	addArea(TrashDir, trash)
+	removed, err := s.Removed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, when := range removed {
+		add("removed "+id.String(), when.UTC())
+	}
+	return contents
+}
+
+// hasPrefix checks if one of the keys of the given contents starts with the given prefix
+func hasPrefix(contents map[string]string, prefix string) bool {
+	for key := range contents {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   []string // The backends the repository is converted to, in order
+	}{
+		{"json to sqlite", JSONBackend, []string{SQLiteBackend}},
+		{"sqlite to json", SQLiteBackend, []string{JSONBackend}},
+		{"json to sqlite and back", JSONBackend, []string{SQLiteBackend, JSONBackend}},
+		{"sqlite to json and back", SQLiteBackend, []string{JSONBackend, SQLiteBackend}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			s := newBackendStore(t, root, test.from)
+			fillStore(t, s)
+			want := storeContents(t, s)
+			for _, prefix := range []string{"main todo", ArchiveDir + " todo", TrashDir + " board", "removed", "tag",
+				"user", "milestone"} {
+				if !hasPrefix(want, prefix) {
+					t.Fatalf("the filled store has no %s", prefix)
+				}
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+			for _, backend := range test.to {
+				if err := Convert(root, backend); err != nil {
+					t.Fatalf("unable to convert to %s: %s", backend, err)
+				}
+				if got := Backend(root); got != backend {
+					t.Fatalf("the repository uses %s, expected %s", got, backend)
+				}
+			}
+			last := test.to[len(test.to)-1]
+			if last == SQLiteBackend {
+				for _, name := range jsonFiles {
+					if exists(filepath.Join(root, name)) {
+						t.Errorf("the json file %s was not removed", name)
+					}
+				}
+			} else if exists(filepath.Join(root, SQLiteFile)) {
+				t.Error("the database was not removed")
+			}
+
+			s, err := NewStore(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			got := storeContents(t, s)
+			for key, value := range want {
+				if got[key] != value {
+					t.Errorf("%s is %s, expected %s", key, got[key], value)
+				}
+			}
+			for key := range got {
+				if _, ok := want[key]; !ok {
+					t.Errorf("unexpected %s", key)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertRefused(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend string
+		to      string
+	}{
+		{"json", JSONBackend, JSONBackend},
+		{"sqlite", SQLiteBackend, SQLiteBackend},
+		{"unknown", JSONBackend, "xml"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			if err := newBackendStore(t, root, test.backend).Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := Convert(root, test.to); err == nil {
+				t.Errorf("converting a %s repository to %s did not fail", test.backend, test.to)
+			}
+			if got := Backend(root); got != test.backend {
+				t.Errorf("the repository uses %s, expected %s", got, test.backend)
+			}
+		})
+	}
+}
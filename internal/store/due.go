@@ -0,0 +1,48 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"sort"
+	"time"
+
+	"github.com/chordflower/todoman/internal/model"
+)
+
+// Upcoming returns the unfinished todos that are overdue at the given date and the ones that are due within
+// the given duration after it, both ordered by their due date
+func Upcoming(s Store, now time.Time, within time.Duration) (overdue, soon []model.Task, err error) {
+	overdue, err = s.FindTodos(&TodoQuery{Now: now, Overdue: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	if soon, err = s.FindTodos(&TodoQuery{Now: now, Soon: within}); err != nil {
+		return nil, nil, err
+	}
+	SortByDueDate(overdue)
+	SortByDueDate(soon)
+	return
+}
+
+// SortByDueDate orders the given tasks by their due date, the ones without a due date go last
+func SortByDueDate(tasks []model.Task) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		a, b := tasks[i].Base().DueDate, tasks[j].Base().DueDate
+		if a.IsZero() || b.IsZero() {
+			return !a.IsZero()
+		}
+		return a.Time().Before(b.Time())
+	})
+}
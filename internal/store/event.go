@@ -0,0 +1,526 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/gofrs/uuid"
+)
+
+// EventType tells what changed in the store
+type EventType string
+
+const (
+	// EVENT_BOARD_CREATED is emitted when a board is created
+	EVENT_BOARD_CREATED EventType = "board.created"
+	// EVENT_BOARD_UPDATED is emitted when a board is changed
+	EVENT_BOARD_UPDATED EventType = "board.updated"
+	// EVENT_BOARD_DELETED is emitted when a board and its todos are removed
+	EVENT_BOARD_DELETED EventType = "board.deleted"
+	// EVENT_BOARD_ARCHIVED is emitted when a board and its todos are moved to the archive
+	EVENT_BOARD_ARCHIVED EventType = "board.archived"
+	// EVENT_BOARD_RESTORED is emitted when an archived board and its archived todos are moved out of the archive
+	EVENT_BOARD_RESTORED EventType = "board.restored"
+	// EVENT_BOARD_RECOVERED is emitted when a removed board and its removed todos are moved out of the trash
+	EVENT_BOARD_RECOVERED EventType = "board.recovered"
+	// EVENT_TODO_CREATED is emitted when a todo is created
+	EVENT_TODO_CREATED EventType = "todo.created"
+	// EVENT_TODO_UPDATED is emitted when a todo is changed
+	EVENT_TODO_UPDATED EventType = "todo.updated"
+	// EVENT_TODO_STATUS_CHANGED is emitted when the status of a todo changes, after its todo.updated event
+	EVENT_TODO_STATUS_CHANGED EventType = "todo.status_changed"
+	// EVENT_TODO_MOVED is emitted when a todo is moved to another board
+	EVENT_TODO_MOVED EventType = "todo.moved"
+	// EVENT_TODO_DELETED is emitted when a todo is removed
+	EVENT_TODO_DELETED EventType = "todo.deleted"
+	// EVENT_TODO_ARCHIVED is emitted when a todo is moved to the archive
+	EVENT_TODO_ARCHIVED EventType = "todo.archived"
+	// EVENT_TODO_RESTORED is emitted when an archived todo is moved out of the archive
+	EVENT_TODO_RESTORED EventType = "todo.restored"
+	// EVENT_TODO_RECOVERED is emitted when a removed todo is moved out of the trash
+	EVENT_TODO_RECOVERED EventType = "todo.recovered"
+	// EVENT_NOTE_ADDED is emitted when a note is added to a todo
+	EVENT_NOTE_ADDED EventType = "note.added"
+	// EVENT_EFFORT_LOGGED is emitted when an effort is added to an agile todo
+	EVENT_EFFORT_LOGGED EventType = "effort.logged"
+	// EVENT_TAG_CREATED is emitted when a tag is created
+	EVENT_TAG_CREATED EventType = "tag.created"
+	// EVENT_TAG_UPDATED is emitted when a tag is changed
+	EVENT_TAG_UPDATED EventType = "tag.updated"
+	// EVENT_TAG_DELETED is emitted when a tag is removed
+	EVENT_TAG_DELETED EventType = "tag.deleted"
+	// EVENT_USER_CREATED is emitted when a user is added to the repository
+	EVENT_USER_CREATED EventType = "user.created"
+	// EVENT_USER_UPDATED is emitted when a user is changed
+	EVENT_USER_UPDATED EventType = "user.updated"
+	// EVENT_USER_DELETED is emitted when a user is removed from the repository
+	EVENT_USER_DELETED EventType = "user.deleted"
+	// EVENT_MILESTONE_CREATED is emitted when a milestone is added to the repository
+	EVENT_MILESTONE_CREATED EventType = "milestone.created"
+	// EVENT_MILESTONE_UPDATED is emitted when a milestone is changed
+	EVENT_MILESTONE_UPDATED EventType = "milestone.updated"
+	// EVENT_MILESTONE_DELETED is emitted when a milestone is removed from the repository
+	EVENT_MILESTONE_DELETED EventType = "milestone.deleted"
+)
+
+// Change is the old and new value of a field changed by an event
+type Change struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// Event describes a change of the store
+type Event struct {
+	ID      uuid.UUID         `json:"id"`                // An unique ID for the event
+	Type    EventType         `json:"type"`              // What changed
+	Time    time.Time         `json:"time"`              // When it changed
+	Board   uuid.UUID         `json:"board"`             // The board of the changed model, if any
+	Todo    uuid.UUID         `json:"todo"`              // The todo of the changed model, if any
+	Model   any               `json:"model"`             // The changed model, as it is after the change
+	Changes map[string]Change `json:"changes,omitempty"` // The fields that changed, for the events that tell them
+}
+
+// NewEvent creates a new event of the given type about the given model
+func NewEvent(kind EventType, board, todo uuid.UUID, value any) *Event {
+	id, _ := uuid.NewV1()
+	return &Event{ID: id, Type: kind, Time: time.Now(), Board: board, Todo: todo, Model: value}
+}
+
+// Listener receives the events of a bus
+type Listener func(event *Event)
+
+// Bus delivers the events of a store to its listeners, in the order they were published
+type Bus struct {
+	listeners []Listener
+}
+
+// NewBus creates a bus without listeners
+func NewBus() *Bus {
+	return &Bus{listeners: make([]Listener, 0)}
+}
+
+// Subscribe adds a listener to this bus
+func (b *Bus) Subscribe(listener Listener) {
+	b.listeners = append(b.listeners, listener)
+}
+
+// Publish delivers the given event to every listener of this bus
+func (b *Bus) Publish(event *Event) {
+	for _, listener := range b.listeners {
+		listener(event)
+	}
+}
+
+// todoState is what an event store remembers of a saved todo, to find what changed when it is saved again
+type todoState struct {
+	status  model.TodoStatus
+	notes   map[uuid.UUID]bool
+	efforts map[uuid.UUID]bool
+}
+
+// eventStore publishes the changes made through another store, once they are committed and the store is closed
+type eventStore struct {
+	Store
+	bus    *Bus
+	states map[uuid.UUID]*todoState
+	queue  []*Event
+}
+
+// NewEventStore returns a store that publishes every change made through the given store to the given bus. The events
+// are kept until the store is closed and delivered afterwards, so that the listeners only see committed changes and
+// can change the repository themselves.
+func NewEventStore(s Store, bus *Bus) Store {
+	return &eventStore{Store: s, bus: bus, states: make(map[uuid.UUID]*todoState)}
+}
+
+// publish queues the given event until the store is closed, with its model as it is now since the model can change
+// again before the event is delivered
+func (es *eventStore) publish(event *Event) {
+	if data, err := json.Marshal(event.Model); err == nil {
+		event.Model = json.RawMessage(data)
+	}
+	es.queue = append(es.queue, event)
+}
+
+func (es *eventStore) batch(fn func() error) error {
+	queued := len(es.queue)
+	if err := es.Store.batch(fn); err != nil {
+		// The changes of a failed batch are rolled back, and so are their events and the states of their todos
+		es.queue = es.queue[:queued]
+		es.states = make(map[uuid.UUID]*todoState)
+		return err
+	}
+	return nil
+}
+
+// Close closes the store, which releases the repository, and only then delivers the queued events
+func (es *eventStore) Close() error {
+	err := es.Store.Close()
+	events := es.queue
+	es.queue = nil
+	for _, event := range events {
+		es.bus.Publish(event)
+	}
+	return err
+}
+
+// know remembers the state of the given task when it is read for the first time, since the todos are changed in place
+// before being saved
+func (es *eventStore) know(task model.Task) {
+	if _, ok := es.states[task.Base().ID]; !ok {
+		es.remember(task)
+	}
+}
+
+func (es *eventStore) Todos(board uuid.UUID) ([]model.Task, error) {
+	tasks, err := es.Store.Todos(board)
+	for _, task := range tasks {
+		es.know(task)
+	}
+	return tasks, err
+}
+
+func (es *eventStore) AllTodos() []model.Task {
+	tasks := es.Store.AllTodos()
+	for _, task := range tasks {
+		es.know(task)
+	}
+	return tasks
+}
+
+func (es *eventStore) FindTodos(query *TodoQuery) ([]model.Task, error) {
+	tasks, err := es.Store.FindTodos(query)
+	for _, task := range tasks {
+		es.know(task)
+	}
+	return tasks, err
+}
+
+func (es *eventStore) Todo(id uuid.UUID) (model.Task, error) {
+	task, err := es.Store.Todo(id)
+	if err == nil {
+		es.know(task)
+	}
+	return task, err
+}
+
+// remember keeps the state of the given task
+func (es *eventStore) remember(task model.Task) {
+	t := task.Base()
+	state := &todoState{status: t.Status, notes: make(map[uuid.UUID]bool), efforts: make(map[uuid.UUID]bool)}
+	t.Notes.Each(func(index int, note *model.Note) {
+		state.notes[note.ID] = true
+	})
+	if ag, ok := task.(*model.AgileTodo); ok {
+		ag.Effort.Each(func(index int, effort *model.Effort) {
+			state.efforts[effort.ID] = true
+		})
+	}
+	es.states[t.ID] = state
+}
+
+func (es *eventStore) SaveBoard(board *model.Board) error {
+	_, err := es.Store.Board(board.ID)
+	kind := EVENT_BOARD_UPDATED
+	if err != nil {
+		kind = EVENT_BOARD_CREATED
+	}
+	if err := es.Store.SaveBoard(board); err != nil {
+		return err
+	}
+	es.publish(NewEvent(kind, board.ID, uuid.Nil, board))
+	return nil
+}
+
+func (es *eventStore) RemoveBoard(id uuid.UUID) error {
+	board, err := es.Store.Board(id)
+	if err != nil {
+		return err
+	}
+	tasks, err := es.Store.Todos(id)
+	if err != nil {
+		return err
+	}
+	if err := es.Store.RemoveBoard(id); err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		delete(es.states, task.Base().ID)
+	}
+	es.publish(NewEvent(EVENT_BOARD_DELETED, id, uuid.Nil, board))
+	return nil
+}
+
+func (es *eventStore) SaveTodo(board uuid.UUID, task model.Task) error {
+	t := task.Base()
+	_, err := es.Store.Todo(t.ID)
+	existed := err == nil
+	if err := es.Store.SaveTodo(board, task); err != nil {
+		return err
+	}
+	old, known := es.states[t.ID]
+	es.remember(task)
+	if !existed {
+		es.publish(NewEvent(EVENT_TODO_CREATED, board, t.ID, task))
+		return nil
+	}
+	es.publish(NewEvent(EVENT_TODO_UPDATED, board, t.ID, task))
+	// Only the changes of a todo that was read through this store are known
+	if !known {
+		return nil
+	}
+	if old.status != t.Status {
+		event := NewEvent(EVENT_TODO_STATUS_CHANGED, board, t.ID, task)
+		event.Changes = map[string]Change{"status": {From: old.status.Name(), To: t.Status.Name()}}
+		es.publish(event)
+	}
+	t.Notes.Each(func(index int, note *model.Note) {
+		if !old.notes[note.ID] {
+			es.publish(NewEvent(EVENT_NOTE_ADDED, board, t.ID, note))
+		}
+	})
+	if ag, ok := task.(*model.AgileTodo); ok {
+		ag.Effort.Each(func(index int, effort *model.Effort) {
+			if !old.efforts[effort.ID] {
+				es.publish(NewEvent(EVENT_EFFORT_LOGGED, board, t.ID, effort))
+			}
+		})
+	}
+	return nil
+}
+
+func (es *eventStore) saveAreaTodo(area string, board uuid.UUID, task model.Task) error {
+	if err := es.Store.saveAreaTodo(area, board, task); err != nil {
+		return err
+	}
+	es.publish(NewEvent(EVENT_TODO_UPDATED, board, task.Base().ID, task))
+	return nil
+}
+
+func (es *eventStore) RemoveTodo(id uuid.UUID) error {
+	task, err := es.Store.Todo(id)
+	if err != nil {
+		return err
+	}
+	board, err := es.Store.BoardOf(id)
+	if err != nil {
+		return err
+	}
+	if err := es.Store.RemoveTodo(id); err != nil {
+		return err
+	}
+	delete(es.states, id)
+	es.publish(NewEvent(EVENT_TODO_DELETED, board.ID, id, task))
+	return nil
+}
+
+func (es *eventStore) TransferTodo(id uuid.UUID, board uuid.UUID) error {
+	from, err := es.Store.BoardOf(id)
+	if err != nil {
+		return err
+	}
+	if err := es.Store.TransferTodo(id, board); err != nil {
+		return err
+	}
+	task, err := es.Store.Todo(id)
+	if err != nil {
+		return err
+	}
+	if from.ID != board {
+		event := NewEvent(EVENT_TODO_MOVED, board, id, task)
+		event.Changes = map[string]Change{"board": {From: from.ID, To: board}}
+		es.publish(event)
+	}
+	return nil
+}
+
+func (es *eventStore) ArchiveBoard(id uuid.UUID) error {
+	board, err := es.Store.Board(id)
+	if err != nil {
+		return err
+	}
+	tasks, err := es.Store.Todos(id)
+	if err != nil {
+		return err
+	}
+	if err := es.Store.ArchiveBoard(id); err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		delete(es.states, task.Base().ID)
+	}
+	es.publish(NewEvent(EVENT_BOARD_ARCHIVED, id, uuid.Nil, board))
+	return nil
+}
+
+func (es *eventStore) RestoreBoard(id uuid.UUID) error {
+	if err := es.Store.RestoreBoard(id); err != nil {
+		return err
+	}
+	board, err := es.Store.Board(id)
+	if err != nil {
+		return err
+	}
+	tasks, err := es.Store.Todos(id)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		es.remember(task)
+	}
+	es.publish(NewEvent(EVENT_BOARD_RESTORED, id, uuid.Nil, board))
+	return nil
+}
+
+func (es *eventStore) ArchiveTodo(id uuid.UUID) error {
+	task, err := es.Store.Todo(id)
+	if err != nil {
+		return err
+	}
+	board, err := es.Store.BoardOf(id)
+	if err != nil {
+		return err
+	}
+	if err := es.Store.ArchiveTodo(id); err != nil {
+		return err
+	}
+	delete(es.states, id)
+	es.publish(NewEvent(EVENT_TODO_ARCHIVED, board.ID, id, task))
+	return nil
+}
+
+func (es *eventStore) RestoreTodo(id uuid.UUID) error {
+	if err := es.Store.RestoreTodo(id); err != nil {
+		return err
+	}
+	task, err := es.Store.Todo(id)
+	if err != nil {
+		return err
+	}
+	board, err := es.Store.BoardOf(id)
+	if err != nil {
+		return err
+	}
+	es.remember(task)
+	es.publish(NewEvent(EVENT_TODO_RESTORED, board.ID, id, task))
+	return nil
+}
+
+func (es *eventStore) Recover(id uuid.UUID) error {
+	if err := es.Store.Recover(id); err != nil {
+		return err
+	}
+	if board, err := es.Store.Board(id); err == nil {
+		tasks, err := es.Store.Todos(id)
+		if err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			es.remember(task)
+		}
+		es.publish(NewEvent(EVENT_BOARD_RECOVERED, id, uuid.Nil, board))
+		return nil
+	}
+	task, err := es.Store.Todo(id)
+	if err != nil {
+		return err
+	}
+	board, err := es.Store.BoardOf(id)
+	if err != nil {
+		return err
+	}
+	es.remember(task)
+	es.publish(NewEvent(EVENT_TODO_RECOVERED, board.ID, id, task))
+	return nil
+}
+
+func (es *eventStore) SaveTag(tag *model.Tag) error {
+	kind := EVENT_TAG_CREATED
+	for _, other := range es.Store.Tags() {
+		if other.ID == tag.ID {
+			kind = EVENT_TAG_UPDATED
+		}
+	}
+	if err := es.Store.SaveTag(tag); err != nil {
+		return err
+	}
+	es.publish(NewEvent(kind, uuid.Nil, uuid.Nil, tag))
+	return nil
+}
+
+func (es *eventStore) RemoveTag(name string) error {
+	tag, err := es.Store.Tag(name)
+	if err != nil {
+		return err
+	}
+	if err := es.Store.RemoveTag(name); err != nil {
+		return err
+	}
+	es.publish(NewEvent(EVENT_TAG_DELETED, uuid.Nil, uuid.Nil, tag))
+	return nil
+}
+
+func (es *eventStore) SaveUser(user *model.User) error {
+	kind := EVENT_USER_CREATED
+	for _, other := range es.Store.Users() {
+		if other.ID == user.ID {
+			kind = EVENT_USER_UPDATED
+		}
+	}
+	if err := es.Store.SaveUser(user); err != nil {
+		return err
+	}
+	es.publish(NewEvent(kind, uuid.Nil, uuid.Nil, user))
+	return nil
+}
+
+func (es *eventStore) RemoveUser(name string) error {
+	user, err := es.Store.User(name)
+	if err != nil {
+		return err
+	}
+	if err := es.Store.RemoveUser(name); err != nil {
+		return err
+	}
+	es.publish(NewEvent(EVENT_USER_DELETED, uuid.Nil, uuid.Nil, user))
+	return nil
+}
+
+func (es *eventStore) SaveMilestone(milestone *model.Milestone) error {
+	kind := EVENT_MILESTONE_CREATED
+	if _, err := es.Store.Milestone(milestone.ID); err == nil {
+		kind = EVENT_MILESTONE_UPDATED
+	}
+	if err := es.Store.SaveMilestone(milestone); err != nil {
+		return err
+	}
+	es.publish(NewEvent(kind, uuid.Nil, uuid.Nil, milestone))
+	return nil
+}
+
+func (es *eventStore) RemoveMilestone(id uuid.UUID) error {
+	milestone, err := es.Store.Milestone(id)
+	if err != nil {
+		return err
+	}
+	if err := es.Store.RemoveMilestone(id); err != nil {
+		return err
+	}
+	es.publish(NewEvent(EVENT_MILESTONE_DELETED, uuid.Nil, uuid.Nil, milestone))
+	return nil
+}
@@ -0,0 +1,680 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/gofrs/uuid"
+)
+
+// HistoryFile is the file of the repository that keeps the revisions of the changes made to it
+const HistoryFile = "history.jsonl"
+
+// Revision is a change made through a history store, with the changed model as it was before and after the change
+type Revision struct {
+	Command uuid.UUID       `json:"command"`          // The id of the command that made the change
+	Line    string          `json:"line"`             // The command line of the command that made the change
+	Time    time.Time       `json:"time"`             // When it changed
+	Type    EventType       `json:"type"`             // What changed, like the event of the change
+	Board   uuid.UUID       `json:"board"`            // The board of the changed model before the change, if any
+	Area    string          `json:"area,omitempty"`   // The area of the changed todo, like the archive, if any
+	Model   uuid.UUID       `json:"model"`            // The id of the changed model
+	Before  json.RawMessage `json:"before,omitempty"` // The changed model before the change, if it existed
+	After   json.RawMessage `json:"after,omitempty"`  // The changed model after the change, if it still exists
+}
+
+// historyStore keeps a revision of every change made through another store, so that the changes can be undone,
+// and an audit entry of who made it. The state of a model before its change is the one it had when it was first read
+// through the history store, since the models are changed in place before being saved.
+type historyStore struct {
+	Store
+	path    string
+	audit   string
+	command uuid.UUID
+	line    string
+	actor   string
+	states  map[uuid.UUID]json.RawMessage
+}
+
+// NewHistoryStore returns a store that appends a revision of every change made through the given store to the
+// history file at the given path, and its audit entry to the audit file at the given path, as made by the given
+// actor with a new command with the given command line. Either file is not written when its path is empty.
+func NewHistoryStore(s Store, path, audit, line, actor string) Store {
+	command, _ := uuid.NewV1()
+	return &historyStore{Store: s, path: path, audit: audit, command: command, line: line, actor: actor,
+		states: make(map[uuid.UUID]json.RawMessage)}
+}
+
+// keep remembers the state of the given model
+func (hs *historyStore) keep(id uuid.UUID, value any) json.RawMessage {
+	data, err := json.Marshal(value)
+	if err != nil {
+		data = nil
+	}
+	hs.states[id] = data
+	return data
+}
+
+// snapshot remembers the state of the given model when it is read for the first time
+func (hs *historyStore) snapshot(id uuid.UUID, value any) {
+	if _, ok := hs.states[id]; !ok {
+		hs.keep(id, value)
+	}
+}
+
+func (hs *historyStore) batch(fn func() error) error {
+	if err := hs.Store.batch(fn); err != nil {
+		// The models are read again after a failed batch, so their states are taken again too
+		hs.states = make(map[uuid.UUID]json.RawMessage)
+		return err
+	}
+	return nil
+}
+
+func (hs *historyStore) Boards() []*model.Board {
+	boards := hs.Store.Boards()
+	for _, board := range boards {
+		hs.snapshot(board.ID, board)
+	}
+	return boards
+}
+
+func (hs *historyStore) Board(id uuid.UUID) (*model.Board, error) {
+	board, err := hs.Store.Board(id)
+	if err == nil {
+		hs.snapshot(board.ID, board)
+	}
+	return board, err
+}
+
+func (hs *historyStore) BoardOf(todo uuid.UUID) (*model.Board, error) {
+	board, err := hs.Store.BoardOf(todo)
+	if err == nil {
+		hs.snapshot(board.ID, board)
+	}
+	return board, err
+}
+
+func (hs *historyStore) Todos(board uuid.UUID) ([]model.Task, error) {
+	tasks, err := hs.Store.Todos(board)
+	for _, task := range tasks {
+		hs.snapshot(task.Base().ID, task)
+	}
+	return tasks, err
+}
+
+func (hs *historyStore) AllTodos() []model.Task {
+	tasks := hs.Store.AllTodos()
+	for _, task := range tasks {
+		hs.snapshot(task.Base().ID, task)
+	}
+	return tasks
+}
+
+func (hs *historyStore) FindTodos(query *TodoQuery) ([]model.Task, error) {
+	tasks, err := hs.Store.FindTodos(query)
+	for _, task := range tasks {
+		hs.snapshot(task.Base().ID, task)
+	}
+	return tasks, err
+}
+
+func (hs *historyStore) Todo(id uuid.UUID) (model.Task, error) {
+	task, err := hs.Store.Todo(id)
+	if err == nil {
+		hs.snapshot(id, task)
+	}
+	return task, err
+}
+
+func (hs *historyStore) Tags() []*model.Tag {
+	tags := hs.Store.Tags()
+	for _, tag := range tags {
+		hs.snapshot(tag.ID, tag)
+	}
+	return tags
+}
+
+func (hs *historyStore) Tag(name string) (*model.Tag, error) {
+	tag, err := hs.Store.Tag(name)
+	if err == nil {
+		hs.snapshot(tag.ID, tag)
+	}
+	return tag, err
+}
+
+func (hs *historyStore) Users() []*model.User {
+	users := hs.Store.Users()
+	for _, user := range users {
+		hs.snapshot(user.ID, user)
+	}
+	return users
+}
+
+func (hs *historyStore) User(name string) (*model.User, error) {
+	user, err := hs.Store.User(name)
+	if err == nil {
+		hs.snapshot(user.ID, user)
+	}
+	return user, err
+}
+
+func (hs *historyStore) Milestones() []*model.Milestone {
+	milestones := hs.Store.Milestones()
+	for _, milestone := range milestones {
+		hs.snapshot(milestone.ID, milestone)
+	}
+	return milestones
+}
+
+func (hs *historyStore) Milestone(id uuid.UUID) (*model.Milestone, error) {
+	milestone, err := hs.Store.Milestone(id)
+	if err == nil {
+		hs.snapshot(milestone.ID, milestone)
+	}
+	return milestone, err
+}
+
+// hasTag checks if the store has the tag with the given id, under any name
+func (hs *historyStore) hasTag(id uuid.UUID) bool {
+	for _, tag := range hs.Store.Tags() {
+		if tag.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// hasUser checks if the store has the user with the given id, under any name
+func (hs *historyStore) hasUser(id uuid.UUID) bool {
+	for _, user := range hs.Store.Users() {
+		if user.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// record appends, in the transaction of the change, a revision of the given change to the history file and its audit
+// entry to the audit file, using the kept state of the model as its state before the change and the given value, if
+// any, as its state after the change
+func (hs *historyStore) record(kind EventType, board, id uuid.UUID, after any) error {
+	return hs.recordIn("", kind, board, id, after)
+}
+
+// recordIn records the given change of a model inside the given area, like record
+func (hs *historyStore) recordIn(area string, kind EventType, board, id uuid.UUID, after any) error {
+	revision := &Revision{
+		Command: hs.command,
+		Line:    hs.line,
+		Time:    time.Now(),
+		Type:    kind,
+		Board:   board,
+		Area:    area,
+		Model:   id,
+		Before:  hs.states[id],
+	}
+	if after != nil {
+		revision.After = hs.keep(id, after)
+	} else {
+		delete(hs.states, id)
+	}
+	if hs.path != "" {
+		if err := appendLine(hs.Store, hs.path, revision); err != nil {
+			return err
+		}
+	}
+	if hs.audit != "" {
+		return appendLine(hs.Store, hs.audit, newAuditEntry(revision, hs.actor))
+	}
+	return nil
+}
+
+// keepBoard remembers the state of the board with the given id and of its todos
+func (hs *historyStore) keepBoard(id uuid.UUID) (*model.Board, error) {
+	board, err := hs.Store.Board(id)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := hs.Store.Todos(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		hs.keep(task.Base().ID, task)
+	}
+	return board, nil
+}
+
+func (hs *historyStore) SaveBoard(board *model.Board) error {
+	return hs.batch(func() error {
+		kind := EVENT_BOARD_UPDATED
+		if _, err := hs.Store.Board(board.ID); err != nil {
+			kind = EVENT_BOARD_CREATED
+		}
+		if err := hs.Store.SaveBoard(board); err != nil {
+			return err
+		}
+		return hs.record(kind, board.ID, board.ID, board)
+	})
+}
+
+func (hs *historyStore) RemoveBoard(id uuid.UUID) error {
+	return hs.batch(func() error {
+		if err := hs.Store.RemoveBoard(id); err != nil {
+			return err
+		}
+		return hs.record(EVENT_BOARD_DELETED, id, id, nil)
+	})
+}
+
+func (hs *historyStore) ArchiveBoard(id uuid.UUID) error {
+	return hs.batch(func() error {
+		if err := hs.Store.ArchiveBoard(id); err != nil {
+			return err
+		}
+		return hs.record(EVENT_BOARD_ARCHIVED, id, id, nil)
+	})
+}
+
+func (hs *historyStore) RestoreBoard(id uuid.UUID) error {
+	return hs.batch(func() error {
+		if err := hs.Store.RestoreBoard(id); err != nil {
+			return err
+		}
+		board, err := hs.keepBoard(id)
+		if err != nil {
+			return err
+		}
+		return hs.record(EVENT_BOARD_RESTORED, id, id, board)
+	})
+}
+
+func (hs *historyStore) SaveTodo(board uuid.UUID, task model.Task) error {
+	return hs.batch(func() error {
+		id := task.Base().ID
+		kind := EVENT_TODO_UPDATED
+		if _, err := hs.Store.Todo(id); err != nil {
+			kind = EVENT_TODO_CREATED
+		}
+		if err := hs.Store.SaveTodo(board, task); err != nil {
+			return err
+		}
+		return hs.record(kind, board, id, task)
+	})
+}
+
+// removeTodo changes the todo with the given id with the given function, which takes it out of the store
+func (hs *historyStore) removeTodo(kind EventType, id uuid.UUID, remove func(id uuid.UUID) error) error {
+	return hs.batch(func() error {
+		board, err := hs.Store.BoardOf(id)
+		if err != nil {
+			return err
+		}
+		if err := remove(id); err != nil {
+			return err
+		}
+		return hs.record(kind, board.ID, id, nil)
+	})
+}
+
+func (hs *historyStore) RemoveTodo(id uuid.UUID) error {
+	return hs.removeTodo(EVENT_TODO_DELETED, id, hs.Store.RemoveTodo)
+}
+
+func (hs *historyStore) ArchiveTodo(id uuid.UUID) error {
+	return hs.removeTodo(EVENT_TODO_ARCHIVED, id, hs.Store.ArchiveTodo)
+}
+
+func (hs *historyStore) RestoreTodo(id uuid.UUID) error {
+	return hs.batch(func() error {
+		if err := hs.Store.RestoreTodo(id); err != nil {
+			return err
+		}
+		return hs.recordTodo(EVENT_TODO_RESTORED, id)
+	})
+}
+
+// recordTodo records a change that brought back the todo with the given id
+func (hs *historyStore) recordTodo(kind EventType, id uuid.UUID) error {
+	task, err := hs.Store.Todo(id)
+	if err != nil {
+		return err
+	}
+	board, err := hs.Store.BoardOf(id)
+	if err != nil {
+		return err
+	}
+	return hs.record(kind, board.ID, id, task)
+}
+
+func (hs *historyStore) TransferTodo(id uuid.UUID, board uuid.UUID) error {
+	return hs.batch(func() error {
+		from, err := hs.Store.BoardOf(id)
+		if err != nil {
+			return err
+		}
+		if err := hs.Store.TransferTodo(id, board); err != nil {
+			return err
+		}
+		if from.ID == board {
+			return nil
+		}
+		task, err := hs.Store.Todo(id)
+		if err != nil {
+			return err
+		}
+		return hs.record(EVENT_TODO_MOVED, from.ID, id, task)
+	})
+}
+
+func (hs *historyStore) Recover(id uuid.UUID) error {
+	return hs.batch(func() error {
+		if err := hs.Store.Recover(id); err != nil {
+			return err
+		}
+		if board, err := hs.keepBoard(id); err == nil {
+			return hs.record(EVENT_BOARD_RECOVERED, id, id, board)
+		}
+		return hs.recordTodo(EVENT_TODO_RECOVERED, id)
+	})
+}
+
+func (hs *historyStore) SaveTag(tag *model.Tag) error {
+	return hs.batch(func() error {
+		kind := EVENT_TAG_CREATED
+		if hs.hasTag(tag.ID) {
+			kind = EVENT_TAG_UPDATED
+		}
+		if err := hs.Store.SaveTag(tag); err != nil {
+			return err
+		}
+		return hs.record(kind, uuid.Nil, tag.ID, tag)
+	})
+}
+
+func (hs *historyStore) RemoveTag(name string) error {
+	return hs.batch(func() error {
+		tag, err := hs.Tag(name)
+		if err != nil {
+			return err
+		}
+		if err := hs.Store.RemoveTag(name); err != nil {
+			return err
+		}
+		return hs.record(EVENT_TAG_DELETED, uuid.Nil, tag.ID, nil)
+	})
+}
+
+func (hs *historyStore) SaveUser(user *model.User) error {
+	return hs.batch(func() error {
+		kind := EVENT_USER_CREATED
+		if hs.hasUser(user.ID) {
+			kind = EVENT_USER_UPDATED
+		}
+		if err := hs.Store.SaveUser(user); err != nil {
+			return err
+		}
+		return hs.record(kind, uuid.Nil, user.ID, user)
+	})
+}
+
+func (hs *historyStore) RemoveUser(name string) error {
+	return hs.batch(func() error {
+		user, err := hs.User(name)
+		if err != nil {
+			return err
+		}
+		if err := hs.Store.RemoveUser(name); err != nil {
+			return err
+		}
+		return hs.record(EVENT_USER_DELETED, uuid.Nil, user.ID, nil)
+	})
+}
+
+func (hs *historyStore) SaveMilestone(milestone *model.Milestone) error {
+	return hs.batch(func() error {
+		kind := EVENT_MILESTONE_CREATED
+		if _, err := hs.Store.Milestone(milestone.ID); err == nil {
+			kind = EVENT_MILESTONE_UPDATED
+		}
+		if err := hs.Store.SaveMilestone(milestone); err != nil {
+			return err
+		}
+		return hs.record(kind, uuid.Nil, milestone.ID, milestone)
+	})
+}
+
+func (hs *historyStore) RemoveMilestone(id uuid.UUID) error {
+	return hs.batch(func() error {
+		milestone, err := hs.Milestone(id)
+		if err != nil {
+			return err
+		}
+		if err := hs.Store.RemoveMilestone(id); err != nil {
+			return err
+		}
+		return hs.record(EVENT_MILESTONE_DELETED, uuid.Nil, milestone.ID, nil)
+	})
+}
+
+// historyArea is the store of an area of a history store, like its archive, which remembers the states of the todos
+// read through it for the history store
+type historyArea struct {
+	Store
+	hs *historyStore
+}
+
+// area returns the given store of an area of the history store, which is opened with the given error
+func (hs *historyStore) area(s Store, err error) (Store, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &historyArea{Store: s, hs: hs}, nil
+}
+
+func (hs *historyStore) Archive() (Store, error) {
+	return hs.area(hs.Store.Archive())
+}
+
+func (hs *historyStore) Trash() (Store, error) {
+	return hs.area(hs.Store.Trash())
+}
+
+func (ha *historyArea) Todos(board uuid.UUID) ([]model.Task, error) {
+	tasks, err := ha.Store.Todos(board)
+	for _, task := range tasks {
+		ha.hs.snapshot(task.Base().ID, task)
+	}
+	return tasks, err
+}
+
+func (ha *historyArea) AllTodos() []model.Task {
+	tasks := ha.Store.AllTodos()
+	for _, task := range tasks {
+		ha.hs.snapshot(task.Base().ID, task)
+	}
+	return tasks
+}
+
+func (ha *historyArea) FindTodos(query *TodoQuery) ([]model.Task, error) {
+	tasks, err := ha.Store.FindTodos(query)
+	for _, task := range tasks {
+		ha.hs.snapshot(task.Base().ID, task)
+	}
+	return tasks, err
+}
+
+func (ha *historyArea) Todo(id uuid.UUID) (model.Task, error) {
+	task, err := ha.Store.Todo(id)
+	if err == nil {
+		ha.hs.snapshot(id, task)
+	}
+	return task, err
+}
+
+func (hs *historyStore) saveAreaTodo(area string, board uuid.UUID, task model.Task) error {
+	return hs.batch(func() error {
+		if err := hs.Store.saveAreaTodo(area, board, task); err != nil {
+			return err
+		}
+		return hs.recordIn(area, EVENT_TODO_UPDATED, board, task.Base().ID, task)
+	})
+}
+
+// ReadHistory reads the revisions of the history file at the given path, from the oldest to the newest
+func ReadHistory(path string) ([]*Revision, error) {
+	revisions, _, err := readHistory(path)
+	return revisions, err
+}
+
+// readHistory reads the revisions of the history file at the given path, from the oldest to the newest, together
+// with the offset of the line of each one in the file
+func readHistory(path string) ([]*Revision, []int64, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []*Revision{}, []int64{}, nil
+	} else if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to read the history")
+	}
+	revisions := make([]*Revision, 0)
+	offsets := make([]int64, 0)
+	offset := int64(0)
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		start := offset
+		offset += int64(len(line))
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		revision := &Revision{}
+		if err := json.Unmarshal(line, revision); err != nil {
+			return nil, nil, errors.Wrap(err, "unable to parse the history")
+		}
+		revisions = append(revisions, revision)
+		offsets = append(offsets, start)
+	}
+	return revisions, offsets, nil
+}
+
+// Undo reverts the changes of the last count commands of the history file at the given path through the given
+// store, newest first, and removes them from the history. Each change is reverted in the same transaction that removes
+// it from the history. It returns the command lines of the undone commands, and when a change can not be reverted the
+// history keeps it and the older ones.
+func Undo(s Store, path string, count int) ([]string, error) {
+	revisions, offsets, err := readHistory(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, 0, count)
+	cut := len(revisions)
+	for cut > 0 && len(lines) < count {
+		command := revisions[cut-1].Command
+		lines = append(lines, revisions[cut-1].Line)
+		for cut > 0 && revisions[cut-1].Command == command {
+			cut--
+		}
+	}
+	for i := len(revisions) - 1; i >= cut; i-- {
+		err := s.batch(func() error {
+			if err := revert(s, revisions[i]); err != nil {
+				return err
+			}
+			return s.writeLog(path, offsets[i], nil)
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to undo %q", revisions[i].Line)
+		}
+	}
+	return lines, nil
+}
+
+// revert makes the opposite change of the given revision through the given store
+func revert(s Store, r *Revision) error {
+	switch r.Type {
+	case EVENT_BOARD_CREATED, EVENT_BOARD_RECOVERED:
+		return s.RemoveBoard(r.Model)
+	case EVENT_BOARD_UPDATED:
+		board := &model.Board{}
+		if err := json.Unmarshal(r.Before, board); err != nil {
+			return errors.Wrap(err, "unable to parse the board")
+		}
+		return s.SaveBoard(board)
+	case EVENT_BOARD_DELETED, EVENT_TODO_DELETED:
+		return s.Recover(r.Model)
+	case EVENT_BOARD_ARCHIVED:
+		return s.RestoreBoard(r.Model)
+	case EVENT_BOARD_RESTORED:
+		return s.ArchiveBoard(r.Model)
+	case EVENT_TODO_CREATED, EVENT_TODO_RECOVERED:
+		return s.RemoveTodo(r.Model)
+	case EVENT_TODO_UPDATED, EVENT_TODO_MOVED:
+		task, err := DecodeTask(r.Before)
+		if err != nil {
+			return errors.Wrap(err, "unable to parse the todo")
+		}
+		if r.Area != "" {
+			return s.saveAreaTodo(r.Area, r.Board, task)
+		}
+		if r.Type == EVENT_TODO_MOVED {
+			if err := s.TransferTodo(r.Model, r.Board); err != nil {
+				return err
+			}
+		}
+		return s.SaveTodo(r.Board, task)
+	case EVENT_TODO_ARCHIVED:
+		return s.RestoreTodo(r.Model)
+	case EVENT_TODO_RESTORED:
+		return s.ArchiveTodo(r.Model)
+	case EVENT_TAG_CREATED:
+		tag := &model.Tag{}
+		if err := json.Unmarshal(r.After, tag); err != nil {
+			return errors.Wrap(err, "unable to parse the tag")
+		}
+		return s.RemoveTag(tag.Name)
+	case EVENT_TAG_UPDATED, EVENT_TAG_DELETED:
+		tag := &model.Tag{}
+		if err := json.Unmarshal(r.Before, tag); err != nil {
+			return errors.Wrap(err, "unable to parse the tag")
+		}
+		return s.SaveTag(tag)
+	case EVENT_USER_CREATED:
+		user := &model.User{}
+		if err := json.Unmarshal(r.After, user); err != nil {
+			return errors.Wrap(err, "unable to parse the user")
+		}
+		return s.RemoveUser(user.Name)
+	case EVENT_USER_UPDATED, EVENT_USER_DELETED:
+		user := &model.User{}
+		if err := json.Unmarshal(r.Before, user); err != nil {
+			return errors.Wrap(err, "unable to parse the user")
+		}
+		return s.SaveUser(user)
+	case EVENT_MILESTONE_CREATED:
+		return s.RemoveMilestone(r.Model)
+	case EVENT_MILESTONE_UPDATED, EVENT_MILESTONE_DELETED:
+		milestone := &model.Milestone{}
+		if err := json.Unmarshal(r.Before, milestone); err != nil {
+			return errors.Wrap(err, "unable to parse the milestone")
+		}
+		return s.SaveMilestone(milestone)
+	}
+	return errors.Errorf("unable to undo a change of type %s", r.Type)
+}
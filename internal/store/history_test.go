@@ -0,0 +1,195 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chordflower/todoman/internal/model"
+)
+
+// fillAreas adds to the given store the tag and user with the given names, and a todo with both of them in the
+// repository, in the archive and in the trash
+func fillAreas(t *testing.T, s Store, tag, user string) {
+	t.Helper()
+	check := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	check(s.SaveTag(model.NewTag2(tag, "#ff0000")))
+	check(s.SaveUser(model.NewUser(user, "", "")))
+	board := model.NewBoard2("main", "")
+	check(s.SaveBoard(board))
+	for _, name := range []string{"open", "archived", "removed"} {
+		todo := model.NewTodo(name)
+		todo.AddTag(tag)
+		todo.Assignees = []string{user}
+		check(s.SaveTodo(board.ID, todo))
+	}
+	for _, task := range s.AllTodos() {
+		switch task.Base().Name {
+		case "archived":
+			check(s.ArchiveTodo(task.Base().ID))
+		case "removed":
+			check(s.RemoveTodo(task.Base().ID))
+		}
+	}
+}
+
+// areaTodos returns the tags and assignees of every todo of the given store and of its areas, by the name of the todo
+func areaTodos(t *testing.T, s Store) map[string]string {
+	t.Helper()
+	todos := make(map[string]string)
+	add := func(s Store) {
+		for _, task := range s.AllTodos() {
+			todos[task.Base().Name] = strings.Join(task.Base().Tags, ",") + "|" +
+				strings.Join(task.Base().Assignees, ",")
+		}
+	}
+	add(s)
+	for _, open := range []func() (Store, error){s.Archive, s.Trash} {
+		area, err := open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		add(area)
+		if err := area.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return todos
+}
+
+func TestRewriteTodosUndo(t *testing.T) {
+	tests := []struct {
+		name    string
+		rewrite func(s Store) error
+		want    string // The tags and assignees of every todo after the rewrite
+	}{
+		{"rename tag", func(s Store) error { return RenameTag(s, "work", "job") }, "job|ann"},
+		{"remove tag", func(s Store) error { return RemoveTag(s, "work") }, "|ann"},
+		{"remove user", func(s Store) error { return RemoveUser(s, "ann") }, "work|"},
+	}
+	for _, backend := range []string{JSONBackend, SQLiteBackend} {
+		for _, test := range tests {
+			t.Run(backend+" "+test.name, func(t *testing.T) {
+				root := t.TempDir()
+				s := newBackendStore(t, root, backend)
+				fillAreas(t, s, "work", "ann")
+				before := areaTodos(t, s)
+				path := filepath.Join(root, HistoryFile)
+				if err := test.rewrite(NewHistoryStore(s, path, "", "rewrite", "")); err != nil {
+					t.Fatal(err)
+				}
+				for name, got := range areaTodos(t, s) {
+					if got != test.want {
+						t.Errorf("todo %s has %s after the rewrite, expected %s", name, got, test.want)
+					}
+				}
+				revisions, err := ReadHistory(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				areas := make(map[string]bool)
+				for _, revision := range revisions {
+					if revision.Command != revisions[0].Command {
+						t.Errorf("the rewrite made more than one command")
+					}
+					areas[revision.Area] = true
+				}
+				if !areas[""] || !areas[ArchiveDir] || !areas[TrashDir] {
+					t.Errorf("the history has revisions of the areas %v, expected all of them", areas)
+				}
+
+				if _, err := Undo(s, path, 1); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.Close(); err != nil {
+					t.Fatal(err)
+				}
+				s = newBackendStore(t, root, backend)
+				defer s.Close()
+				after := areaTodos(t, s)
+				for name, want := range before {
+					if after[name] != want {
+						t.Errorf("todo %s has %s after the undo, expected %s", name, after[name], want)
+					}
+				}
+				if _, err := s.Tag("work"); err != nil {
+					t.Errorf("the tag was not brought back: %s", err)
+				}
+				if _, err := s.User("ann"); err != nil {
+					t.Errorf("the user was not brought back: %s", err)
+				}
+			})
+		}
+	}
+}
+
+func TestMilestoneUndo(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(s Store, milestone *model.Milestone) error
+	}{
+		{"create", func(s Store, milestone *model.Milestone) error {
+			return s.SaveMilestone(model.NewMilestone("beta"))
+		}},
+		{"update", func(s Store, milestone *model.Milestone) error {
+			milestone.Description = "second"
+			return s.SaveMilestone(milestone)
+		}},
+		{"remove", func(s Store, milestone *model.Milestone) error {
+			return s.RemoveMilestone(milestone.ID)
+		}},
+	}
+	for _, backend := range []string{JSONBackend, SQLiteBackend} {
+		for _, test := range tests {
+			t.Run(backend+" "+test.name, func(t *testing.T) {
+				root := t.TempDir()
+				s := newBackendStore(t, root, backend)
+				milestone := model.NewMilestone("release")
+				milestone.Description = "first"
+				if err := s.SaveMilestone(milestone); err != nil {
+					t.Fatal(err)
+				}
+				path := filepath.Join(root, HistoryFile)
+				hs := NewHistoryStore(s, path, "", "milestone", "")
+				found, err := hs.Milestone(milestone.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := test.change(hs, found); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := Undo(s, path, 1); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.Close(); err != nil {
+					t.Fatal(err)
+				}
+				s = newBackendStore(t, root, backend)
+				defer s.Close()
+				milestones := s.Milestones()
+				if len(milestones) != 1 || milestones[0].Name != "release" || milestones[0].Description != "first" {
+					t.Errorf("the milestones after the undo are %v", milestones)
+				}
+			})
+		}
+	}
+}
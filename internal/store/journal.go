@@ -0,0 +1,320 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"emperror.dev/errors"
+	"github.com/gofrs/uuid"
+)
+
+// JournalFile is the write-ahead journal of a json store, which keeps every operation on the files of the store
+// before it is applied, so that an operation interrupted by a crash is replayed the next time the store is opened.
+// The journal only exists while an operation is being applied, or after it was interrupted.
+const JournalFile = "journal.log"
+
+// fileOp is a change of a file of a json store, whose paths are relative to the root of the store
+type fileOp struct {
+	Op   string `json:"op"`             // Either write, append, rename or remove
+	Path string `json:"path"`           // The written, appended to, renamed or removed file or directory
+	To   string `json:"to,omitempty"`   // The new path of a renamed file or directory
+	At   int64  `json:"at,omitempty"`   // The offset of an appended file where its data are written
+	Data []byte `json:"data,omitempty"` // The content of a written file, or the data appended to it
+}
+
+// apply makes the change of this operation inside the given root, in a way that can be repeated after a crash
+func (op *fileOp) apply(root string) error {
+	path := filepath.Join(root, op.Path)
+	switch op.Op {
+	case "write":
+		return writeFile(path, op.Data)
+	case "append":
+		return writeAt(path, op.At, op.Data)
+	case "rename":
+		to := filepath.Join(root, op.To)
+		// A repeated rename was already done
+		if !exists(path) && exists(to) {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+			return errors.Wrapf(err, "unable to create the directory of %s", to)
+		}
+		if err := os.Rename(path, to); err != nil {
+			return errors.Wrapf(err, "unable to move %s", path)
+		}
+		syncDir(filepath.Dir(path))
+		syncDir(filepath.Dir(to))
+	case "remove":
+		if err := os.RemoveAll(path); err != nil {
+			return errors.Wrapf(err, "unable to remove %s", path)
+		}
+		syncDir(filepath.Dir(path))
+	default:
+		return errors.Errorf("unknown journal operation %q", op.Op)
+	}
+	return nil
+}
+
+// writeFile replaces the given file with the given data at once, by writing them to a temporary file that is synced
+// to the disk and then renamed over the file, so that the file never has only part of the data
+func writeFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return errors.Wrapf(err, "unable to create the directory of %s", path)
+	}
+	temp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return errors.Wrapf(err, "unable to write %s", path)
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return errors.Wrapf(err, "unable to write %s", path)
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return errors.Wrapf(err, "unable to write %s", path)
+	}
+	if err := temp.Close(); err != nil {
+		return errors.Wrapf(err, "unable to write %s", path)
+	}
+	if err := os.Chmod(temp.Name(), 0o644); err != nil {
+		return errors.Wrapf(err, "unable to write %s", path)
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return errors.Wrapf(err, "unable to write %s", path)
+	}
+	syncDir(dir)
+	return nil
+}
+
+// writeAt replaces the given file from the given offset on with the given data, creating it if needed, so that
+// writing the same data at the same offset again leaves the file as it was
+func writeAt(path string, at int64, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return errors.Wrapf(err, "unable to create the directory of %s", path)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open %s", path)
+	}
+	defer file.Close()
+	if err := file.Truncate(at); err != nil {
+		return errors.Wrapf(err, "unable to write %s", path)
+	}
+	if _, err := file.WriteAt(data, at); err != nil {
+		return errors.Wrapf(err, "unable to write %s", path)
+	}
+	return errors.Wrapf(file.Sync(), "unable to write %s", path)
+}
+
+// syncDir syncs the given directory to the disk, so that the files created, renamed or removed in it stay that way
+// after a crash, on the systems that support it
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+}
+
+// transaction is a group of file operations of a json store, that are applied together or not at all
+type transaction struct {
+	root string
+	ops  []fileOp
+	ends map[string]int64 // The sizes the files appended to by the transaction have once it is applied
+}
+
+// begin starts a new transaction of this store, or returns the transaction of the current batch
+func (s *jsonStore) begin() *transaction {
+	if s.pending != nil {
+		return s.pending
+	}
+	return &transaction{root: s.root, ops: make([]fileOp, 0), ends: make(map[string]int64)}
+}
+
+// transact runs the given function with a new transaction of this store, and commits the transaction when the
+// function succeeds
+func (s *jsonStore) transact(fn func(tx *transaction) error) error {
+	tx := s.begin()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return s.commit(tx)
+}
+
+// rel returns the given path relative to the root of the store of this transaction
+func (tx *transaction) rel(path string) string {
+	if rel, err := filepath.Rel(tx.root, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// write adds the writing of the given value as json to the given file
+func (tx *transaction) write(path string, value any) error {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return errors.Wrapf(err, "unable to encode %s", path)
+	}
+	tx.ops = append(tx.ops, fileOp{Op: "write", Path: tx.rel(path), Data: buffer.Bytes()})
+	return nil
+}
+
+// writeLog adds the writing of the given data to the given log file from the given offset on, dropping what followed
+// it, or at its end when the offset is negative
+func (tx *transaction) writeLog(path string, at int64, data []byte) {
+	if at < 0 {
+		at = tx.end(path)
+	}
+	tx.ends[path] = at + int64(len(data))
+	tx.ops = append(tx.ops, fileOp{Op: "append", Path: tx.rel(path), At: at, Data: data})
+}
+
+// end returns the size the given file has once the transaction is applied
+func (tx *transaction) end(path string) int64 {
+	if end, ok := tx.ends[path]; ok {
+		return end
+	}
+	if info, err := os.Stat(path); err == nil {
+		return info.Size()
+	}
+	return 0
+}
+
+// rename adds the renaming of the given file or directory, creating the directory of its new path if needed
+func (tx *transaction) rename(from, to string) {
+	tx.ops = append(tx.ops, fileOp{Op: "rename", Path: tx.rel(from), To: tx.rel(to)})
+}
+
+// remove adds the removal of the given file or directory and all of its contents
+func (tx *transaction) remove(path string) {
+	tx.ops = append(tx.ops, fileOp{Op: "remove", Path: tx.rel(path)})
+}
+
+// journalEntry is a record of the journal, with all of the operations of a transaction
+type journalEntry struct {
+	ID  uuid.UUID `json:"id"`
+	Ops []fileOp  `json:"ops"`
+}
+
+// appendJournal adds the given entry to the journal of the given root and syncs it to the disk
+func appendJournal(root string, entry *journalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode the journal entry")
+	}
+	file, err := os.OpenFile(filepath.Join(root, JournalFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.Wrap(err, "unable to open the journal")
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return errors.Wrap(err, "unable to write the journal")
+	}
+	if err := file.Sync(); err != nil {
+		return errors.Wrap(err, "unable to write the journal")
+	}
+	syncDir(root)
+	return nil
+}
+
+// commit records the operations of the given transaction in the journal, applies them and then empties the journal.
+// A transaction that fails to be applied stays in the journal, and is replayed the next time the store is opened.
+func (s *jsonStore) commit(tx *transaction) error {
+	// The transaction of a batch is committed when the batch ends
+	if len(tx.ops) == 0 || tx == s.pending {
+		return nil
+	}
+	if s.lock != nil && !s.lock.Exclusive() {
+		return errors.New("the repository was opened for reading only")
+	}
+	id, _ := uuid.NewV4()
+	if err := appendJournal(s.root, &journalEntry{ID: id, Ops: tx.ops}); err != nil {
+		return err
+	}
+	for i := range tx.ops {
+		if err := tx.ops[i].apply(s.root); err != nil {
+			return err
+		}
+	}
+	return emptyJournal(s.root)
+}
+
+// batch makes the changes of the given function in a single transaction, which is committed when the function
+// succeeds. The files are only written when the batch ends, so the changes of a batch can not depend on the files
+// written by its earlier changes, like the trash of a removed todo. When the function fails the in memory state is
+// read again from the files, dropping its changes.
+func (s *jsonStore) batch(fn func() error) error {
+	if s.pending != nil {
+		return fn()
+	}
+	s.pending = s.begin()
+	err := fn()
+	tx := s.pending
+	s.pending = nil
+	if err != nil {
+		return errors.Combine(err, s.reload())
+	}
+	return s.commit(tx)
+}
+
+func (s *jsonStore) writeLog(path string, at int64, data []byte) error {
+	return s.transact(func(tx *transaction) error {
+		tx.writeLog(path, at, data)
+		return nil
+	})
+}
+
+// emptyJournal removes the journal of the given root, once all of its transactions are applied
+func emptyJournal(root string) error {
+	if err := os.Remove(filepath.Join(root, JournalFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrap(err, "unable to empty the journal")
+	}
+	return nil
+}
+
+// recoverJournal replays the interrupted transactions of the journal of the given root and then empties it,
+// returning how many were replayed. The last entry may have been cut by a crash while it was written, and its
+// transaction is rolled back by ignoring it, since none of its operations were applied.
+func recoverJournal(root string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(root, JournalFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, errors.Wrap(err, "unable to read the journal")
+	}
+	pending := make([]*journalEntry, 0)
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		entry := &journalEntry{}
+		if len(bytes.TrimSpace(line)) == 0 || json.Unmarshal(line, entry) != nil {
+			continue
+		}
+		pending = append(pending, entry)
+	}
+	for _, entry := range pending {
+		for i := range entry.Ops {
+			if err := entry.Ops[i].apply(root); err != nil {
+				return 0, errors.Wrapf(err, "unable to replay the journal entry %s", entry.ID)
+			}
+		}
+	}
+	return len(pending), emptyJournal(root)
+}
@@ -0,0 +1,219 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chordflower/todoman/internal/model"
+)
+
+// writeFiles creates the given files inside the given root, with their contents
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRecoverJournal(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string // The files before the journal is replayed
+		entries  [][]fileOp        // The complete entries of the journal
+		cut      string            // The start of an entry that was being written when the crash happened
+		replayed int
+		want     map[string]string // The files after the journal is replayed
+		missing  []string          // The files that must not exist after the journal is replayed
+	}{
+		{
+			name: "no journal",
+		},
+		{
+			name:     "write",
+			entries:  [][]fileOp{{{Op: "write", Path: "tags.json", Data: []byte("[]")}}},
+			replayed: 1,
+			want:     map[string]string{"tags.json": "[]"},
+		},
+		{
+			name:     "write over an old file",
+			files:    map[string]string{"tags.json": "old"},
+			entries:  [][]fileOp{{{Op: "write", Path: "tags.json", Data: []byte("new")}}},
+			replayed: 1,
+			want:     map[string]string{"tags.json": "new"},
+		},
+		{
+			name:     "entries in order",
+			entries:  [][]fileOp{{{Op: "write", Path: "a", Data: []byte("1")}}, {{Op: "write", Path: "a", Data: []byte("2")}}},
+			replayed: 2,
+			want:     map[string]string{"a": "2"},
+		},
+		{
+			name:     "rename",
+			files:    map[string]string{"boards/b/board.json": "board"},
+			entries:  [][]fileOp{{{Op: "rename", Path: "boards/b", To: "archive/boards/b"}}},
+			replayed: 1,
+			want:     map[string]string{"archive/boards/b/board.json": "board"},
+			missing:  []string{"boards/b"},
+		},
+		{
+			name:     "rename already done",
+			files:    map[string]string{"archive/boards/b/board.json": "board"},
+			entries:  [][]fileOp{{{Op: "rename", Path: "boards/b", To: "archive/boards/b"}}},
+			replayed: 1,
+			want:     map[string]string{"archive/boards/b/board.json": "board"},
+			missing:  []string{"boards/b"},
+		},
+		{
+			name:     "remove",
+			files:    map[string]string{"trash/boards/b/board.json": "board"},
+			entries:  [][]fileOp{{{Op: "remove", Path: "trash/boards/b"}}},
+			replayed: 1,
+			missing:  []string{"trash/boards/b"},
+		},
+		{
+			name:     "remove already done",
+			entries:  [][]fileOp{{{Op: "remove", Path: "trash/boards/b"}}},
+			replayed: 1,
+			missing:  []string{"trash/boards/b"},
+		},
+		{
+			name:     "append",
+			files:    map[string]string{"history.jsonl": "a\n"},
+			entries:  [][]fileOp{{{Op: "append", Path: "history.jsonl", At: 2, Data: []byte("b\n")}}},
+			replayed: 1,
+			want:     map[string]string{"history.jsonl": "a\nb\n"},
+		},
+		{
+			name:     "append already done",
+			files:    map[string]string{"history.jsonl": "a\nb\n"},
+			entries:  [][]fileOp{{{Op: "append", Path: "history.jsonl", At: 2, Data: []byte("b\n")}}},
+			replayed: 1,
+			want:     map[string]string{"history.jsonl": "a\nb\n"},
+		},
+		{
+			name:     "append to a new file",
+			entries:  [][]fileOp{{{Op: "append", Path: "audit.jsonl", Data: []byte("a\n")}}},
+			replayed: 1,
+			want:     map[string]string{"audit.jsonl": "a\n"},
+		},
+		{
+			name:     "truncate",
+			files:    map[string]string{"history.jsonl": "a\nb\nc\n"},
+			entries:  [][]fileOp{{{Op: "append", Path: "history.jsonl", At: 2}}},
+			replayed: 1,
+			want:     map[string]string{"history.jsonl": "a\n"},
+		},
+		{
+			name:     "cut entry",
+			entries:  [][]fileOp{{{Op: "write", Path: "tags.json", Data: []byte("[]")}}},
+			cut:      `{"id":"6ba7b810-9dad-11d1-80b4-00c04fd430c8","ops":[{"op":"write","path":"users.json"`,
+			replayed: 1,
+			want:     map[string]string{"tags.json": "[]"},
+			missing:  []string{"users.json"},
+		},
+		{
+			name:  "only a cut entry",
+			files: map[string]string{"users.json": "[]"},
+			cut:   `{"id":"6ba7b810-9dad-11d1-80b4-00c04fd430c8","ops":[{"op":"remove","path":"users.json"}`,
+			want:  map[string]string{"users.json": "[]"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, test.files)
+			for _, ops := range test.entries {
+				if err := appendJournal(root, &journalEntry{Ops: ops}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if test.cut != "" {
+				writeFiles(t, root, map[string]string{JournalFile: readFile(t, root, JournalFile) + test.cut})
+			}
+			replayed, err := recoverJournal(root)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if replayed != test.replayed {
+				t.Errorf("replayed %d entries, expected %d", replayed, test.replayed)
+			}
+			for name, content := range test.want {
+				if got := readFile(t, root, name); got != content {
+					t.Errorf("%s has %q, expected %q", name, got, content)
+				}
+			}
+			for _, name := range append(test.missing, JournalFile) {
+				if exists(filepath.Join(root, filepath.FromSlash(name))) {
+					t.Errorf("%s still exists", name)
+				}
+			}
+		})
+	}
+}
+
+// readFile returns the content of the given file inside the given root, empty when it does not exist
+func readFile(t *testing.T, root, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestJSONStoreReplaysJournal(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewJSONStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A crash right after the change was recorded in the journal, before any of its files was written
+	board := model.NewBoard2("crashed", "")
+	js := s.(*jsonStore)
+	tx := js.begin()
+	if err := js.saveBoard(tx, board); err != nil {
+		t.Fatal(err)
+	}
+	tx.writeLog(filepath.Join(root, HistoryFile), -1, []byte("{}\n"))
+	if err := appendJournal(root, &journalEntry{Ops: tx.ops}); err != nil {
+		t.Fatal(err)
+	}
+	if exists(filepath.Join(js.boardDir(board.ID), "board.json")) {
+		t.Fatal("the board was written before the journal was replayed")
+	}
+
+	s, err = NewJSONStore(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := s.Board(board.ID); err != nil {
+		t.Errorf("the board was not replayed: %s", err)
+	}
+	if got := readFile(t, root, HistoryFile); got != "{}\n" {
+		t.Errorf("the history has %q, expected its line", got)
+	}
+	if exists(filepath.Join(root, JournalFile)) {
+		t.Error("the journal was not emptied")
+	}
+}
@@ -0,0 +1,466 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/chordflower/todoman/internal/utils"
+	"github.com/gofrs/uuid"
+)
+
+// jsonStore is a store that keeps each model in its own json file, with the following layout:
+//
+//	<root>/index.json                          the index of all boards
+//	<root>/tags.json                           all of the tags
+//	<root>/users.json                          all of the users
+//	<root>/milestones.json                     all of the milestones
+//	<root>/boards/<board>/board.json           a board
+//	<root>/boards/<board>/index.json           the index of the todos of a board
+//	<root>/boards/<board>/todos/<todo>.json    a todo of a board
+//	<root>/archive/...                         the archived boards and todos, with the same layout
+//	<root>/trash/...                           the removed boards and todos, with the same layout
+//	<root>/journal.log                         the write-ahead journal of the changes of the files
+//
+// Every file is written to a temporary file and renamed over the old one, and the changes made together are recorded
+// in the journal before they are applied, so that a crash never leaves a file or a change half done.
+type jsonStore struct {
+	*memory
+	root    string
+	lock    *Lock
+	pending *transaction // The transaction of the changes of the current batch, if any
+}
+
+// NewJSONStore opens (or creates) a json file based store at the given directory, without locking it
+func NewJSONStore(root string) (Store, error) {
+	s := &jsonStore{memory: newMemory(), root: root}
+	if err := os.MkdirAll(filepath.Join(root, "boards"), 0o755); err != nil {
+		return nil, errors.Wrap(err, "unable to create the repository")
+	}
+	replayed, err := recoverJournal(root)
+	if err != nil {
+		return nil, err
+	}
+	if replayed > 0 {
+		utils.Warning("Replayed %d interrupted changes of %s", replayed, root)
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenJSONStore takes the lock of the repository at the given directory, exclusive when the store changes it, and
+// opens its json file based store, which holds the lock until it is closed. A store opened for reading can not
+// change the repository, unless it had interrupted changes, which are replayed under an exclusive lock.
+func OpenJSONStore(root string, exclusive bool, timeout time.Duration) (Store, error) {
+	if exists(filepath.Join(root, JournalFile)) {
+		exclusive = true
+	}
+	lock, err := LockRepository(root, exclusive, timeout)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewJSONStore(root)
+	if err != nil {
+		lock.Unlock()
+		return nil, err
+	}
+	s.(*jsonStore).lock = lock
+	return s, nil
+}
+
+func (s *jsonStore) Close() error {
+	if s.lock == nil {
+		return nil
+	}
+	lock := s.lock
+	s.lock = nil
+	return lock.Unlock()
+}
+
+func (s *jsonStore) boardDir(id uuid.UUID) string {
+	return filepath.Join(s.root, "boards", id.String())
+}
+
+func (s *jsonStore) todoFile(board, id uuid.UUID) string {
+	return filepath.Join(s.boardDir(board), "todos", id.String()+".json")
+}
+
+// load reads every board and todo of the repository
+func (s *jsonStore) load() error {
+	entries, err := os.ReadDir(filepath.Join(s.root, "boards"))
+	if err != nil {
+		return errors.Wrap(err, "unable to read the repository")
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := s.loadBoard(filepath.Join(s.root, "boards", entry.Name())); err != nil {
+			return err
+		}
+	}
+	if err := s.loadTags(); err != nil {
+		return err
+	}
+	if err := s.loadUsers(); err != nil {
+		return err
+	}
+	return s.loadMilestones()
+}
+
+// reload replaces the in memory state with the one of the files, dropping the changes that were not committed
+func (s *jsonStore) reload() error {
+	s.memory = newMemory()
+	return s.load()
+}
+
+// loadBoard reads the board of the given directory and its todos
+func (s *jsonStore) loadBoard(dir string) (*model.Board, error) {
+	board := &model.Board{}
+	if err := readJSON(filepath.Join(dir, "board.json"), board); err != nil {
+		return nil, err
+	}
+	s.boards[board.ID] = board
+	s.boardIndex.AddItem(model.NewItem(board.ID, board.Name))
+	return board, s.loadTodos(board, dir)
+}
+
+// loadTags reads the tags of the repository
+func (s *jsonStore) loadTags() error {
+	tags := make([]*model.Tag, 0)
+	if err := readJSON(filepath.Join(s.root, "tags.json"), &tags); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, tag := range tags {
+		s.tags[tag.ID] = tag
+	}
+	return nil
+}
+
+// loadUsers reads the users of the repository
+func (s *jsonStore) loadUsers() error {
+	users := make([]*model.User, 0)
+	if err := readJSON(filepath.Join(s.root, "users.json"), &users); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, user := range users {
+		s.users[user.ID] = user
+	}
+	return nil
+}
+
+// loadMilestones reads the milestones of the repository
+func (s *jsonStore) loadMilestones() error {
+	milestones := make([]*model.Milestone, 0)
+	if err := readJSON(filepath.Join(s.root, "milestones.json"), &milestones); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, milestone := range milestones {
+		s.milestones[milestone.ID] = milestone
+	}
+	return nil
+}
+
+// loadTodos reads the todos of the given board from the given directory ordered by their rank, the todos without a rank go first in the
+// order of the index of the board, and then the ones that are not in the index ordered by their creation date
+func (s *jsonStore) loadTodos(board *model.Board, dir string) error {
+	entries, err := os.ReadDir(filepath.Join(dir, "todos"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "unable to read the todos of board %s", board.Name)
+	}
+	tasks := make([]model.Task, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "todos", entry.Name()))
+		if err != nil {
+			return errors.Wrap(err, "unable to read todo")
+		}
+		task, err := DecodeTask(data)
+		if err != nil {
+			return errors.Wrapf(err, "unable to parse todo %s", entry.Name())
+		}
+		tasks = append(tasks, task)
+	}
+	index := model.NewIndex()
+	if err := readJSON(filepath.Join(dir, "index.json"), index); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	position := func(task model.Task) int {
+		if p := index.Items.Position(task.Base().ID); p != -1 {
+			return p
+		}
+		return index.Items.Len()
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if ri, rj := tasks[i].Base().Rank, tasks[j].Base().Rank; ri != rj {
+			return rankBefore(ri, rj)
+		}
+		if pi, pj := position(tasks[i]), position(tasks[j]); pi != pj {
+			return pi < pj
+		}
+		return tasks[i].Base().CreationDate.Time().Before(tasks[j].Base().CreationDate.Time())
+	})
+	for _, task := range tasks {
+		s.attach(board, task)
+	}
+	return nil
+}
+
+// DecodeTask reads either a todo or an agile todo from the given json data
+func DecodeTask(data []byte) (model.Task, error) {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if _, agile := fields["efforts"]; agile {
+		ag := &model.AgileTodo{}
+		return ag, json.Unmarshal(data, ag)
+	}
+	t := &model.Todo{}
+	return t, json.Unmarshal(data, t)
+}
+
+func readJSON(path string, value any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read %s", path)
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		return errors.Wrapf(err, "unable to parse %s", path)
+	}
+	return nil
+}
+
+func (s *jsonStore) SaveBoard(board *model.Board) error {
+	tx := s.begin()
+	if err := s.saveBoard(tx, board); err != nil {
+		return err
+	}
+	return s.commit(tx)
+}
+
+// saveBoard adds the saving of the given board to the given transaction
+func (s *jsonStore) saveBoard(tx *transaction, board *model.Board) error {
+	if err := board.Validate(); err != nil {
+		return invalid(err)
+	}
+	if err := tx.write(filepath.Join(s.boardDir(board.ID), "board.json"), board); err != nil {
+		return err
+	}
+	s.keepBoard(board)
+	return tx.write(filepath.Join(s.root, "index.json"), s.boardIndex)
+}
+
+func (s *jsonStore) RemoveBoard(id uuid.UUID) error {
+	tx := s.begin()
+	if err := s.stashBoard(tx, TrashDir, id); err != nil {
+		return err
+	}
+	if err := s.markRemoved(tx, id, time.Now()); err != nil {
+		return err
+	}
+	return s.commit(tx)
+}
+
+func (s *jsonStore) SaveTodo(board uuid.UUID, task model.Task) error {
+	b, err := s.Board(board)
+	if err != nil {
+		return err
+	}
+	if err := task.Validate(); err != nil {
+		return invalid(err)
+	}
+	todo := task.Base()
+	if owner, ok := s.owners[todo.ID]; ok && owner != board {
+		return errors.Errorf("todo %s belongs to another board", todo.Name)
+	}
+	if todo.Rank == "" && !b.HasTodo(todo.ID) {
+		last := ""
+		if b.Todos.Len() > 0 {
+			last = b.Todos.At(b.Todos.Len() - 1).Rank
+		}
+		todo.Rank = model.RankBetween(last, "")
+	}
+	tx := s.begin()
+	if err := tx.write(s.todoFile(board, todo.ID), task); err != nil {
+		return err
+	}
+	if old, ok := s.todos[todo.ID]; ok && old != task {
+		b.Todos.Insert(b.Todos.Position(todo.ID), todo)
+	}
+	s.attach(b, task)
+	s.place(b, todo)
+	if err := tx.write(filepath.Join(s.boardDir(board), "index.json"), s.boardTodoIndex(b)); err != nil {
+		return err
+	}
+	return s.commit(tx)
+}
+
+func (s *jsonStore) TransferTodo(id uuid.UUID, board uuid.UUID) error {
+	from, err := s.BoardOf(id)
+	if err != nil {
+		return err
+	}
+	to, err := s.Board(board)
+	if err != nil {
+		return err
+	}
+	if from.ID == to.ID {
+		return nil
+	}
+	task := s.todos[id]
+	todo := task.Base()
+	rank := todo.Rank
+	todo.Rank = ""
+	if to.Todos.Len() > 0 {
+		todo.Rank = to.Todos.At(to.Todos.Len() - 1).Rank
+	}
+	todo.Rank = model.RankBetween(todo.Rank, "")
+	// The todo gets its new rank while still in the old board, and then the rename moves it to the new board at once
+	tx := s.begin()
+	if err := tx.write(s.todoFile(from.ID, id), task); err != nil {
+		todo.Rank = rank
+		return err
+	}
+	tx.rename(s.todoFile(from.ID, id), s.todoFile(to.ID, id))
+	from.RemoveTodo(id)
+	s.attach(to, task)
+	s.place(to, todo)
+	if err := tx.write(filepath.Join(s.boardDir(from.ID), "index.json"), s.boardTodoIndex(from)); err != nil {
+		return err
+	}
+	if err := tx.write(filepath.Join(s.boardDir(to.ID), "index.json"), s.boardTodoIndex(to)); err != nil {
+		return err
+	}
+	return s.commit(tx)
+}
+
+func (s *jsonStore) RemoveTodo(id uuid.UUID) error {
+	tx := s.begin()
+	if err := s.stashTodo(tx, TrashDir, id); err != nil {
+		return err
+	}
+	if err := s.markRemoved(tx, id, time.Now()); err != nil {
+		return err
+	}
+	return s.commit(tx)
+}
+
+func (s *jsonStore) SaveTag(tag *model.Tag) error {
+	if err := tag.Validate(); err != nil {
+		return invalid(err)
+	}
+	if other, err := s.Tag(tag.Name); err == nil && other.ID != tag.ID {
+		return errors.Errorf("there is already a tag named %s", tag.Name)
+	}
+	s.tags[tag.ID] = tag
+	return s.writeTags()
+}
+
+func (s *jsonStore) RemoveTag(name string) error {
+	tag, err := s.Tag(name)
+	if err != nil {
+		return err
+	}
+	delete(s.tags, tag.ID)
+	return s.writeTags()
+}
+
+// writeTags writes all of the tags to their file
+func (s *jsonStore) writeTags() error {
+	tx := s.begin()
+	if err := tx.write(filepath.Join(s.root, "tags.json"), s.Tags()); err != nil {
+		return err
+	}
+	return s.commit(tx)
+}
+
+func (s *jsonStore) SaveUser(user *model.User) error {
+	if err := user.Validate(); err != nil {
+		return invalid(err)
+	}
+	if other, err := s.User(user.Name); err == nil && other.ID != user.ID {
+		return errors.Errorf("there is already a user named %s", user.Name)
+	}
+	s.users[user.ID] = user
+	return s.writeUsers()
+}
+
+func (s *jsonStore) RemoveUser(name string) error {
+	user, err := s.User(name)
+	if err != nil {
+		return err
+	}
+	delete(s.users, user.ID)
+	return s.writeUsers()
+}
+
+// writeUsers writes all of the users to their file
+func (s *jsonStore) writeUsers() error {
+	tx := s.begin()
+	if err := tx.write(filepath.Join(s.root, "users.json"), s.Users()); err != nil {
+		return err
+	}
+	return s.commit(tx)
+}
+
+func (s *jsonStore) SaveMilestone(milestone *model.Milestone) error {
+	if err := milestone.Validate(); err != nil {
+		return invalid(err)
+	}
+	if other, ok := s.milestoneNamed(milestone.Name); ok && other.ID != milestone.ID {
+		return errors.Errorf("there is already a milestone named %s", milestone.Name)
+	}
+	s.milestones[milestone.ID] = milestone
+	return s.writeMilestones()
+}
+
+func (s *jsonStore) RemoveMilestone(id uuid.UUID) error {
+	if _, err := s.Milestone(id); err != nil {
+		return err
+	}
+	delete(s.milestones, id)
+	return s.writeMilestones()
+}
+
+// writeMilestones writes all of the milestones to their file
+func (s *jsonStore) writeMilestones() error {
+	tx := s.begin()
+	if err := tx.write(filepath.Join(s.root, "milestones.json"), s.Milestones()); err != nil {
+		return err
+	}
+	return s.commit(tx)
+}
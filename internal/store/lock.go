@@ -0,0 +1,176 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"emperror.dev/errors"
+)
+
+const (
+	// LockFile is the file of a repository whose advisory lock is held by the processes that use the repository,
+	// shared by the ones that only read it and exclusive for the one that changes it
+	LockFile = "lock"
+	// HoldersDir is the directory of a repository with a file per holder of its lock, telling who holds it
+	HoldersDir = "locks"
+)
+
+// lockRetry is how often the lock of a repository is tried again while it is held by others
+const lockRetry = 50 * time.Millisecond
+
+// holders counts the holders of this process, to name the file of each one
+var holders uint64
+
+// Holder describes a holder of the lock of a repository
+type Holder struct {
+	PID       int       `json:"pid"`       // The process that holds the lock
+	Exclusive bool      `json:"exclusive"` // If it holds it to change the repository
+	Since     time.Time `json:"since"`     // When it got the lock
+	Command   string    `json:"command"`   // The command line of the process
+}
+
+// Lock is a held lock of a repository
+type Lock struct {
+	file      *os.File
+	holder    string
+	exclusive bool
+}
+
+// LockRepository waits until it gets the lock of the repository at the given directory, shared or exclusive, for
+// at most the given timeout, failing with the pids of the processes that hold it when the time runs out
+func LockRepository(root string, exclusive bool, timeout time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Join(root, HoldersDir), 0o755); err != nil {
+		return nil, errors.Wrap(err, "unable to create the repository")
+	}
+	file, err := os.OpenFile(filepath.Join(root, LockFile), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open the lock of the repository")
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLock(file, exclusive)
+		if err != nil {
+			file.Close()
+			return nil, errors.Wrap(err, "unable to lock the repository")
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, lockedError(root, exclusive)
+		}
+		time.Sleep(lockRetry)
+	}
+	removeStaleHolders(root)
+	holder := &Holder{PID: os.Getpid(), Exclusive: exclusive, Since: time.Now(), Command: strings.Join(os.Args, " ")}
+	name := filepath.Join(root, HoldersDir, fmt.Sprintf("%d-%d.json", holder.PID, atomic.AddUint64(&holders, 1)))
+	data, _ := json.Marshal(holder)
+	// The file is written at once, so that no one reads it half written and takes it for a stale one
+	if err := writeFile(name, data); err != nil {
+		unlock(file)
+		file.Close()
+		return nil, errors.Wrap(err, "unable to lock the repository")
+	}
+	return &Lock{file: file, holder: name, exclusive: exclusive}, nil
+}
+
+// Exclusive checks if this lock allows changing the repository
+func (l *Lock) Exclusive() bool {
+	return l.exclusive
+}
+
+// Unlock releases this lock
+func (l *Lock) Unlock() error {
+	if err := os.Remove(l.holder); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrap(err, "unable to unlock the repository")
+	}
+	if err := unlock(l.file); err != nil {
+		l.file.Close()
+		return errors.Wrap(err, "unable to unlock the repository")
+	}
+	return l.file.Close()
+}
+
+// Holders returns the holders of the lock of the repository at the given directory, without the stale ones left by
+// the processes that ended without releasing it
+func Holders(root string) []*Holder {
+	ret := make([]*Holder, 0)
+	entries, _ := os.ReadDir(filepath.Join(root, HoldersDir))
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		holder := &Holder{}
+		data, err := os.ReadFile(filepath.Join(root, HoldersDir, entry.Name()))
+		if err != nil || json.Unmarshal(data, holder) != nil || !alive(holder.PID) {
+			continue
+		}
+		ret = append(ret, holder)
+	}
+	return ret
+}
+
+// removeStaleHolders removes the files of the holders of the lock of the repository at the given directory whose
+// processes ended without releasing it, the lock itself is released by the system when a process ends. A file is only
+// removed when its process is known to have ended, and the files being written are left alone.
+func removeStaleHolders(root string) {
+	entries, _ := os.ReadDir(filepath.Join(root, HoldersDir))
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(root, HoldersDir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if pid, ok := holderPID(entry.Name(), data); ok && !alive(pid) {
+			os.Remove(path)
+		}
+	}
+}
+
+// holderPID returns the pid of the holder of the file with the given name and content, read from the content or else
+// from the name, which starts with it
+func holderPID(name string, data []byte) (int, bool) {
+	holder := &Holder{}
+	if json.Unmarshal(data, holder) == nil && holder.PID > 0 {
+		return holder.PID, true
+	}
+	prefix, _, _ := strings.Cut(name, "-")
+	pid, err := strconv.Atoi(prefix)
+	return pid, err == nil && pid > 0
+}
+
+// lockedError returns the error of a repository whose lock could not be taken in time
+func lockedError(root string, exclusive bool) error {
+	for _, holder := range Holders(root) {
+		// A reader only waits for a writer, while a writer waits for everyone
+		if holder.PID != os.Getpid() && (exclusive || holder.Exclusive) {
+			return errors.Errorf("repository is locked by pid %d since %s (%s)", holder.PID,
+				holder.Since.Local().Format("15:04:05"), holder.Command)
+		}
+	}
+	return errors.New("repository is locked by another process")
+}
@@ -0,0 +1,38 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !unix
+
+package store
+
+import (
+	"os"
+)
+
+// tryLock takes the lock of the given file at once, since the other systems have no advisory locks, where every
+// process runs alone and the holders of the lock still tell who is using the repository
+func tryLock(file *os.File, exclusive bool) (bool, error) {
+	return true, nil
+}
+
+// unlock releases the lock of the given file
+func unlock(file *os.File) error {
+	return nil
+}
+
+// alive checks if the process with the given pid is still running
+func alive(pid int) bool {
+	_, err := os.FindProcess(pid)
+	return err == nil
+}
@@ -0,0 +1,253 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"sort"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/gofrs/uuid"
+)
+
+// memory is the in memory state of the boards, todos and tags of a store, which every store keeps so that the
+// models it returns are the same until they are saved
+type memory struct {
+	boards     map[uuid.UUID]*model.Board
+	todos      map[uuid.UUID]model.Task
+	owners     map[uuid.UUID]uuid.UUID
+	tags       map[uuid.UUID]*model.Tag
+	users      map[uuid.UUID]*model.User
+	milestones map[uuid.UUID]*model.Milestone
+	boardIndex *model.Index
+	todoIndex  *model.Index
+}
+
+// newMemory creates an empty in memory state
+func newMemory() *memory {
+	return &memory{
+		boards:     make(map[uuid.UUID]*model.Board),
+		todos:      make(map[uuid.UUID]model.Task),
+		owners:     make(map[uuid.UUID]uuid.UUID),
+		tags:       make(map[uuid.UUID]*model.Tag),
+		users:      make(map[uuid.UUID]*model.User),
+		milestones: make(map[uuid.UUID]*model.Milestone),
+		boardIndex: model.NewIndex(),
+		todoIndex:  model.NewIndex(),
+	}
+}
+
+// rankBefore checks if a todo with the first rank goes before one with the second rank, the todos created before
+// the ranks existed have none and go before the others
+func rankBefore(a, b string) bool {
+	if (a == "") != (b == "") {
+		return a == ""
+	}
+	return a < b
+}
+
+// place moves the given todo of the given board to the position of its rank, the todos without a rank keep their
+// position
+func (s *memory) place(board *model.Board, todo *model.Todo) {
+	if todo.Rank == "" {
+		return
+	}
+	board.Todos.Remove(todo.ID)
+	position := 0
+	board.Todos.Each(func(i int, other *model.Todo) {
+		if !rankBefore(todo.Rank, other.Rank) {
+			position = i + 1
+		}
+	})
+	board.Todos.Insert(position, todo)
+}
+
+// attach adds the given task to the in memory state of the given board
+func (s *memory) attach(board *model.Board, task model.Task) {
+	todo := task.Base()
+	s.todos[todo.ID] = task
+	s.owners[todo.ID] = board.ID
+	board.AddTodo(todo)
+	s.todoIndex.RemoveItem(todo.ID)
+	s.todoIndex.AddItem(model.NewItem(todo.ID, todo.Name))
+}
+
+// keepBoard adds the given board to the in memory state, or replaces the board with its id keeping its todos
+func (s *memory) keepBoard(board *model.Board) {
+	if old, ok := s.boards[board.ID]; ok && old != board {
+		board.Todos = old.Todos
+	}
+	s.boards[board.ID] = board
+	s.boardIndex.RemoveItem(board.ID)
+	s.boardIndex.AddItem(model.NewItem(board.ID, board.Name))
+}
+
+// boardTodoIndex builds the index of the todos of the given board
+func (s *memory) boardTodoIndex(board *model.Board) *model.Index {
+	index := model.NewIndex()
+	board.Todos.Each(func(i int, todo *model.Todo) {
+		index.AddItem(model.NewItem(todo.ID, todo.Name))
+	})
+	return index
+}
+
+func (s *memory) Boards() []*model.Board {
+	ret := make([]*model.Board, 0, len(s.boards))
+	for _, b := range s.boards {
+		ret = append(ret, b)
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].CreationDate.Time().Before(ret[j].CreationDate.Time())
+	})
+	return ret
+}
+
+func (s *memory) Board(id uuid.UUID) (*model.Board, error) {
+	board, ok := s.boards[id]
+	if !ok {
+		return nil, errors.Wrapf(ErrNotFound, "board %s", id)
+	}
+	return board, nil
+}
+
+// forget removes the given board and its todos from the in memory state
+func (s *memory) forget(board *model.Board) {
+	board.Todos.Each(func(i int, todo *model.Todo) {
+		s.forgetTodo(todo.ID)
+	})
+	delete(s.boards, board.ID)
+	s.boardIndex.RemoveItem(board.ID)
+}
+
+// forgetTodo removes the todo with the given id from the in memory state, but not from its board
+func (s *memory) forgetTodo(id uuid.UUID) {
+	delete(s.todos, id)
+	delete(s.owners, id)
+	s.todoIndex.RemoveItem(id)
+}
+
+func (s *memory) Todos(board uuid.UUID) ([]model.Task, error) {
+	b, err := s.Board(board)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]model.Task, 0, b.Todos.Len())
+	b.Todos.Each(func(i int, todo *model.Todo) {
+		ret = append(ret, s.todos[todo.ID])
+	})
+	return ret, nil
+}
+
+func (s *memory) AllTodos() []model.Task {
+	ret := make([]model.Task, 0, len(s.todos))
+	for _, b := range s.Boards() {
+		todos, _ := s.Todos(b.ID)
+		ret = append(ret, todos...)
+	}
+	return ret
+}
+
+func (s *memory) Todo(id uuid.UUID) (model.Task, error) {
+	task, ok := s.todos[id]
+	if !ok {
+		return nil, errors.Wrapf(ErrNotFound, "todo %s", id)
+	}
+	return task, nil
+}
+
+func (s *memory) BoardOf(todo uuid.UUID) (*model.Board, error) {
+	board, ok := s.owners[todo]
+	if !ok {
+		return nil, errors.Wrapf(ErrNotFound, "todo %s", todo)
+	}
+	return s.Board(board)
+}
+
+func (s *memory) BoardIndex() *model.Index {
+	return s.boardIndex
+}
+
+func (s *memory) TodoIndex() *model.Index {
+	return s.todoIndex
+}
+
+func (s *memory) Tags() []*model.Tag {
+	ret := make([]*model.Tag, 0, len(s.tags))
+	for _, tag := range s.tags {
+		ret = append(ret, tag)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+func (s *memory) Tag(name string) (*model.Tag, error) {
+	for _, tag := range s.tags {
+		if tag.Name == name {
+			return tag, nil
+		}
+	}
+	return nil, errors.Wrapf(ErrNotFound, "tag %s", name)
+}
+
+func (s *memory) Users() []*model.User {
+	ret := make([]*model.User, 0, len(s.users))
+	for _, user := range s.users {
+		ret = append(ret, user)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+func (s *memory) User(name string) (*model.User, error) {
+	for _, user := range s.users {
+		if user.Name == name {
+			return user, nil
+		}
+	}
+	return nil, errors.Wrapf(ErrNotFound, "user %s", name)
+}
+
+func (s *memory) Milestones() []*model.Milestone {
+	ret := make([]*model.Milestone, 0, len(s.milestones))
+	for _, milestone := range s.milestones {
+		ret = append(ret, milestone)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+func (s *memory) Milestone(id uuid.UUID) (*model.Milestone, error) {
+	milestone, ok := s.milestones[id]
+	if !ok {
+		return nil, errors.Wrapf(ErrNotFound, "milestone %s", id)
+	}
+	return milestone, nil
+}
+
+// milestoneNamed returns the milestone with the given name, if any
+func (s *memory) milestoneNamed(name string) (*model.Milestone, bool) {
+	for _, milestone := range s.milestones {
+		if milestone.Name == name {
+			return milestone, true
+		}
+	}
+	return nil, false
+}
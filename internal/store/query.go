@@ -0,0 +1,65 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"time"
+
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/gofrs/uuid"
+)
+
+// TodoQuery selects todos by the fields a store can look up without reading every todo, the zero query selects all
+// of them
+type TodoQuery struct {
+	Board    uuid.UUID           // Only the todos of this board, when it is not nil
+	Status   *model.TodoStatus   // Only the todos with this status, when it is not nil
+	Priority *model.TodoPriority // Only the todos with this priority, when it is not nil
+	Now      time.Time           // The time the due dates are compared with
+	Overdue  bool                // Only the unfinished todos due before now
+	Soon     time.Duration       // Only the unfinished todos due within this duration after now, when it is not zero
+}
+
+// Match checks if the given todo has the fields selected by this query, whatever its board
+func (q *TodoQuery) Match(task model.Task) bool {
+	t := task.Base()
+	if q.Status != nil && t.Status != *q.Status {
+		return false
+	}
+	if q.Priority != nil && t.Priority != *q.Priority {
+		return false
+	}
+	if q.Overdue && !t.IsOverdue(q.Now) {
+		return false
+	}
+	return q.Soon == 0 || t.IsDueWithin(q.Now, q.Soon)
+}
+
+func (s *memory) FindTodos(query *TodoQuery) ([]model.Task, error) {
+	tasks := s.AllTodos()
+	if query.Board != uuid.Nil {
+		var err error
+		if tasks, err = s.Todos(query.Board); err != nil {
+			return nil, err
+		}
+	}
+	ret := make([]model.Task, 0, len(tasks))
+	for _, task := range tasks {
+		if query.Match(task) {
+			ret = append(ret, task)
+		}
+	}
+	return ret, nil
+}
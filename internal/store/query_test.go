@@ -0,0 +1,168 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	date "github.com/bykof/gostradamus"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/gofrs/uuid"
+)
+
+// fillQueries adds to the given store the boards main and other with todos of every kind of the queries, and an
+// overdue archived todo, returning the boards
+func fillQueries(t *testing.T, s Store, now time.Time) (*model.Board, *model.Board) {
+	t.Helper()
+	check := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	main, other := model.NewBoard2("main", ""), model.NewBoard2("other", "")
+	other.CreationDate = model.DateTime{DateTime: date.DateTimeFromTime(main.CreationDate.Time().Add(time.Second))}
+	check(s.SaveBoard(main))
+	check(s.SaveBoard(other))
+	add := func(board *model.Board, name string, status model.TodoStatus, priority model.TodoPriority,
+		due time.Duration) *model.Todo {
+		todo := model.NewTodo(name)
+		todo.Status, todo.Priority = status, priority
+		if status == model.STATUS_DONE {
+			todo.CompleteDate = model.DateTime{DateTime: date.DateTimeFromTime(now)}
+		}
+		if due != 0 {
+			todo.DueDate = model.DateTime{DateTime: date.DateTimeFromTime(now.Add(due))}
+		}
+		check(s.SaveTodo(board.ID, todo))
+		return todo
+	}
+	add(main, "overdue", model.STATUS_STARTED, model.PRIORITY_NORMAL, -time.Hour)
+	add(main, "done", model.STATUS_DONE, model.PRIORITY_NORMAL, -time.Hour)
+	add(main, "soon", model.STATUS_NEW, model.PRIORITY_NORMAL, 2*time.Hour)
+	add(main, "later", model.STATUS_NEW, model.PRIORITY_HIGH, 72*time.Hour)
+	add(main, "undated", model.STATUS_PAUSED, model.PRIORITY_HIGH, 0)
+	add(other, "other overdue", model.STATUS_NEW, model.PRIORITY_LOW, -time.Minute)
+	archived := add(other, "archived overdue", model.STATUS_NEW, model.PRIORITY_LOW, -time.Minute)
+	check(s.ArchiveTodo(archived.ID))
+	return main, other
+}
+
+func TestFindTodos(t *testing.T) {
+	status := func(status model.TodoStatus) *model.TodoStatus { return &status }
+	priority := func(priority model.TodoPriority) *model.TodoPriority { return &priority }
+	tests := []struct {
+		name    string
+		query   func(main, other uuid.UUID) TodoQuery
+		archive bool     // If the query is made on the archive
+		want    []string // The names of the todos found, in order
+	}{
+		{"all", func(main, other uuid.UUID) TodoQuery { return TodoQuery{} }, false,
+			[]string{"overdue", "done", "soon", "later", "undated", "other overdue"}},
+		{"board", func(main, other uuid.UUID) TodoQuery { return TodoQuery{Board: other} }, false,
+			[]string{"other overdue"}},
+		{"status", func(main, other uuid.UUID) TodoQuery { return TodoQuery{Status: status(model.STATUS_DONE)} }, false,
+			[]string{"done"}},
+		{"priority", func(main, other uuid.UUID) TodoQuery {
+			return TodoQuery{Priority: priority(model.PRIORITY_HIGH)}
+		}, false, []string{"later", "undated"}},
+		{"status and priority", func(main, other uuid.UUID) TodoQuery {
+			return TodoQuery{Status: status(model.STATUS_NEW), Priority: priority(model.PRIORITY_HIGH)}
+		}, false, []string{"later"}},
+		{"overdue", func(main, other uuid.UUID) TodoQuery { return TodoQuery{Overdue: true} }, false,
+			[]string{"overdue", "other overdue"}},
+		{"overdue of a board", func(main, other uuid.UUID) TodoQuery { return TodoQuery{Board: main, Overdue: true} },
+			false, []string{"overdue"}},
+		{"soon", func(main, other uuid.UUID) TodoQuery { return TodoQuery{Soon: 24 * time.Hour} }, false,
+			[]string{"soon"}},
+		{"overdue and soon", func(main, other uuid.UUID) TodoQuery {
+			return TodoQuery{Overdue: true, Soon: 24 * time.Hour}
+		}, false, []string{}},
+		{"archived overdue", func(main, other uuid.UUID) TodoQuery { return TodoQuery{Overdue: true} }, true,
+			[]string{"archived overdue"}},
+	}
+	now := time.Now()
+	for _, backend := range []string{JSONBackend, SQLiteBackend} {
+		for _, test := range tests {
+			t.Run(backend+" "+test.name, func(t *testing.T) {
+				s := newBackendStore(t, t.TempDir(), backend)
+				defer s.Close()
+				main, other := fillQueries(t, s, now)
+				if test.archive {
+					archive, err := s.Archive()
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer archive.Close()
+					s = archive
+				}
+				query := test.query(main.ID, other.ID)
+				query.Now = now
+				tasks, err := s.FindTodos(&query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := make([]string, 0, len(tasks))
+				for _, task := range tasks {
+					got = append(got, task.Base().Name)
+					// The todos found are the ones the store returns until they are saved
+					if same, err := s.Todo(task.Base().ID); err != nil || same != task {
+						t.Errorf("todo %s is not the one of the store", task.Base().Name)
+					}
+				}
+				if strings.Join(got, ",") != strings.Join(test.want, ",") {
+					t.Errorf("found %v, expected %v", got, test.want)
+				}
+			})
+		}
+	}
+}
+
+func TestFindTodosUnknownBoard(t *testing.T) {
+	for _, backend := range []string{JSONBackend, SQLiteBackend} {
+		t.Run(backend, func(t *testing.T) {
+			s := newBackendStore(t, t.TempDir(), backend)
+			defer s.Close()
+			board, _ := uuid.NewV4()
+			if _, err := s.FindTodos(&TodoQuery{Board: board}); err == nil {
+				t.Error("finding the todos of an unknown board did not fail")
+			}
+		})
+	}
+}
+
+func TestSQLiteIndexes(t *testing.T) {
+	root := t.TempDir()
+	if err := newBackendStore(t, root, SQLiteBackend).Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", filepath.Join(root, SQLiteFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, name := range []string{"todos_board", "todos_parent", "todos_status", "todos_priority", "todos_due_date",
+		"todos_complete_date", "notes_author", "efforts_date"} {
+		var found string
+		err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'index' AND name = ?`, name).Scan(&found)
+		if err != nil {
+			t.Errorf("the database has no index %s: %s", name, err)
+		}
+	}
+}
@@ -0,0 +1,953 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/gofrs/uuid"
+	// The pure go sqlite driver, registered as sqlite
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteFile is the database of a repository that is kept in sqlite instead of json files
+const SQLiteFile = "todoman.db"
+
+// SQLiteVersion is the version of the tables of the databases written by this version of todoman, kept as the user
+// version of the database
+const SQLiteVersion = 1
+
+// sqliteTables are the tables every database of a repository has
+var sqliteTables = []string{"boards", "todos", "notes", "efforts", "tags", "users", "removed"}
+
+// sqliteTime is the layout of the times kept in the database, which sort in the order of the times
+const sqliteTime = "2006-01-02T15:04:05.000000000Z"
+
+// sqliteSchema creates the tables and indexes of the database. The archived and removed boards and todos stay in the
+// same tables, in the area named after the directory they have in a json store, the others have an empty area. The
+// todos of a board and the ones selected by a query are looked up with the indexes of their columns, and so are the
+// notes of an author and the efforts of a date by the tools that read the database. The logs table keeps the lines
+// of the log files of the repository written by a transaction, until they are written to their files once it is
+// committed.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS boards (
+	id            TEXT NOT NULL,
+	area          TEXT NOT NULL DEFAULT '',
+	name          TEXT NOT NULL,
+	creation_date TEXT NOT NULL,
+	data          TEXT NOT NULL,
+	PRIMARY KEY (area, id)
+);
+CREATE TABLE IF NOT EXISTS todos (
+	id            TEXT PRIMARY KEY,
+	board         TEXT NOT NULL,
+	area          TEXT NOT NULL DEFAULT '',
+	agile         INTEGER NOT NULL,
+	parent        TEXT,
+	name          TEXT NOT NULL,
+	status        INTEGER NOT NULL,
+	priority      INTEGER NOT NULL,
+	creation_date TEXT NOT NULL,
+	start_date    TEXT,
+	due_date      TEXT,
+	complete_date TEXT,
+	rank          TEXT NOT NULL DEFAULT '',
+	data          TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS todos_board ON todos (area, board, rank);
+CREATE INDEX IF NOT EXISTS todos_parent ON todos (parent);
+CREATE INDEX IF NOT EXISTS todos_status ON todos (status);
+CREATE INDEX IF NOT EXISTS todos_priority ON todos (priority);
+CREATE INDEX IF NOT EXISTS todos_due_date ON todos (due_date);
+CREATE INDEX IF NOT EXISTS todos_complete_date ON todos (complete_date);
+CREATE TABLE IF NOT EXISTS notes (
+	todo          TEXT NOT NULL,
+	position      INTEGER NOT NULL,
+	name          TEXT NOT NULL,
+	author        TEXT NOT NULL,
+	creation_date TEXT NOT NULL,
+	data          TEXT NOT NULL,
+	PRIMARY KEY (todo, position)
+);
+CREATE INDEX IF NOT EXISTS notes_author ON notes (author);
+CREATE TABLE IF NOT EXISTS efforts (
+	todo     TEXT NOT NULL,
+	position INTEGER NOT NULL,
+	date     TEXT,
+	duration INTEGER NOT NULL,
+	data     TEXT NOT NULL,
+	PRIMARY KEY (todo, position)
+);
+CREATE INDEX IF NOT EXISTS efforts_date ON efforts (date);
+CREATE TABLE IF NOT EXISTS tags (
+	id   TEXT PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS users (
+	id    TEXT PRIMARY KEY,
+	name  TEXT NOT NULL UNIQUE,
+	email TEXT NOT NULL,
+	data  TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS milestones (
+	id       TEXT PRIMARY KEY,
+	name     TEXT NOT NULL UNIQUE,
+	due_date TEXT,
+	data     TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS removed (
+	id   TEXT PRIMARY KEY,
+	time TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS logs (
+	path TEXT NOT NULL,
+	at   INTEGER NOT NULL,
+	data BLOB NOT NULL
+);
+`
+
+// sqliteStore is a store that keeps the whole repository in a single sqlite database, with a row for each board,
+// todo, note, effort, tag, user and milestone. The notes and efforts of a todo have their own tables and the rest of
+// the todo is kept as json, next to columns with its main fields. The store loads all of the rows when it is opened, so
+// that it returns the same models until they are saved, and the todos of a board or of a query are selected by their
+// columns and then taken from memory.
+type sqliteStore struct {
+	*memory
+	db      *sql.DB
+	root    string // The directory of the database, to which the paths of the logs are relative
+	area    string
+	base    *sqliteStore // The store of the repository, for the store of one of its areas
+	lock    *Lock
+	pending *sql.Tx // The database transaction of the current batch, if any
+}
+
+// querier runs queries on either the database or one of its transactions
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// NewSQLiteStore opens (or creates) a sqlite based store with the given database file, without locking it
+func NewSQLiteStore(path string) (Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open %s", path)
+	}
+	// The repository lock keeps the other processes away, so a single connection is enough
+	db.SetMaxOpenConns(1)
+	version, err := sqliteVersion(db)
+	if err == nil && version > SQLiteVersion {
+		err = errors.Errorf("%s was written by a newer version of todoman", path)
+	}
+	if err == nil {
+		_, err = db.Exec(sqliteSchema + fmt.Sprintf("PRAGMA user_version = %d;", SQLiteVersion))
+		err = errors.Wrapf(err, "unable to create the tables of %s", path)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	s := &sqliteStore{memory: newMemory(), db: db, root: filepath.Dir(path)}
+	// The logs of a transaction that was committed right before a crash may not have been written yet
+	if err := errors.Combine(s.flushLogs(), s.load(db, "", nil)); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// sqliteVersion returns the version of the tables of the given database, zero for a new one
+func sqliteVersion(db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRow(`PRAGMA user_version`).Scan(&version)
+	return version, errors.Wrap(err, "unable to read the version of the database")
+}
+
+// CheckSQLite checks that the database of the given file is not damaged and has the tables of a version of todoman
+// that this one can read, without changing it
+func CheckSQLite(path string) error {
+	db, err := sql.Open("sqlite", "file:"+filepath.ToSlash(path)+"?mode=ro")
+	if err != nil {
+		return errors.Wrapf(err, "unable to open %s", path)
+	}
+	defer db.Close()
+	rows, err := db.Query(`PRAGMA integrity_check(10)`)
+	if err != nil {
+		return errors.Wrap(err, "unable to check the database")
+	}
+	problems := make([]string, 0)
+	err = scanRows(rows, func(data []byte) error {
+		if text := strings.TrimSpace(string(data)); text != "ok" && !strings.HasPrefix(text, "***") {
+			problems = append(problems, text)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("the database is damaged: %s", strings.Join(problems, "; "))
+	}
+	version, err := sqliteVersion(db)
+	if err != nil {
+		return err
+	}
+	if version < 1 || version > SQLiteVersion {
+		return errors.Errorf("unknown version %d of the database", version)
+	}
+	for _, table := range sqliteTables {
+		var count int
+		err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&count)
+		if err != nil {
+			return errors.Wrap(err, "unable to check the database")
+		}
+		if count == 0 {
+			return errors.Errorf("the database has no %s table", table)
+		}
+	}
+	return nil
+}
+
+// OpenSQLiteStore takes the lock of the repository at the given directory, exclusive when the store changes it, and
+// opens its sqlite based store, which holds the lock until it is closed
+func OpenSQLiteStore(root string, exclusive bool, timeout time.Duration) (Store, error) {
+	lock, err := LockRepository(root, exclusive, timeout)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewSQLiteStore(filepath.Join(root, SQLiteFile))
+	if err != nil {
+		lock.Unlock()
+		return nil, err
+	}
+	s.(*sqliteStore).lock = lock
+	return s, nil
+}
+
+func (s *sqliteStore) Close() error {
+	// The stores of the areas share the database of the repository store
+	if s.db == nil || s.area != "" {
+		return nil
+	}
+	err := errors.Wrap(s.db.Close(), "unable to close the database")
+	s.db = nil
+	if s.lock != nil {
+		err = errors.Combine(err, s.lock.Unlock())
+		s.lock = nil
+	}
+	return err
+}
+
+// update runs the given function inside a database transaction, which is committed when it succeeds, or inside the
+// transaction of the current batch
+func (s *sqliteStore) update(fn func(tx *sql.Tx) error) error {
+	if s.pending != nil {
+		return fn(s.pending)
+	}
+	if s.base != nil && s.base.pending != nil {
+		return fn(s.base.pending)
+	}
+	if s.lock != nil && !s.lock.Exclusive() {
+		return errors.New("the repository was opened for reading only")
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "unable to change the database")
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "unable to change the database")
+	}
+	return s.flushLogs()
+}
+
+// batch makes the changes of the given function in a single database transaction, which is committed when the
+// function succeeds. When the function fails the in memory state is read again from the database, dropping its
+// changes.
+func (s *sqliteStore) batch(fn func() error) error {
+	if s.pending != nil {
+		return fn()
+	}
+	if s.lock != nil && !s.lock.Exclusive() {
+		return errors.New("the repository was opened for reading only")
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "unable to change the database")
+	}
+	s.pending = tx
+	err = fn()
+	s.pending = nil
+	if err != nil {
+		tx.Rollback()
+		return errors.Combine(err, s.reload())
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "unable to change the database")
+	}
+	return s.flushLogs()
+}
+
+func (s *sqliteStore) writeLog(path string, at int64, data []byte) error {
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to write %s", path)
+	}
+	return s.update(func(tx *sql.Tx) error {
+		if at < 0 {
+			if at, err = s.logEnd(tx, rel); err != nil {
+				return err
+			}
+		}
+		// A nil slice would be kept as a null, when only the end of the file is dropped
+		_, err := tx.Exec(`INSERT INTO logs (path, at, data) VALUES (?, ?, ?)`, filepath.ToSlash(rel), at,
+			append([]byte{}, data...))
+		return errors.Wrapf(err, "unable to write %s", path)
+	})
+}
+
+// logEnd returns the size the log file at the given path, relative to the root of the store, has once its lines
+// written so far are flushed
+func (s *sqliteStore) logEnd(tx *sql.Tx, rel string) (int64, error) {
+	var end int64
+	err := tx.QueryRow(`SELECT at + length(data) FROM logs WHERE path = ? ORDER BY rowid DESC LIMIT 1`,
+		filepath.ToSlash(rel)).Scan(&end)
+	if errors.Is(err, sql.ErrNoRows) {
+		if info, err := os.Stat(filepath.Join(s.root, rel)); err == nil {
+			return info.Size(), nil
+		}
+		return 0, nil
+	}
+	return end, errors.Wrap(err, "unable to read the logs")
+}
+
+// flushLogs writes the lines of the logs of the committed transactions to their files, and then forgets them
+func (s *sqliteStore) flushLogs() error {
+	rows, err := s.db.Query(`SELECT rowid, path, at, data FROM logs ORDER BY rowid`)
+	if err != nil {
+		return errors.Wrap(err, "unable to read the logs")
+	}
+	type line struct {
+		path string
+		at   int64
+		data []byte
+	}
+	lines := make([]line, 0)
+	last := int64(-1)
+	for rows.Next() {
+		var l line
+		if err := rows.Scan(&last, &l.path, &l.at, &l.data); err != nil {
+			rows.Close()
+			return errors.Wrap(err, "unable to read the logs")
+		}
+		lines = append(lines, l)
+	}
+	if err := errors.Combine(rows.Err(), rows.Close()); err != nil {
+		return errors.Wrap(err, "unable to read the logs")
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	for _, l := range lines {
+		if err := writeAt(filepath.Join(s.root, filepath.FromSlash(l.path)), l.at, l.data); err != nil {
+			return err
+		}
+	}
+	_, err = s.db.Exec(`DELETE FROM logs WHERE rowid <= ?`, last)
+	return errors.Wrap(err, "unable to forget the written logs")
+}
+
+// querier returns the transaction of the current batch, so that it sees its changes, or else the database. The
+// store of an area uses the one of the repository, since the database only has one connection.
+func (s *sqliteStore) querier() querier {
+	if s.pending != nil {
+		return s.pending
+	}
+	if s.base != nil {
+		return s.base.querier()
+	}
+	return s.db
+}
+
+// reload replaces the in memory state with the one of the database, dropping the changes that were not committed
+func (s *sqliteStore) reload() error {
+	s.memory = newMemory()
+	return s.load(s.db, "", nil)
+}
+
+func (s *sqliteStore) Todos(board uuid.UUID) ([]model.Task, error) {
+	return s.FindTodos(&TodoQuery{Board: board})
+}
+
+func (s *sqliteStore) FindTodos(query *TodoQuery) ([]model.Task, error) {
+	where, args := []string{"t.area = ?"}, []any{s.area}
+	if query.Board != uuid.Nil {
+		if _, err := s.Board(query.Board); err != nil {
+			return nil, err
+		}
+		where, args = append(where, "t.board = ?"), append(args, query.Board.String())
+	}
+	if query.Status != nil {
+		where, args = append(where, "t.status = ?"), append(args, *query.Status)
+	}
+	if query.Priority != nil {
+		where, args = append(where, "t.priority = ?"), append(args, *query.Priority)
+	}
+	if query.Overdue || query.Soon != 0 {
+		where = append(where, "t.due_date IS NOT NULL", "t.status NOT IN (?, ?)")
+		args = append(args, model.STATUS_FINISHED, model.STATUS_DONE)
+	}
+	now := query.Now.UTC().Format(sqliteTime)
+	if query.Overdue {
+		where, args = append(where, "t.due_date < ?"), append(args, now)
+	}
+	if query.Soon != 0 {
+		where = append(where, "t.due_date >= ?", "t.due_date <= ?")
+		args = append(args, now, query.Now.Add(query.Soon).UTC().Format(sqliteTime))
+	}
+	return s.selectTodos(strings.Join(where, " AND "), args)
+}
+
+// selectTodos returns the todos of the rows of the todos table that match the given condition, from memory, in the
+// order of their boards and ranks
+func (s *sqliteStore) selectTodos(where string, args []any) ([]model.Task, error) {
+	rows, err := s.querier().Query(`SELECT t.id FROM todos t JOIN boards b ON b.area = t.area AND b.id = t.board
+		WHERE `+where+` ORDER BY b.creation_date, t.board, t.rank <> '', t.rank, t.rowid`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the todos")
+	}
+	ret := make([]model.Task, 0)
+	err = scanRows(rows, func(data []byte) error {
+		if task, ok := s.todos[uuid.FromStringOrNil(string(data))]; ok {
+			ret = append(ret, task)
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// load reads the boards and todos of the area of this store that match the given condition on the todos table,
+// all of them when it is empty, together with the tags, users and milestones when they are all read
+func (s *sqliteStore) load(q querier, where string, args []any) error {
+	if where == "" {
+		rows, err := q.Query(`SELECT data FROM boards WHERE area = ? ORDER BY creation_date`, s.area)
+		if err != nil {
+			return errors.Wrap(err, "unable to read the boards")
+		}
+		err = scanRows(rows, func(data []byte) error {
+			_, err := s.loadBoard(data)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		where, args = "1 = 1", []any{}
+		if s.area == "" {
+			if err := s.loadTags(q); err != nil {
+				return err
+			}
+			if err := s.loadUsers(q); err != nil {
+				return err
+			}
+			if err := s.loadMilestones(q); err != nil {
+				return err
+			}
+		}
+	}
+	return s.loadTodos(q, where, args)
+}
+
+// loadBoard adds the board of the given json data to the in memory state
+func (s *sqliteStore) loadBoard(data []byte) (*model.Board, error) {
+	board := &model.Board{}
+	if err := json.Unmarshal(data, board); err != nil {
+		return nil, errors.Wrap(err, "unable to parse board")
+	}
+	board.Todos = model.NewCollection[*model.Todo]()
+	s.keepBoard(board)
+	return board, nil
+}
+
+// loadTags reads the tags of the repository
+func (s *sqliteStore) loadTags(q querier) error {
+	rows, err := q.Query(`SELECT data FROM tags`)
+	if err != nil {
+		return errors.Wrap(err, "unable to read the tags")
+	}
+	return scanRows(rows, func(data []byte) error {
+		tag := &model.Tag{}
+		if err := json.Unmarshal(data, tag); err != nil {
+			return errors.Wrap(err, "unable to parse tag")
+		}
+		s.tags[tag.ID] = tag
+		return nil
+	})
+}
+
+// loadUsers reads the users of the repository
+func (s *sqliteStore) loadUsers(q querier) error {
+	rows, err := q.Query(`SELECT data FROM users`)
+	if err != nil {
+		return errors.Wrap(err, "unable to read the users")
+	}
+	return scanRows(rows, func(data []byte) error {
+		user := &model.User{}
+		if err := json.Unmarshal(data, user); err != nil {
+			return errors.Wrap(err, "unable to parse user")
+		}
+		s.users[user.ID] = user
+		return nil
+	})
+}
+
+// loadMilestones reads the milestones of the repository
+func (s *sqliteStore) loadMilestones(q querier) error {
+	rows, err := q.Query(`SELECT data FROM milestones`)
+	if err != nil {
+		return errors.Wrap(err, "unable to read the milestones")
+	}
+	return scanRows(rows, func(data []byte) error {
+		milestone := &model.Milestone{}
+		if err := json.Unmarshal(data, milestone); err != nil {
+			return errors.Wrap(err, "unable to parse milestone")
+		}
+		s.milestones[milestone.ID] = milestone
+		return nil
+	})
+}
+
+// loadTodos reads the todos of the area of this store that match the given condition, together with their notes and
+// efforts, and adds them to their boards in the order of their rank
+func (s *sqliteStore) loadTodos(q querier, where string, args []any) error {
+	notes, err := s.loadDetails(q, "notes", where, args)
+	if err != nil {
+		return err
+	}
+	efforts, err := s.loadDetails(q, "efforts", where, args)
+	if err != nil {
+		return err
+	}
+	rows, err := q.Query(`SELECT t.id, t.board, t.agile, t.data FROM todos t WHERE t.area = ? AND `+where+`
+		ORDER BY t.board, t.rank <> '', t.rank, t.rowid`, append([]any{s.area}, args...)...)
+	if err != nil {
+		return errors.Wrap(err, "unable to read the todos")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, board string
+		var agile bool
+		var data []byte
+		if err := rows.Scan(&id, &board, &agile, &data); err != nil {
+			return errors.Wrap(err, "unable to read the todos")
+		}
+		task, err := decodeTodo(data, agile, notes[id], efforts[id])
+		if err != nil {
+			return errors.Wrapf(err, "unable to parse todo %s", id)
+		}
+		b, ok := s.boards[uuid.FromStringOrNil(board)]
+		if !ok {
+			return errors.Errorf("todo %s belongs to the missing board %s", id, board)
+		}
+		s.attach(b, task)
+		s.place(b, task.Base())
+	}
+	return errors.Wrap(rows.Err(), "unable to read the todos")
+}
+
+// loadDetails reads the json data of the rows of the given table, either notes or efforts, of the todos that match
+// the given condition, by todo and in their order
+func (s *sqliteStore) loadDetails(q querier, table, where string, args []any) (map[string][]json.RawMessage, error) {
+	rows, err := q.Query(`SELECT d.todo, d.data FROM `+table+` d JOIN todos t ON t.id = d.todo
+		WHERE t.area = ? AND `+where+` ORDER BY d.todo, d.position`, append([]any{s.area}, args...)...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read the %s", table)
+	}
+	defer rows.Close()
+	details := make(map[string][]json.RawMessage)
+	for rows.Next() {
+		var todo string
+		var data []byte
+		if err := rows.Scan(&todo, &data); err != nil {
+			return nil, errors.Wrapf(err, "unable to read the %s", table)
+		}
+		details[todo] = append(details[todo], data)
+	}
+	return details, errors.Wrapf(rows.Err(), "unable to read the %s", table)
+}
+
+// scanRows calls the given function with the single column of every row, closing the rows afterwards
+func scanRows(rows *sql.Rows, fn func(data []byte) error) error {
+	defer rows.Close()
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return errors.Wrap(err, "unable to read the database")
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return errors.Wrap(rows.Err(), "unable to read the database")
+}
+
+// decodeTodo reads a todo from its json data without the notes and efforts, and the json data of those
+func decodeTodo(data []byte, agile bool, notes, efforts []json.RawMessage) (model.Task, error) {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	var err error
+	if fields["notes"], err = json.Marshal(append([]json.RawMessage{}, notes...)); err != nil {
+		return nil, err
+	}
+	if agile {
+		if fields["efforts"], err = json.Marshal(append([]json.RawMessage{}, efforts...)); err != nil {
+			return nil, err
+		}
+	}
+	if data, err = json.Marshal(fields); err != nil {
+		return nil, err
+	}
+	return DecodeTask(data)
+}
+
+// timeOf returns the given date as kept in the database, or nil when it is not defined
+func timeOf(date model.DateTime) any {
+	if date.IsZero() {
+		return nil
+	}
+	return date.Time().UTC().Format(sqliteTime)
+}
+
+// idOf returns the given id as kept in the database, or nil for the nil id
+func idOf(id uuid.UUID) any {
+	if id == uuid.Nil {
+		return nil
+	}
+	return id.String()
+}
+
+// writeBoard writes the given board into the given area
+func writeBoard(tx *sql.Tx, area string, board *model.Board) error {
+	data, err := json.Marshal(board)
+	if err != nil {
+		return errors.Wrapf(err, "unable to encode board %s", board.Name)
+	}
+	_, err = tx.Exec(`INSERT INTO boards (id, area, name, creation_date, data) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (area, id) DO UPDATE SET name = excluded.name, data = excluded.data`,
+		board.ID.String(), area, board.Name, timeOf(board.CreationDate), data)
+	return errors.Wrapf(err, "unable to write board %s", board.Name)
+}
+
+// writeTodo writes the given todo of the board with the given id into the area of this store, with its notes and
+// efforts
+func (s *sqliteStore) writeTodo(tx *sql.Tx, board uuid.UUID, task model.Task) error {
+	todo := task.Base()
+	data, err := json.Marshal(task)
+	if err != nil {
+		return errors.Wrapf(err, "unable to encode todo %s", todo.Name)
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return errors.Wrapf(err, "unable to encode todo %s", todo.Name)
+	}
+	_, agile := fields["efforts"]
+	delete(fields, "notes")
+	delete(fields, "efforts")
+	if data, err = json.Marshal(fields); err != nil {
+		return errors.Wrapf(err, "unable to encode todo %s", todo.Name)
+	}
+	_, err = tx.Exec(`INSERT INTO todos (id, board, area, agile, parent, name, status, priority, creation_date,
+			start_date, due_date, complete_date, rank, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET board = excluded.board, area = excluded.area, agile = excluded.agile,
+			parent = excluded.parent, name = excluded.name, status = excluded.status, priority = excluded.priority,
+			start_date = excluded.start_date, due_date = excluded.due_date, complete_date = excluded.complete_date,
+			rank = excluded.rank, data = excluded.data`,
+		todo.ID.String(), board.String(), s.area, agile, idOf(todo.Parent), todo.Name, todo.Status, todo.Priority,
+		timeOf(todo.CreationDate), timeOf(todo.StartDate), timeOf(todo.DueDate), timeOf(todo.CompleteDate), todo.Rank,
+		data)
+	if err != nil {
+		return errors.Wrapf(err, "unable to write todo %s", todo.Name)
+	}
+	if err := writeNotes(tx, todo); err != nil {
+		return err
+	}
+	if ag, ok := task.(*model.AgileTodo); ok {
+		return writeEfforts(tx, ag)
+	}
+	return nil
+}
+
+// writeNotes replaces the notes of the given todo
+func writeNotes(tx *sql.Tx, todo *model.Todo) error {
+	if _, err := tx.Exec(`DELETE FROM notes WHERE todo = ?`, todo.ID.String()); err != nil {
+		return errors.Wrapf(err, "unable to write the notes of todo %s", todo.Name)
+	}
+	if todo.Notes == nil {
+		return nil
+	}
+	for i, note := range todo.Notes.Values() {
+		data, err := json.Marshal(note)
+		if err != nil {
+			return errors.Wrapf(err, "unable to encode note %s", note.Name)
+		}
+		_, err = tx.Exec(`INSERT INTO notes (todo, position, name, author, creation_date, data) VALUES (?, ?, ?, ?, ?, ?)`,
+			todo.ID.String(), i, note.Name, note.Author, timeOf(note.CreationDate), data)
+		if err != nil {
+			return errors.Wrapf(err, "unable to write note %s", note.Name)
+		}
+	}
+	return nil
+}
+
+// writeEfforts replaces the efforts of the given agile todo
+func writeEfforts(tx *sql.Tx, todo *model.AgileTodo) error {
+	if _, err := tx.Exec(`DELETE FROM efforts WHERE todo = ?`, todo.ID.String()); err != nil {
+		return errors.Wrapf(err, "unable to write the efforts of todo %s", todo.Name)
+	}
+	if todo.Effort == nil {
+		return nil
+	}
+	for i, effort := range todo.Effort.Values() {
+		data, err := json.Marshal(effort)
+		if err != nil {
+			return errors.Wrap(err, "unable to encode effort")
+		}
+		_, err = tx.Exec(`INSERT INTO efforts (todo, position, date, duration, data) VALUES (?, ?, ?, ?, ?)`,
+			todo.ID.String(), i, timeOf(effort.Date), int64(effort.Duration), data)
+		if err != nil {
+			return errors.Wrapf(err, "unable to write the efforts of todo %s", todo.Name)
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) SaveBoard(board *model.Board) error {
+	if err := board.Validate(); err != nil {
+		return invalid(err)
+	}
+	return s.update(func(tx *sql.Tx) error {
+		if err := writeBoard(tx, s.area, board); err != nil {
+			return err
+		}
+		s.keepBoard(board)
+		return nil
+	})
+}
+
+func (s *sqliteStore) RemoveBoard(id uuid.UUID) error {
+	return s.update(func(tx *sql.Tx) error {
+		if err := s.stashBoard(tx, TrashDir, id); err != nil {
+			return err
+		}
+		return markRemovedRow(tx, id, time.Now())
+	})
+}
+
+func (s *sqliteStore) SaveTodo(board uuid.UUID, task model.Task) error {
+	b, err := s.Board(board)
+	if err != nil {
+		return err
+	}
+	if err := task.Validate(); err != nil {
+		return invalid(err)
+	}
+	todo := task.Base()
+	if owner, ok := s.owners[todo.ID]; ok && owner != board {
+		return errors.Errorf("todo %s belongs to another board", todo.Name)
+	}
+	if todo.Rank == "" && !b.HasTodo(todo.ID) {
+		last := ""
+		if b.Todos.Len() > 0 {
+			last = b.Todos.At(b.Todos.Len() - 1).Rank
+		}
+		todo.Rank = model.RankBetween(last, "")
+	}
+	return s.update(func(tx *sql.Tx) error {
+		if err := s.writeTodo(tx, board, task); err != nil {
+			return err
+		}
+		if old, ok := s.todos[todo.ID]; ok && old != task {
+			b.Todos.Insert(b.Todos.Position(todo.ID), todo)
+		}
+		s.attach(b, task)
+		s.place(b, todo)
+		return nil
+	})
+}
+
+func (s *sqliteStore) TransferTodo(id uuid.UUID, board uuid.UUID) error {
+	from, err := s.BoardOf(id)
+	if err != nil {
+		return err
+	}
+	to, err := s.Board(board)
+	if err != nil {
+		return err
+	}
+	if from.ID == to.ID {
+		return nil
+	}
+	task := s.todos[id]
+	todo := task.Base()
+	rank := todo.Rank
+	todo.Rank = ""
+	if to.Todos.Len() > 0 {
+		todo.Rank = to.Todos.At(to.Todos.Len() - 1).Rank
+	}
+	todo.Rank = model.RankBetween(todo.Rank, "")
+	err = s.update(func(tx *sql.Tx) error {
+		return s.writeTodo(tx, to.ID, task)
+	})
+	if err != nil {
+		todo.Rank = rank
+		return err
+	}
+	from.RemoveTodo(id)
+	s.attach(to, task)
+	s.place(to, todo)
+	return nil
+}
+
+func (s *sqliteStore) RemoveTodo(id uuid.UUID) error {
+	return s.update(func(tx *sql.Tx) error {
+		if err := s.stashTodo(tx, TrashDir, id); err != nil {
+			return err
+		}
+		return markRemovedRow(tx, id, time.Now())
+	})
+}
+
+func (s *sqliteStore) SaveTag(tag *model.Tag) error {
+	if err := tag.Validate(); err != nil {
+		return invalid(err)
+	}
+	if other, err := s.Tag(tag.Name); err == nil && other.ID != tag.ID {
+		return errors.Errorf("there is already a tag named %s", tag.Name)
+	}
+	data, err := json.Marshal(tag)
+	if err != nil {
+		return errors.Wrapf(err, "unable to encode tag %s", tag.Name)
+	}
+	return s.update(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO tags (id, name, data) VALUES (?, ?, ?)
+			ON CONFLICT (id) DO UPDATE SET name = excluded.name, data = excluded.data`, tag.ID.String(), tag.Name, data)
+		if err != nil {
+			return errors.Wrapf(err, "unable to write tag %s", tag.Name)
+		}
+		s.tags[tag.ID] = tag
+		return nil
+	})
+}
+
+func (s *sqliteStore) RemoveTag(name string) error {
+	tag, err := s.Tag(name)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM tags WHERE id = ?`, tag.ID.String()); err != nil {
+			return errors.Wrapf(err, "unable to remove tag %s", tag.Name)
+		}
+		delete(s.tags, tag.ID)
+		return nil
+	})
+}
+
+func (s *sqliteStore) SaveUser(user *model.User) error {
+	if err := user.Validate(); err != nil {
+		return invalid(err)
+	}
+	if other, err := s.User(user.Name); err == nil && other.ID != user.ID {
+		return errors.Errorf("there is already a user named %s", user.Name)
+	}
+	data, err := json.Marshal(user)
+	if err != nil {
+		return errors.Wrapf(err, "unable to encode user %s", user.Name)
+	}
+	return s.update(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO users (id, name, email, data) VALUES (?, ?, ?, ?)
+			ON CONFLICT (id) DO UPDATE SET name = excluded.name, email = excluded.email, data = excluded.data`,
+			user.ID.String(), user.Name, user.Email, data)
+		if err != nil {
+			return errors.Wrapf(err, "unable to write user %s", user.Name)
+		}
+		s.users[user.ID] = user
+		return nil
+	})
+}
+
+func (s *sqliteStore) RemoveUser(name string) error {
+	user, err := s.User(name)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM users WHERE id = ?`, user.ID.String()); err != nil {
+			return errors.Wrapf(err, "unable to remove user %s", user.Name)
+		}
+		delete(s.users, user.ID)
+		return nil
+	})
+}
+
+func (s *sqliteStore) SaveMilestone(milestone *model.Milestone) error {
+	if err := milestone.Validate(); err != nil {
+		return invalid(err)
+	}
+	if other, ok := s.milestoneNamed(milestone.Name); ok && other.ID != milestone.ID {
+		return errors.Errorf("there is already a milestone named %s", milestone.Name)
+	}
+	data, err := json.Marshal(milestone)
+	if err != nil {
+		return errors.Wrapf(err, "unable to encode milestone %s", milestone.Name)
+	}
+	return s.update(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO milestones (id, name, due_date, data) VALUES (?, ?, ?, ?)
+			ON CONFLICT (id) DO UPDATE SET name = excluded.name, due_date = excluded.due_date, data = excluded.data`,
+			milestone.ID.String(), milestone.Name, timeOf(milestone.DueDate), data)
+		if err != nil {
+			return errors.Wrapf(err, "unable to write milestone %s", milestone.Name)
+		}
+		s.milestones[milestone.ID] = milestone
+		return nil
+	})
+}
+
+func (s *sqliteStore) RemoveMilestone(id uuid.UUID) error {
+	milestone, err := s.Milestone(id)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM milestones WHERE id = ?`, milestone.ID.String()); err != nil {
+			return errors.Wrapf(err, "unable to remove milestone %s", milestone.Name)
+		}
+		delete(s.milestones, milestone.ID)
+		return nil
+	})
+}
@@ -0,0 +1,303 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/gofrs/uuid"
+)
+
+// The areas of a sqlite store keep their boards and todos in the same tables as the others, and a board with todos
+// in an area has a copy of its row in it
+
+// areaStore returns the store of the given area, which shares the database of this store
+func (s *sqliteStore) areaStore(area string) (Store, error) {
+	a := &sqliteStore{memory: newMemory(), db: s.db, root: s.root, area: area, base: s, lock: s.lock}
+	if err := a.load(s.querier(), "", nil); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// saveAreaTodo updates the todo through the store of the given area, inside the database transaction of this store
+func (s *sqliteStore) saveAreaTodo(area string, board uuid.UUID, task model.Task) error {
+	a, err := s.areaStore(area)
+	if err != nil {
+		return err
+	}
+	stash := a.(*sqliteStore)
+	return s.update(func(tx *sql.Tx) error {
+		stash.pending = tx
+		defer func() { stash.pending = nil }()
+		return stash.SaveTodo(board, task)
+	})
+}
+
+// hasBoard checks if the given area has a copy of the board with the given id
+func hasBoard(tx *sql.Tx, area string, id uuid.UUID) (bool, error) {
+	var count int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM boards WHERE area = ? AND id = ?`, area, id.String()).Scan(&count)
+	return count > 0, errors.Wrap(err, "unable to read the boards")
+}
+
+// readBoard reads the copy of the board with the given id of the given area
+func readBoard(tx *sql.Tx, area string, id uuid.UUID) (*model.Board, error) {
+	var data []byte
+	err := tx.QueryRow(`SELECT data FROM boards WHERE area = ? AND id = ?`, area, id.String()).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrapf(ErrNotFound, "board %s in the %s", id, area)
+	} else if err != nil {
+		return nil, errors.Wrap(err, "unable to read the boards")
+	}
+	board := &model.Board{}
+	if err := json.Unmarshal(data, board); err != nil {
+		return nil, errors.Wrap(err, "unable to parse board")
+	}
+	board.Todos = model.NewCollection[*model.Todo]()
+	return board, nil
+}
+
+// stashBoard moves the board with the given id and all of its todos to the given area
+func (s *sqliteStore) stashBoard(tx *sql.Tx, area string, id uuid.UUID) error {
+	board, err := s.Board(id)
+	if err != nil {
+		return err
+	}
+	if err := writeBoard(tx, area, board); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM boards WHERE area = ? AND id = ?`, s.area, id.String())
+	if err == nil {
+		_, err = tx.Exec(`UPDATE todos SET area = ? WHERE area = ? AND board = ?`, area, s.area, id.String())
+	}
+	if err != nil {
+		return errors.Wrapf(err, "unable to move board %s to the %s", board.Name, area)
+	}
+	s.forget(board)
+	return nil
+}
+
+// stashTodo moves the todo with the given id to the given area, into a copy of its board
+func (s *sqliteStore) stashTodo(tx *sql.Tx, area string, id uuid.UUID) error {
+	board, err := s.BoardOf(id)
+	if err != nil {
+		return err
+	}
+	if err := writeBoard(tx, area, board); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE todos SET area = ? WHERE id = ?`, area, id.String()); err != nil {
+		return errors.Wrapf(err, "unable to move todo %s to the %s", id, area)
+	}
+	board.RemoveTodo(id)
+	s.forgetTodo(id)
+	return nil
+}
+
+// unstashBoard moves the board with the given id and all of its todos out of the given area
+func (s *sqliteStore) unstashBoard(tx *sql.Tx, area string, id uuid.UUID) error {
+	stashed, err := readBoard(tx, area, id)
+	if err != nil {
+		return err
+	}
+	if _, ok := s.boards[id]; !ok {
+		// The whole board is moved back
+		if err := writeBoard(tx, s.area, stashed); err != nil {
+			return err
+		}
+		s.keepBoard(stashed)
+	}
+	ids := make([]any, 0)
+	rows, err := tx.Query(`SELECT id FROM todos WHERE area = ? AND board = ?`, area, id.String())
+	if err != nil {
+		return errors.Wrapf(err, "unable to read the todos of board %s in the %s", stashed.Name, area)
+	}
+	err = scanRows(rows, func(data []byte) error {
+		ids = append(ids, string(data))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE todos SET area = ? WHERE area = ? AND board = ?`, s.area, area, id.String())
+	if err == nil {
+		_, err = tx.Exec(`DELETE FROM boards WHERE area = ? AND id = ?`, area, id.String())
+	}
+	if err != nil {
+		return errors.Wrapf(err, "unable to move board %s out of the %s", stashed.Name, area)
+	}
+	for _, todo := range ids {
+		if err := s.loadTodos(tx, "t.id = ?", []any{todo}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unstashTodo moves the todo with the given id out of the given area, back to its board
+func (s *sqliteStore) unstashTodo(tx *sql.Tx, area string, id uuid.UUID) error {
+	var board string
+	err := tx.QueryRow(`SELECT board FROM todos WHERE area = ? AND id = ?`, area, id.String()).Scan(&board)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.Wrapf(ErrNotFound, "todo %s in the %s", id, area)
+	} else if err != nil {
+		return errors.Wrap(err, "unable to read the todos")
+	}
+	boardID := uuid.FromStringOrNil(board)
+	if _, ok := s.boards[boardID]; !ok {
+		// The board is in the area too, so it comes back without its other todos
+		stashed, err := readBoard(tx, area, boardID)
+		if err != nil {
+			return err
+		}
+		if err := writeBoard(tx, s.area, stashed); err != nil {
+			return err
+		}
+		s.keepBoard(stashed)
+	}
+	if _, err := tx.Exec(`UPDATE todos SET area = ? WHERE id = ?`, s.area, id.String()); err != nil {
+		return errors.Wrapf(err, "unable to move todo %s out of the %s", id, area)
+	}
+	// The copy of the board goes away with its last todo
+	_, err = tx.Exec(`DELETE FROM boards WHERE area = ? AND id = ?
+		AND NOT EXISTS (SELECT 1 FROM todos WHERE area = ? AND board = ?)`, area, board, area, board)
+	if err != nil {
+		return errors.Wrapf(err, "unable to move todo %s out of the %s", id, area)
+	}
+	return s.loadTodos(tx, "t.id = ?", []any{id.String()})
+}
+
+func (s *sqliteStore) Archive() (Store, error) {
+	return s.areaStore(ArchiveDir)
+}
+
+func (s *sqliteStore) ArchiveBoard(id uuid.UUID) error {
+	return s.update(func(tx *sql.Tx) error {
+		return s.stashBoard(tx, ArchiveDir, id)
+	})
+}
+
+func (s *sqliteStore) ArchiveTodo(id uuid.UUID) error {
+	return s.update(func(tx *sql.Tx) error {
+		return s.stashTodo(tx, ArchiveDir, id)
+	})
+}
+
+func (s *sqliteStore) RestoreBoard(id uuid.UUID) error {
+	return s.update(func(tx *sql.Tx) error {
+		return s.unstashBoard(tx, ArchiveDir, id)
+	})
+}
+
+func (s *sqliteStore) RestoreTodo(id uuid.UUID) error {
+	return s.update(func(tx *sql.Tx) error {
+		return s.unstashTodo(tx, ArchiveDir, id)
+	})
+}
+
+// markRemovedRow sets the removal time of the board or todo with the given id, or clears it when the time is zero
+func markRemovedRow(tx *sql.Tx, id uuid.UUID, when time.Time) error {
+	var err error
+	if when.IsZero() {
+		_, err = tx.Exec(`DELETE FROM removed WHERE id = ?`, id.String())
+	} else {
+		_, err = tx.Exec(`INSERT INTO removed (id, time) VALUES (?, ?) ON CONFLICT (id) DO UPDATE SET time = excluded.time`,
+			id.String(), when.UTC().Format(sqliteTime))
+	}
+	return errors.Wrap(err, "unable to write the removed boards and todos")
+}
+
+func (s *sqliteStore) Trash() (Store, error) {
+	return s.areaStore(TrashDir)
+}
+
+func (s *sqliteStore) Removed() (map[uuid.UUID]time.Time, error) {
+	rows, err := s.querier().Query(`SELECT id, time FROM removed`)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the removed boards and todos")
+	}
+	defer rows.Close()
+	removed := make(map[uuid.UUID]time.Time)
+	for rows.Next() {
+		var id, when string
+		if err := rows.Scan(&id, &when); err != nil {
+			return nil, errors.Wrap(err, "unable to read the removed boards and todos")
+		}
+		t, err := time.Parse(sqliteTime, when)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse the removal time of %s", id)
+		}
+		removed[uuid.FromStringOrNil(id)] = t
+	}
+	return removed, errors.Wrap(rows.Err(), "unable to read the removed boards and todos")
+}
+
+func (s *sqliteStore) Recover(id uuid.UUID) error {
+	return s.update(func(tx *sql.Tx) error {
+		board, err := hasBoard(tx, TrashDir, id)
+		if err != nil {
+			return err
+		}
+		if !board {
+			if err := s.unstashTodo(tx, TrashDir, id); err != nil {
+				return err
+			}
+			return markRemovedRow(tx, id, time.Time{})
+		}
+		if err := s.unstashBoard(tx, TrashDir, id); err != nil {
+			return err
+		}
+		if err := markRemovedRow(tx, id, time.Time{}); err != nil {
+			return err
+		}
+		for _, todo := range s.boards[id].Todos.Values() {
+			if err := markRemovedRow(tx, todo.ID, time.Time{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqliteStore) EmptyTrash(before time.Time) error {
+	old := before.UTC().Format(sqliteTime)
+	// The todos go away when either they or their board were removed before the time, and the copy of a board that
+	// was not removed itself goes away with its last todo
+	statements := []string{
+		`DELETE FROM notes WHERE todo IN (SELECT id FROM todos WHERE area = ?1 AND (id IN (SELECT id FROM removed
+			WHERE time < ?2) OR board IN (SELECT id FROM removed WHERE time < ?2)))`,
+		`DELETE FROM efforts WHERE todo IN (SELECT id FROM todos WHERE area = ?1 AND (id IN (SELECT id FROM removed
+			WHERE time < ?2) OR board IN (SELECT id FROM removed WHERE time < ?2)))`,
+		`DELETE FROM todos WHERE area = ?1 AND (id IN (SELECT id FROM removed WHERE time < ?2)
+			OR board IN (SELECT id FROM removed WHERE time < ?2))`,
+		`DELETE FROM boards WHERE area = ?1 AND (id IN (SELECT id FROM removed WHERE time < ?2)
+			OR (id NOT IN (SELECT id FROM removed) AND id NOT IN (SELECT board FROM todos WHERE area = ?1)))`,
+		`DELETE FROM removed WHERE id NOT IN (SELECT id FROM todos WHERE area = ?1)
+			AND id NOT IN (SELECT id FROM boards WHERE area = ?1)`,
+	}
+	return s.update(func(tx *sql.Tx) error {
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement, TrashDir, old); err != nil {
+				return errors.Wrap(err, "unable to empty the trash")
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,148 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	// ErrNotFound is returned when a model does not exist in the store
+	ErrNotFound = errors.Sentinel("not found")
+	// ErrInvalid is returned when a model that is not valid is saved in the store
+	ErrInvalid = errors.Sentinel("not valid")
+)
+
+// invalidError is the error of a model that is not valid, with the reasons given by its validation
+type invalidError struct {
+	error
+}
+
+// Is checks if the given error is ErrInvalid
+func (e *invalidError) Is(target error) bool {
+	return target == ErrInvalid
+}
+
+// Unwrap returns the reasons of this error
+func (e *invalidError) Unwrap() error {
+	return e.error
+}
+
+// invalid returns the error of a model that is not valid for the reasons of the given error
+func invalid(err error) error {
+	return &invalidError{error: err}
+}
+
+// Store represents a repository of boards and their todos
+type Store interface {
+	// Boards returns all of the boards in the store
+	Boards() []*model.Board
+	// Board returns the board with the given id
+	Board(id uuid.UUID) (*model.Board, error)
+	// SaveBoard creates or updates the given board
+	SaveBoard(board *model.Board) error
+	// RemoveBoard moves the board with the given id and all of its todos to the trash
+	RemoveBoard(id uuid.UUID) error
+
+	// Todos returns all of the todos of the board with the given id
+	Todos(board uuid.UUID) ([]model.Task, error)
+	// AllTodos returns the todos of every board
+	AllTodos() []model.Task
+	// FindTodos returns the todos selected by the given query, in the order of their boards
+	FindTodos(query *TodoQuery) ([]model.Task, error)
+	// Todo returns the todo with the given id
+	Todo(id uuid.UUID) (model.Task, error)
+	// BoardOf returns the board that contains the todo with the given id
+	BoardOf(todo uuid.UUID) (*model.Board, error)
+	// SaveTodo creates or updates the given todo inside the board with the given id
+	SaveTodo(board uuid.UUID, todo model.Task) error
+	// RemoveTodo moves the todo with the given id to the trash
+	RemoveTodo(id uuid.UUID) error
+	// TransferTodo moves the todo with the given id to the end of the board with the given id, at once so that the
+	// todo is always in exactly one of the boards
+	TransferTodo(id uuid.UUID, board uuid.UUID) error
+
+	// Tags returns all of the tags, ordered by name
+	Tags() []*model.Tag
+	// Tag returns the tag with the given name
+	Tag(name string) (*model.Tag, error)
+	// SaveTag creates or updates the given tag
+	SaveTag(tag *model.Tag) error
+	// RemoveTag removes the tag with the given name
+	RemoveTag(name string) error
+
+	// Users returns all of the users of the repository, ordered by name
+	Users() []*model.User
+	// User returns the user with the given name
+	User(name string) (*model.User, error)
+	// SaveUser creates or updates the given user
+	SaveUser(user *model.User) error
+	// RemoveUser removes the user with the given name
+	RemoveUser(name string) error
+
+	// Milestones returns all of the milestones of the repository, ordered by name
+	Milestones() []*model.Milestone
+	// Milestone returns the milestone with the given id
+	Milestone(id uuid.UUID) (*model.Milestone, error)
+	// SaveMilestone creates or updates the given milestone
+	SaveMilestone(milestone *model.Milestone) error
+	// RemoveMilestone removes the milestone with the given id
+	RemoveMilestone(id uuid.UUID) error
+
+	// Archive returns the store of the archived boards and todos, which have the same ids as before being archived
+	Archive() (Store, error)
+	// ArchiveBoard moves the board with the given id and all of its todos to the archive
+	ArchiveBoard(id uuid.UUID) error
+	// ArchiveTodo moves the todo with the given id to the archive, into an archived copy of its board
+	ArchiveTodo(id uuid.UUID) error
+	// RestoreBoard moves the archived board with the given id and all of its archived todos out of the archive
+	RestoreBoard(id uuid.UUID) error
+	// RestoreTodo moves the archived todo with the given id out of the archive, back to its board
+	RestoreTodo(id uuid.UUID) error
+
+	// Trash returns the store of the removed boards and todos, which have the same ids as before being removed
+	Trash() (Store, error)
+	// Removed returns when each board and todo of the trash was removed
+	Removed() (map[uuid.UUID]time.Time, error)
+	// Recover moves the removed board or todo with the given id out of the trash, a board together with all of its
+	// removed todos
+	Recover(id uuid.UUID) error
+	// EmptyTrash removes for good the boards and todos that were moved to the trash before the given time
+	EmptyTrash(before time.Time) error
+
+	// Close releases the repository of the store, which must not be used afterwards
+	Close() error
+
+	// batch runs the given function so that the changes it makes through the store are committed together when it
+	// succeeds, and none of them when it fails
+	batch(fn func() error) error
+
+	// writeLog writes the given data to the log file at the given path from the given offset on, dropping what
+	// followed it, or at its end when the offset is negative, together with the other changes of the current batch
+	writeLog(path string, at int64, data []byte) error
+
+	// saveAreaTodo updates the given todo of the board with the given id inside the given area, like the archive or
+	// the trash, together with the other changes of the current batch
+	saveAreaTodo(area string, board uuid.UUID, todo model.Task) error
+
+	// BoardIndex returns the index of all boards
+	BoardIndex() *model.Index
+	// TodoIndex returns the index of all todos
+	TodoIndex() *model.Index
+}
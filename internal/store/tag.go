@@ -0,0 +1,63 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"github.com/chordflower/todoman/internal/model"
+)
+
+// TagTodo attaches the tag with the given name to the given task, the tag must exist
+func TagTodo(s Store, task model.Task, name string) error {
+	if _, err := s.Tag(name); err != nil {
+		return err
+	}
+	task.Base().AddTag(name)
+	return SaveTask(s, task)
+}
+
+// RenameTag renames the tag with the given name, rewriting every todo that has it, archived and removed ones too
+func RenameTag(s Store, old, new string) error {
+	tag, err := s.Tag(old)
+	if err != nil {
+		return err
+	}
+	return s.batch(func() error {
+		tag.Name = new
+		if err := s.SaveTag(tag); err != nil {
+			tag.Name = old
+			return err
+		}
+		return RewriteTodos(s, func(task model.Task) bool {
+			return task.Base().RenameTag(old, new)
+		})
+	})
+}
+
+// RemoveTag removes the tag with the given name, detaching it from every todo that has it, archived and removed ones
+// too
+func RemoveTag(s Store, name string) error {
+	return s.batch(func() error {
+		if err := s.RemoveTag(name); err != nil {
+			return err
+		}
+		return RewriteTodos(s, func(task model.Task) bool {
+			if !task.Base().HasTag(name) {
+				return false
+			}
+			task.Base().RemoveTag(name)
+			return true
+		})
+	})
+}
@@ -0,0 +1,345 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/gofrs/uuid"
+)
+
+// DependencyGraph builds the dependency graph of all todos in the given store
+func DependencyGraph(s Store) *model.DependencyGraph {
+	tasks := s.AllTodos()
+	todos := make([]*model.Todo, 0, len(tasks))
+	for _, task := range tasks {
+		todos = append(todos, task.Base())
+	}
+	return model.NewDependencyGraph(todos...)
+}
+
+// SaveTask saves the given task in the board it already belongs to
+func SaveTask(s Store, task model.Task) error {
+	board, err := s.BoardOf(task.Base().ID)
+	if err != nil {
+		return err
+	}
+	return s.SaveTodo(board.ID, task)
+}
+
+// RewriteTodos changes every todo of the given store with the given function, including the archived and removed
+// ones so that they are still valid when they come back, and saves the todos it changed, which are the ones for
+// which it returns true. The todos of every area are saved through the given store in a single batch.
+func RewriteTodos(s Store, rewrite func(task model.Task) bool) error {
+	return s.batch(func() error {
+		for _, task := range s.AllTodos() {
+			if rewrite(task) {
+				if err := SaveTask(s, task); err != nil {
+					return err
+				}
+			}
+		}
+		areas := []struct {
+			name string
+			open func() (Store, error)
+		}{{ArchiveDir, s.Archive}, {TrashDir, s.Trash}}
+		for _, area := range areas {
+			stash, err := area.open()
+			if err != nil {
+				return err
+			}
+			err = rewriteArea(s, area.name, stash, rewrite)
+			if err := errors.Combine(err, stash.Close()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// rewriteArea changes the todos of the given store of an area of the other store with the given function, and saves
+// the ones it changed through the other store
+func rewriteArea(s Store, area string, stash Store, rewrite func(task model.Task) bool) error {
+	for _, task := range stash.AllTodos() {
+		if !rewrite(task) {
+			continue
+		}
+		board, err := stash.BoardOf(task.Base().ID)
+		if err != nil {
+			return err
+		}
+		if err := s.saveAreaTodo(area, board.ID, task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddDependency makes the todo with the given id depend on the other todo, refusing to create cycles
+func AddDependency(s Store, id, on uuid.UUID) error {
+	task, err := s.Todo(id)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Todo(on); err != nil {
+		return err
+	}
+	if err := DependencyGraph(s).Depend(id, on); err != nil {
+		return err
+	}
+	// The graph shares the todos of the store, so the dependency is already in place
+	return SaveTask(s, task)
+}
+
+// RemoveDependency removes the dependency of the todo with the given id on the other todo
+func RemoveDependency(s Store, id, on uuid.UUID) error {
+	task, err := s.Todo(id)
+	if err != nil {
+		return err
+	}
+	task.Base().RemoveDependency(on)
+	return SaveTask(s, task)
+}
+
+// SetStatus changes the status of the todo with the given id, a todo can only be started if all of
+// its dependencies are finished. When a recurring todo is done, its next occurrence is created in the same
+// board and returned, the schedule then moves from the done todo to the new one.
+func SetStatus(s Store, id uuid.UUID, status model.TodoStatus) (model.Task, error) {
+	task, err := s.Todo(id)
+	if err != nil {
+		return nil, err
+	}
+	if status == model.STATUS_STARTED {
+		if err := DependencyGraph(s).CanStart(id); err != nil {
+			return nil, err
+		}
+	}
+	var next model.Task
+	if status == model.STATUS_DONE && task.Base().Status != model.STATUS_DONE {
+		if next, err = createNextOccurrence(s, task); err != nil {
+			return nil, err
+		}
+	}
+	task.Base().SetStatus(status)
+	return next, SaveTask(s, task)
+}
+
+// createNextOccurrence saves the next occurrence of the given recurring task, if there is one
+func createNextOccurrence(s Store, task model.Task) (model.Task, error) {
+	var next model.Task
+	now := time.Now()
+	switch t := task.(type) {
+	case *model.AgileTodo:
+		if n, ok := t.NextOccurrence(now); ok {
+			next = n
+		}
+	case *model.Todo:
+		if n, ok := t.NextOccurrence(now); ok {
+			next = n
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+	board, err := s.BoardOf(task.Base().ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.SaveTodo(board.ID, next); err != nil {
+		return nil, err
+	}
+	task.Base().Recurrence = nil
+	return next, nil
+}
+
+// Subtasks returns the subtasks of the todo with the given id
+func Subtasks(s Store, id uuid.UUID) []model.Task {
+	ret := make([]model.Task, 0)
+	for _, task := range s.AllTodos() {
+		if task.Base().Parent == id {
+			ret = append(ret, task)
+		}
+	}
+	return ret
+}
+
+// AddSubtask saves the given task as a subtask of the todo with the given id, in the same board
+func AddSubtask(s Store, parent uuid.UUID, task model.Task) error {
+	board, err := s.BoardOf(parent)
+	if err != nil {
+		return err
+	}
+	task.Base().Parent = parent
+	return s.SaveTodo(board.ID, task)
+}
+
+// Progress returns how many of the subtasks and checklist items of the todo with the given id are done,
+// and how many there are
+func Progress(s Store, id uuid.UUID) (done, total int, err error) {
+	task, err := s.Todo(id)
+	if err != nil {
+		return 0, 0, err
+	}
+	subtasks := make([]*model.Todo, 0)
+	for _, sub := range Subtasks(s, id) {
+		subtasks = append(subtasks, sub.Base())
+	}
+	done, total = task.Base().Progress(subtasks)
+	return done, total, nil
+}
+
+// RemoveTodoTree removes the todo with the given id, together with all of its subtasks
+func RemoveTodoTree(s Store, id uuid.UUID) error {
+	for _, sub := range Subtasks(s, id) {
+		if err := RemoveTodoTree(s, sub.Base().ID); err != nil {
+			return err
+		}
+	}
+	return s.RemoveTodo(id)
+}
+
+// MoveTodo moves the todo with the given id to the given position of its board, counted from zero among the other
+// todos of the board. Only the rank of the moved todo changes, unless the board still has todos without a rank,
+// which are ranked in their current order first.
+func MoveTodo(s Store, id uuid.UUID, position int) error {
+	board, err := s.BoardOf(id)
+	if err != nil {
+		return err
+	}
+	task, err := s.Todo(id)
+	if err != nil {
+		return err
+	}
+	tasks, err := s.Todos(board.ID)
+	if err != nil {
+		return err
+	}
+	others := make([]model.Task, 0, len(tasks))
+	for _, other := range tasks {
+		if other.Base().ID != id {
+			others = append(others, other)
+		}
+	}
+	if position < 0 {
+		position = 0
+	}
+	if position > len(others) {
+		position = len(others)
+	}
+	if err := rankAll(s, board.ID, others); err != nil {
+		return err
+	}
+	prev, next := "", ""
+	if position > 0 {
+		prev = others[position-1].Base().Rank
+	}
+	if position < len(others) {
+		next = others[position].Base().Rank
+	}
+	task.Base().Rank = model.RankBetween(prev, next)
+	return s.SaveTodo(board.ID, task)
+}
+
+// rankAll gives a rank to the given todos of a board if any of them does not have one, keeping their order
+func rankAll(s Store, board uuid.UUID, tasks []model.Task) error {
+	ranked := true
+	for _, task := range tasks {
+		ranked = ranked && task.Base().Rank != ""
+	}
+	if ranked {
+		return nil
+	}
+	rank := ""
+	for _, task := range tasks {
+		rank = model.RankBetween(rank, "")
+		task.Base().Rank = rank
+		if err := s.SaveTodo(board, task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TransferTodoTree moves the todo with the given id and all of its subtasks to the end of the board with the given
+// id at once, the subtasks of a todo can only be moved together with it
+func TransferTodoTree(s Store, id, board uuid.UUID) error {
+	task, err := s.Todo(id)
+	if err != nil {
+		return err
+	}
+	if parent, err := s.Todo(task.Base().Parent); err == nil {
+		return errors.Errorf("todo %s is a subtask of %s, move %s instead", task.Base().Name, parent.Base().Name,
+			parent.Base().Name)
+	}
+	return s.batch(func() error {
+		return transferTree(s, task, board)
+	})
+}
+
+// TransferTodoTreeAt moves the todo with the given id and all of its subtasks to the board with the given id, like
+// TransferTodoTree, and then the todo to the given position of that board, like MoveTodo, in a single batch
+func TransferTodoTreeAt(s Store, id, board uuid.UUID, position int) error {
+	return s.batch(func() error {
+		if err := TransferTodoTree(s, id, board); err != nil {
+			return err
+		}
+		return MoveTodo(s, id, position)
+	})
+}
+
+func transferTree(s Store, task model.Task, board uuid.UUID) error {
+	if err := s.TransferTodo(task.Base().ID, board); err != nil {
+		return err
+	}
+	for _, sub := range Subtasks(s, task.Base().ID) {
+		if err := transferTree(s, sub, board); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CopyTodoTree copies the todo with the given id and all of its subtasks to the end of the board with the given
+// id, returning the copy of the todo. The copies keep everything but the ids, and a copied subtask only stays a
+// subtask when it is copied to the board of its parent.
+func CopyTodoTree(s Store, id, board uuid.UUID) (model.Task, error) {
+	task, err := s.Todo(id)
+	if err != nil {
+		return nil, err
+	}
+	if owner, err := s.BoardOf(task.Base().Parent); err != nil || owner.ID != board {
+		return copyTree(s, task, uuid.Nil, board)
+	}
+	return copyTree(s, task, task.Base().Parent, board)
+}
+
+func copyTree(s Store, task model.Task, parent, board uuid.UUID) (model.Task, error) {
+	copied, err := model.CopyTask(task)
+	if err != nil {
+		return nil, err
+	}
+	copied.Base().Parent = parent
+	if err := s.SaveTodo(board, copied); err != nil {
+		return nil, err
+	}
+	for _, sub := range Subtasks(s, task.Base().ID) {
+		if _, err := copyTree(s, sub, copied.Base().ID, board); err != nil {
+			return nil, err
+		}
+	}
+	return copied, nil
+}
@@ -0,0 +1,240 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+package store
+
+import (
+	"strings"
+	"testing"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/gofrs/uuid"
+)
+
+// fillDependencies adds to the given store the todos a, b, c and d, where c depends on b and b depends on a,
+// returning their ids by name
+func fillDependencies(t *testing.T, s Store) map[string]uuid.UUID {
+	t.Helper()
+	board := model.NewBoard2("main", "")
+	if err := s.SaveBoard(board); err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]uuid.UUID)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		todo := model.NewTodo(name)
+		if err := s.SaveTodo(board.ID, todo); err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = todo.ID
+	}
+	for _, dependency := range [][2]string{{"b", "a"}, {"c", "b"}} {
+		if err := AddDependency(s, ids[dependency[0]], ids[dependency[1]]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ids
+}
+
+func TestAddDependency(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string // The todo that gets the dependency
+		on   string // The todo it depends on, an unknown one when it is empty
+		err  error  // The error of the dependency, nil when it is added
+	}{
+		{"independent todo", "d", "a", nil},
+		{"dependency reached through another", "c", "a", nil},
+		{"self dependency", "a", "a", model.ErrDependencyCycle},
+		{"direct cycle", "a", "b", model.ErrDependencyCycle},
+		{"indirect cycle", "a", "c", model.ErrDependencyCycle},
+		{"unknown todo", "a", "", ErrNotFound},
+	}
+	for _, backend := range []string{JSONBackend, SQLiteBackend} {
+		for _, test := range tests {
+			t.Run(backend+" "+test.name, func(t *testing.T) {
+				root := t.TempDir()
+				s := newBackendStore(t, root, backend)
+				ids := fillDependencies(t, s)
+				on, ok := ids[test.on]
+				if !ok {
+					on = uuid.Must(uuid.NewV4())
+				}
+				err := AddDependency(s, ids[test.id], on)
+				if test.err == nil && err != nil {
+					t.Fatalf("the dependency was refused: %s", err)
+				}
+				if test.err != nil && !errors.Is(err, test.err) {
+					t.Fatalf("the dependency failed with %v, expected %v", err, test.err)
+				}
+				if err := s.Close(); err != nil {
+					t.Fatal(err)
+				}
+				s = newBackendStore(t, root, backend)
+				defer s.Close()
+				task, err := s.Todo(ids[test.id])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := task.Base().DependsOn; test.err == nil && !containsID(got, on) ||
+					test.err != nil && containsID(got, on) {
+					t.Errorf("%s depends on %v after the dependency", test.id, got)
+				}
+				blocked := false
+				for _, blocker := range DependencyGraph(s).Blockers(ids[test.id]) {
+					blocked = blocked || blocker.ID == on
+				}
+				if blocked != (test.err == nil) {
+					t.Errorf("the dependency graph has the dependency of %s: %v", test.id, blocked)
+				}
+			})
+		}
+	}
+}
+
+// containsID checks if the given ids contain the other one
+func containsID(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStartBlocked(t *testing.T) {
+	tests := []struct {
+		name     string
+		finished []string // The todos finished before the start
+		start    string   // The todo that is started
+		blocked  bool     // If the start is refused
+	}{
+		{"without dependencies", nil, "a", false},
+		{"unfinished dependency", nil, "b", true},
+		{"finished dependency", []string{"a"}, "b", false},
+		{"only an unfinished indirect dependency", []string{"b"}, "c", false},
+		{"unfinished direct dependency", []string{"a"}, "c", true},
+	}
+	for _, backend := range []string{JSONBackend, SQLiteBackend} {
+		for _, test := range tests {
+			t.Run(backend+" "+test.name, func(t *testing.T) {
+				s := newBackendStore(t, t.TempDir(), backend)
+				defer s.Close()
+				ids := fillDependencies(t, s)
+				for _, name := range test.finished {
+					if _, err := SetStatus(s, ids[name], model.STATUS_DONE); err != nil {
+						t.Fatal(err)
+					}
+				}
+				_, err := SetStatus(s, ids[test.start], model.STATUS_STARTED)
+				if blocked := errors.Is(err, model.ErrTodoBlocked); blocked != test.blocked {
+					t.Fatalf("starting %s failed with %v, expected blocked %v", test.start, err, test.blocked)
+				}
+				task, err := s.Todo(ids[test.start])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if started := task.Base().Status == model.STATUS_STARTED; started == test.blocked {
+					t.Errorf("%s has the status %s after the start", test.start, task.Base().Status.Name())
+				}
+			})
+		}
+	}
+}
+
+// failingSaves is a store whose todos can be transferred but not saved
+type failingSaves struct {
+	Store
+}
+
+func (s *failingSaves) SaveTodo(board uuid.UUID, task model.Task) error {
+	return errors.New("unable to save the todo")
+}
+
+// boardTodos returns the names of the todos of the board with the given name, in order
+func boardTodos(t *testing.T, s Store, name string) string {
+	t.Helper()
+	board, err := s.Board(s.BoardIndex().FindByName(name)[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := s.Todos(board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		names = append(names, task.Base().Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestTransferTodoTreeAt(t *testing.T) {
+	tests := []struct {
+		name     string
+		position int
+		fail     bool   // If the todos can not be saved, so that the move fails after the transfer
+		main     string // The todos of the main board after the move
+		other    string // The todos of the other board after the move
+	}{
+		{"top", 0, false, "b", "a,x,y,a1"},
+		{"between", 1, false, "b", "x,a,y,a1"},
+		{"after the subtask", 3, false, "b", "x,y,a1,a"},
+		{"failed move", 1, true, "a,a1,b", "x,y"},
+	}
+	for _, backend := range []string{JSONBackend, SQLiteBackend} {
+		for _, test := range tests {
+			t.Run(backend+" "+test.name, func(t *testing.T) {
+				root := t.TempDir()
+				s := newBackendStore(t, root, backend)
+				main, other := model.NewBoard2("main", ""), model.NewBoard2("other", "")
+				for _, board := range []*model.Board{main, other} {
+					if err := s.SaveBoard(board); err != nil {
+						t.Fatal(err)
+					}
+				}
+				a := model.NewTodo("a")
+				for _, todo := range []struct {
+					board uuid.UUID
+					todo  *model.Todo
+				}{{main.ID, a}, {main.ID, model.NewSubtask("a1", a)}, {main.ID, model.NewTodo("b")},
+					{other.ID, model.NewTodo("x")}, {other.ID, model.NewTodo("y")}} {
+					if err := s.SaveTodo(todo.board, todo.todo); err != nil {
+						t.Fatal(err)
+					}
+				}
+				var target Store = s
+				if test.fail {
+					target = &failingSaves{s}
+				}
+				err := TransferTodoTreeAt(target, a.ID, other.ID, test.position)
+				if test.fail != (err != nil) {
+					t.Fatalf("the move failed with %v", err)
+				}
+				if err := s.Close(); err != nil {
+					t.Fatal(err)
+				}
+				s = newBackendStore(t, root, backend)
+				defer s.Close()
+				if got := boardTodos(t, s, "main"); got != test.main {
+					t.Errorf("the main board has %s, expected %s", got, test.main)
+				}
+				if got := boardTodos(t, s, "other"); got != test.other {
+					t.Errorf("the other board has %s, expected %s", got, test.other)
+				}
+			})
+		}
+	}
+}
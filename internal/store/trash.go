@@ -0,0 +1,174 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+	"github.com/gofrs/uuid"
+)
+
+// TrashDir is the area of the repository that keeps the removed boards and todos until the trash is emptied
+const TrashDir = "trash"
+
+// removedFile returns the file that keeps when each board and todo of the trash was removed
+func (s *jsonStore) removedFile() string {
+	return filepath.Join(s.root, TrashDir, "removed.json")
+}
+
+// markRemoved adds the setting of the removal time of the board or todo with the given id to the given transaction,
+// or its clearing when the time is zero
+func (s *jsonStore) markRemoved(tx *transaction, id uuid.UUID, when time.Time) error {
+	removed, err := s.Removed()
+	if err != nil {
+		return err
+	}
+	if when.IsZero() {
+		delete(removed, id)
+	} else {
+		removed[id] = when
+	}
+	return tx.write(s.removedFile(), removed)
+}
+
+func (s *jsonStore) Trash() (Store, error) {
+	return s.area(TrashDir)
+}
+
+func (s *jsonStore) Removed() (map[uuid.UUID]time.Time, error) {
+	removed := make(map[uuid.UUID]time.Time)
+	if err := readJSON(s.removedFile(), &removed); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return removed, nil
+}
+
+func (s *jsonStore) Recover(id uuid.UUID) error {
+	tx := s.begin()
+	if !exists(s.areaDir(TrashDir, id)) {
+		if err := s.unstashTodo(tx, TrashDir, id); err != nil {
+			return err
+		}
+		if err := s.markRemoved(tx, id, time.Time{}); err != nil {
+			return err
+		}
+		return s.commit(tx)
+	}
+	if err := s.unstashBoard(tx, TrashDir, id); err != nil {
+		return err
+	}
+	removed, err := s.Removed()
+	if err != nil {
+		return err
+	}
+	delete(removed, id)
+	s.boards[id].Todos.Each(func(i int, todo *model.Todo) {
+		delete(removed, todo.ID)
+	})
+	if err := tx.write(s.removedFile(), removed); err != nil {
+		return err
+	}
+	return s.commit(tx)
+}
+
+func (s *jsonStore) EmptyTrash(before time.Time) error {
+	removed, err := s.Removed()
+	if err != nil {
+		return err
+	}
+	dirs, err := os.ReadDir(filepath.Join(s.root, TrashDir, "boards"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrap(err, "unable to read the trash")
+	}
+	tx := s.begin()
+	old := func(id uuid.UUID) bool {
+		when, ok := removed[id]
+		return ok && when.Before(before)
+	}
+	for _, dir := range dirs {
+		board := uuid.FromStringOrNil(dir.Name())
+		path := filepath.Join(s.root, TrashDir, "boards", dir.Name())
+		todos, err := os.ReadDir(filepath.Join(path, "todos"))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return errors.Wrap(err, "unable to read the trash")
+		}
+		left := 0
+		for _, todo := range todos {
+			id := uuid.FromStringOrNil(strings.TrimSuffix(todo.Name(), ".json"))
+			if !old(id) && !old(board) {
+				left++
+				continue
+			}
+			tx.remove(filepath.Join(path, "todos", todo.Name()))
+			delete(removed, id)
+		}
+		// The copy of a board that was not removed itself goes away with its last todo
+		_, isRemoved := removed[board]
+		if old(board) || (left == 0 && !isRemoved) {
+			tx.remove(path)
+			delete(removed, board)
+		}
+	}
+	if len(tx.ops) == 0 {
+		return nil
+	}
+	if err := tx.write(s.removedFile(), removed); err != nil {
+		return err
+	}
+	return s.commit(tx)
+}
+
+// RecoverTodoTree moves the removed todo with the given id and all of its removed subtasks out of the trash
+func RecoverTodoTree(s Store, id uuid.UUID) error {
+	trash, err := s.Trash()
+	if err != nil {
+		return err
+	}
+	return unstashTree(trash, id, "restore", s.Recover)
+}
+
+// unstashTree moves the todo with the given id of the given area and all of its subtasks in the area out of it, with
+// the given function, refusing to do it for a subtask whose parent is in the area too
+func unstashTree(area Store, id uuid.UUID, verb string, unstash func(id uuid.UUID) error) error {
+	task, err := area.Todo(id)
+	if err != nil {
+		return err
+	}
+	if parent, err := area.Todo(task.Base().Parent); err == nil {
+		return errors.Errorf("todo %s is a subtask of %s, %s %s instead", task.Base().Name, parent.Base().Name, verb,
+			parent.Base().Name)
+	}
+	// The area is read before moving anything, since it does not see the changes made through the store
+	ids := make([]uuid.UUID, 0)
+	var collect func(task model.Task)
+	collect = func(task model.Task) {
+		ids = append(ids, task.Base().ID)
+		for _, sub := range Subtasks(area, task.Base().ID) {
+			collect(sub)
+		}
+	}
+	collect(task)
+	for _, id := range ids {
+		if err := unstash(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 carddamom
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/todoman/internal/model"
+)
+
+// FindUser returns the user with the given name or email
+func FindUser(s Store, ref string) (*model.User, error) {
+	if user, err := s.User(ref); err == nil {
+		return user, nil
+	}
+	for _, user := range s.Users() {
+		if user.Email != "" && strings.EqualFold(user.Email, ref) {
+			return user, nil
+		}
+	}
+	return nil, errors.Wrapf(ErrNotFound, "user %s", ref)
+}
+
+// AssignTodo assigns the users with the given names or emails to the given task, all of them must exist
+func AssignTodo(s Store, task model.Task, refs ...string) error {
+	for _, ref := range refs {
+		user, err := FindUser(s, ref)
+		if err != nil {
+			return err
+		}
+		task.Base().Assign(user.Name)
+	}
+	return SaveTask(s, task)
+}
+
+// RemoveUser removes the user with the given name, unassigning them from every todo, archived and removed ones too
+func RemoveUser(s Store, name string) error {
+	return s.batch(func() error {
+		if err := s.RemoveUser(name); err != nil {
+			return err
+		}
+		return RewriteTodos(s, func(task model.Task) bool {
+			if !task.Base().IsAssigned(name) {
+				return false
+			}
+			task.Base().Unassign(name)
+			return true
+		})
+	})
+}
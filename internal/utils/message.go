@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"image/color"
 
 	aurora "github.com/logrusorgru/aurora/v3"
 )
@@ -54,3 +55,12 @@ func Debug(msg string, args ...any) {
 func DisableDebug() {
 	defaultMessage.debugEnabled = false
 }
+
+// Colour returns the given text coloured with the closest terminal colour to the given one
+func Colour(text string, c color.RGBA) string {
+	if c.A == 0 {
+		return text
+	}
+	index := 16 + 36*(uint16(c.R)*5/255) + 6*(uint16(c.G)*5/255) + uint16(c.B)*5/255
+	return aurora.Index(uint8(index), text).String()
+}